@@ -11,6 +11,9 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	// Pos returns the position in the source of the token associated with the
+	// node, e.g. the operator of an infix expression.
+	Pos() token.Position
 }
 
 // Statement represents a Monkey statement in the AST.
@@ -44,6 +47,12 @@ func (p *Program) TokenLiteral() string {
 	}
 	return ""
 }
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
 func (p *Program) String() string {
 	var out bytes.Buffer
 	for _, s := range p.Statements {
@@ -57,7 +66,11 @@ func (p *Program) String() string {
 type LetStatement struct {
 	Token token.Token
 	Name  *Identifier // Name of the variable being assigned to
-	Value Expression  // Value of the variable being assigned to
+	// Pattern is set instead of Name when the value is destructured into
+	// several variables, e.g. let [a, b] = pair; It's an ArrayPattern or a
+	// HashPattern.
+	Pattern Expression
+	Value   Expression // Value of the variable being assigned to
 }
 
 func (ls *LetStatement) statementNode() {}
@@ -67,10 +80,15 @@ func (ls *LetStatement) statementNode() {}
 func (ls *LetStatement) TokenLiteral() string {
 	return ls.Token.Literal
 }
+func (ls *LetStatement) Pos() token.Position { return ls.Token.Pos }
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(ls.TokenLiteral() + " ")
-	out.WriteString(ls.Name.String())
+	if ls.Pattern != nil {
+		out.WriteString(ls.Pattern.String())
+	} else {
+		out.WriteString(ls.Name.String())
+	}
 	out.WriteString(" = ")
 	if ls.Value != nil {
 		out.WriteString(ls.Value.String())
@@ -91,6 +109,7 @@ func (rs *ReturnStatement) statementNode() {}
 func (rs *ReturnStatement) TokenLiteral() string {
 	return rs.Token.Literal
 }
+func (rs *ReturnStatement) Pos() token.Position { return rs.Token.Pos }
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 	out.WriteString(rs.TokenLiteral() + " ")
@@ -111,6 +130,7 @@ func (es *ExpressionStatement) statementNode() {}
 func (es *ExpressionStatement) TokenLiteral() string {
 	return es.Token.Literal
 }
+func (es *ExpressionStatement) Pos() token.Position { return es.Token.Pos }
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -135,6 +155,7 @@ func (i *Identifier) expressionNode() {}
 func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
+func (i *Identifier) Pos() token.Position { return i.Token.Pos }
 
 type IntegerLiteral struct {
 	Token token.Token
@@ -145,6 +166,7 @@ func (il *IntegerLiteral) expressionNode() {}
 func (il *IntegerLiteral) TokenLiteral() string {
 	return il.Token.Literal
 }
+func (il *IntegerLiteral) Pos() token.Position { return il.Token.Pos }
 func (il *IntegerLiteral) String() string {
 	return il.Token.Literal
 }
@@ -164,6 +186,7 @@ func (pe *PrefixExpression) expressionNode() {}
 func (pe *PrefixExpression) TokenLiteral() string {
 	return pe.Token.Literal
 }
+func (pe *PrefixExpression) Pos() token.Position { return pe.Token.Pos }
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 	// Add brackets to remove ambiguity about which operands belong to which
@@ -186,6 +209,7 @@ func (oe *InfixExpression) expressionNode() {}
 func (oe *InfixExpression) TokenLiteral() string {
 	return oe.Token.Literal
 }
+func (oe *InfixExpression) Pos() token.Position { return oe.Token.Pos }
 func (oe *InfixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -205,6 +229,7 @@ func (b *Boolean) expressionNode() {}
 func (b *Boolean) TokenLiteral() string {
 	return b.Token.Literal
 }
+func (b *Boolean) Pos() token.Position { return b.Token.Pos }
 func (b *Boolean) String() string {
 	return b.Token.Literal
 }
@@ -218,6 +243,7 @@ type IfExpression struct {
 
 func (ie *IfExpression) expressionNode()      {}
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.Position  { return ie.Token.Pos }
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("if")
@@ -231,6 +257,125 @@ func (ie *IfExpression) String() string {
 	return out.String()
 }
 
+// SwitchExpression evaluates the body of the first of its cases with a value
+// equal to its Value, or if there isn't one, its default case.
+// e.g. switch (x) { case 1, 2: "small"; default: "big" }
+type SwitchExpression struct {
+	// The 'switch' token
+	Token   token.Token
+	Value   Expression
+	Cases   []*SwitchCase
+	Default *BlockStatement
+}
+
+func (se *SwitchExpression) expressionNode()      {}
+func (se *SwitchExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SwitchExpression) Pos() token.Position  { return se.Token.Pos }
+func (se *SwitchExpression) String() string {
+	cases := []string{}
+	for _, c := range se.Cases {
+		cases = append(cases, c.String())
+	}
+	if se.Default != nil {
+		cases = append(cases, "default: "+se.Default.String())
+	}
+	return "switch(" + se.Value.String() + ") {" + strings.Join(cases, " ") +
+		"}"
+}
+
+// SwitchCase is one case of a switch expression, which is selected if any of
+// its Values equal the switch's value.
+type SwitchCase struct {
+	// The 'case' token
+	Token  token.Token
+	Values []Expression
+	Body   *BlockStatement
+}
+
+func (sc *SwitchCase) String() string {
+	values := []string{}
+	for _, v := range sc.Values {
+		values = append(values, v.String())
+	}
+	return "case " + strings.Join(values, ", ") + ": " + sc.Body.String()
+}
+
+// MatchExpression evaluates the body of the first of its arms whose pattern
+// matches its value.
+// e.g. match (x) { 0 => "zero", n if n < 0 => "negative", _ => "positive" }
+type MatchExpression struct {
+	// The 'match' token
+	Token token.Token
+	Value Expression
+	Arms  []*MatchArm
+}
+
+func (me *MatchExpression) expressionNode()      {}
+func (me *MatchExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MatchExpression) Pos() token.Position  { return me.Token.Pos }
+func (me *MatchExpression) String() string {
+	arms := []string{}
+	for _, arm := range me.Arms {
+		arms = append(arms, arm.String())
+	}
+	return "match(" + me.Value.String() + ") {" + strings.Join(arms, ", ") +
+		"}"
+}
+
+// MatchArm is one arm of a match expression. It matches if any of its
+// Patterns match the value, and its Guard, if it has one, is then truthy.
+//
+// A pattern is an integer, boolean or string literal, which matches an equal
+// value; the identifier _, which matches anything; any other identifier,
+// which matches anything and binds it to that name, in a scope which the
+// Guard and Body are evaluated in; or a VariantPattern. An arm with more than
+// one pattern, an or-pattern, must bind the same names in each of them.
+type MatchArm struct {
+	Patterns []Expression
+	Guard    Expression
+	Body     Expression
+}
+
+func (ma *MatchArm) String() string {
+	patterns := []string{}
+	for _, p := range ma.Patterns {
+		patterns = append(patterns, p.String())
+	}
+	out := strings.Join(patterns, " | ")
+	if ma.Guard != nil {
+		out += " if " + ma.Guard.String()
+	}
+	return out + " => " + ma.Body.String()
+}
+
+// VariantPattern is a match pattern which matches values of one of an enum's
+// variants, e.g. Shape.Rect(w, _). If HasFields is set, its Fields are
+// patterns which must match the value's payload; otherwise, e.g. Shape.Rect,
+// it matches any payload.
+type VariantPattern struct {
+	// The enum's name
+	Token     token.Token
+	Enum      *Identifier
+	Variant   *Identifier
+	Fields    []Expression
+	HasFields bool
+}
+
+func (vp *VariantPattern) expressionNode()      {}
+func (vp *VariantPattern) TokenLiteral() string { return vp.Token.Literal }
+func (vp *VariantPattern) Pos() token.Position  { return vp.Token.Pos }
+func (vp *VariantPattern) String() string {
+	out := vp.Enum.String() + "." + vp.Variant.String()
+	if !vp.HasFields {
+		return out
+	}
+	fields := []string{}
+	for _, f := range vp.Fields {
+		fields = append(fields, f.String())
+	}
+	return out + "(" + strings.Join(fields, ", ") + ")"
+}
+
 type BlockStatement struct {
 	Token      token.Token
 	Statements []Statement
@@ -238,6 +383,7 @@ type BlockStatement struct {
 
 func (bs *BlockStatement) statementNode()       {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position  { return bs.Token.Pos }
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 	for _, s := range bs.Statements {
@@ -248,14 +394,18 @@ func (bs *BlockStatement) String() string {
 
 type FunctionLiteral struct {
 	Token      token.Token
-	Parameters []*Identifier
+	Parameters []*Parameter
 	Body       *BlockStatement
+	// Name is the name the function is bound to, if it's the value of a let
+	// statement, for use in error messages.
+	Name string
 }
 
 func (fl *FunctionLiteral) expressionNode() {}
 func (fl *FunctionLiteral) TokenLiteral() string {
 	return fl.Token.Literal
 }
+func (fl *FunctionLiteral) Pos() token.Position { return fl.Token.Pos }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 	params := []string{}
@@ -272,17 +422,68 @@ func (fl *FunctionLiteral) String() string {
 	return out.String()
 }
 
+// Parameter is a parameter of a function literal: a name, which may have a
+// default value, e.g. b = 10, or be a rest parameter, e.g. ...rest, which
+// collects the remaining arguments into an array.
+type Parameter struct {
+	// The parameter's name, or the '...' token of a rest parameter
+	Token   token.Token
+	Name    *Identifier
+	Default Expression
+	Rest    bool
+}
+
+func (p *Parameter) TokenLiteral() string { return p.Token.Literal }
+func (p *Parameter) Pos() token.Position  { return p.Token.Pos }
+func (p *Parameter) String() string {
+	switch {
+	case p.Rest:
+		return "..." + p.Name.String()
+	case p.Default != nil:
+		return p.Name.String() + " = " + p.Default.String()
+	default:
+		return p.Name.String()
+	}
+}
+
+// MacroLiteral represents a macro definition, e.g. macro(x) { quote(x) }
+// Macros are bound with let statements at the top level of a program, and
+// expanded before it's evaluated.
+type MacroLiteral struct {
+	// The 'macro' token
+	Token      token.Token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (ml *MacroLiteral) expressionNode()      {}
+func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MacroLiteral) Pos() token.Position  { return ml.Token.Pos }
+func (ml *MacroLiteral) String() string {
+	params := []string{}
+	for _, p := range ml.Parameters {
+		params = append(params, p.String())
+	}
+	return ml.Token.Literal + "(" + strings.Join(params, ", ") + ")" +
+		ml.Body.String()
+}
+
 type CallExpression struct {
 	// The '(' token
 	Token     token.Token
 	Function  Expression
 	Arguments []Expression
+	// Tail is set if the call is in tail position within a function body,
+	// i.e. its result is the result of the function. Tail calls can be
+	// evaluated without growing the stack.
+	Tail bool
 }
 
 func (ce *CallExpression) expressionNode() {}
 func (ce *CallExpression) TokenLiteral() string {
 	return ce.Token.Literal
 }
+func (ce *CallExpression) Pos() token.Position { return ce.Token.Pos }
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 	args := []string{}
@@ -295,3 +496,512 @@ func (ce *CallExpression) String() string {
 	out.WriteString(")")
 	return out.String()
 }
+
+// NamedArgument is an argument passed to a parameter by name, e.g. the b: 2
+// in f(a, b: 2)
+type NamedArgument struct {
+	// The parameter name's token
+	Token token.Token
+	Name  *Identifier
+	Value Expression
+}
+
+func (na *NamedArgument) expressionNode()      {}
+func (na *NamedArgument) TokenLiteral() string { return na.Token.Literal }
+func (na *NamedArgument) Pos() token.Position  { return na.Token.Pos }
+func (na *NamedArgument) String() string {
+	return na.Name.String() + ": " + na.Value.String()
+}
+
+// SpreadExpression passes the elements of an array or tuple as separate
+// arguments, e.g. the ...args in f(1, ...args)
+type SpreadExpression struct {
+	// The '...' token
+	Token token.Token
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode()      {}
+func (se *SpreadExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadExpression) Pos() token.Position  { return se.Token.Pos }
+func (se *SpreadExpression) String() string {
+	return "..." + se.Value.String()
+}
+
+// AssignExpression represents assignment to an existing binding.
+// e.g. x = 5 or x += 1
+type AssignExpression struct {
+	// The assignment token, e.g. '=' or '+='
+	Token token.Token
+	// Name of the binding being assigned to
+	Name *Identifier
+	// The assignment operator, '=', '+=', '-=', '*=' or '/='
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode() {}
+func (ae *AssignExpression) TokenLiteral() string {
+	return ae.Token.Literal
+}
+func (ae *AssignExpression) Pos() token.Position { return ae.Token.Pos }
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(ae.Name.String())
+	out.WriteString(" " + ae.Operator + " ")
+	out.WriteString(ae.Value.String())
+	out.WriteString(")")
+	return out.String()
+}
+
+// WhileStatement represents a loop which runs its body for as long as its
+// condition is truthy.
+// e.g. while (x < 10) { x += 1; }
+type WhileStatement struct {
+	Token     token.Token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode()       {}
+func (ws *WhileStatement) TokenLiteral() string { return ws.Token.Literal }
+func (ws *WhileStatement) Pos() token.Position  { return ws.Token.Pos }
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("while")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(ws.Body.String())
+	return out.String()
+}
+
+// ForStatement represents a loop which runs its body once for each item in
+// an iterable value, binding the item to Variable.
+// e.g. for (i in range(10)) { total += i; }
+type ForStatement struct {
+	Token    token.Token
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) statementNode()       {}
+func (fs *ForStatement) TokenLiteral() string { return fs.Token.Literal }
+func (fs *ForStatement) Pos() token.Position  { return fs.Token.Pos }
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("for")
+	out.WriteString("(")
+	out.WriteString(fs.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+	return out.String()
+}
+
+// StructStatement declares a struct type, binding its name to a constructor,
+// e.g. struct Point { x, y }
+type StructStatement struct {
+	// The 'struct' token
+	Token  token.Token
+	Name   *Identifier
+	Fields []*Identifier
+}
+
+func (ss *StructStatement) statementNode()       {}
+func (ss *StructStatement) TokenLiteral() string { return ss.Token.Literal }
+func (ss *StructStatement) Pos() token.Position  { return ss.Token.Pos }
+func (ss *StructStatement) String() string {
+	fields := []string{}
+	for _, f := range ss.Fields {
+		fields = append(fields, f.String())
+	}
+	return "struct " + ss.Name.String() + " {" + strings.Join(fields, ", ") +
+		"}"
+}
+
+// EnumStatement declares an enum type, whose values are each one of its
+// variants, e.g. enum Shape { Circle(r), Rect(w, h), Empty }
+type EnumStatement struct {
+	// The 'enum' token
+	Token    token.Token
+	Name     *Identifier
+	Variants []*EnumVariant
+}
+
+// EnumVariant is a variant of an enum, with the names of the fields of its
+// payload, if it has one.
+type EnumVariant struct {
+	Name   *Identifier
+	Fields []*Identifier
+}
+
+func (es *EnumStatement) statementNode()       {}
+func (es *EnumStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *EnumStatement) Pos() token.Position  { return es.Token.Pos }
+func (es *EnumStatement) String() string {
+	variants := []string{}
+	for _, v := range es.Variants {
+		variants = append(variants, v.String())
+	}
+	return "enum " + es.Name.String() + " {" + strings.Join(variants, ", ") +
+		"}"
+}
+
+func (ev *EnumVariant) String() string {
+	if len(ev.Fields) == 0 {
+		return ev.Name.String()
+	}
+	fields := []string{}
+	for _, f := range ev.Fields {
+		fields = append(fields, f.String())
+	}
+	return ev.Name.String() + "(" + strings.Join(fields, ", ") + ")"
+}
+
+// ImplStatement declares methods of a struct type, e.g.
+// impl Point { fn add(self, other) { Point(self.x + other.x, self.y + other.y) } }
+type ImplStatement struct {
+	// The 'impl' token
+	Token   token.Token
+	Type    *Identifier
+	Methods []*Method
+}
+
+// Method is a method declared by an impl statement. Its Function's first
+// parameter is the value the method is called on.
+type Method struct {
+	Name     *Identifier
+	Function *FunctionLiteral
+}
+
+func (is *ImplStatement) statementNode()       {}
+func (is *ImplStatement) TokenLiteral() string { return is.Token.Literal }
+func (is *ImplStatement) Pos() token.Position  { return is.Token.Pos }
+func (is *ImplStatement) String() string {
+	methods := []string{}
+	for _, m := range is.Methods {
+		methods = append(methods, m.String())
+	}
+	return "impl " + is.Type.String() + " {" + strings.Join(methods, " ") +
+		"}"
+}
+
+func (m *Method) String() string {
+	return "fn " + m.Name.String() +
+		strings.TrimPrefix(m.Function.String(), m.Function.TokenLiteral())
+}
+
+// ThrowStatement raises an exception, which unwinds the program until it's
+// caught by a try expression, e.g. throw "not found";
+type ThrowStatement struct {
+	Token token.Token
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode()       {}
+func (ts *ThrowStatement) TokenLiteral() string { return ts.Token.Literal }
+func (ts *ThrowStatement) Pos() token.Position  { return ts.Token.Pos }
+func (ts *ThrowStatement) String() string {
+	return ts.Token.Literal + " " + ts.Value.String() + ";"
+}
+
+// TryExpression evaluates Body, catching any exception it raises, e.g.
+// try { f() } catch (e) { e.message } finally { cleanup() }
+// If the body raises an exception, the catch block is evaluated with the
+// exception bound to CatchName. Finally, if present, is evaluated however
+// the try expression is left. One or both of Catch and Finally are present.
+type TryExpression struct {
+	// The 'try' token
+	Token     token.Token
+	Body      *BlockStatement
+	CatchName *Identifier
+	Catch     *BlockStatement
+	Finally   *BlockStatement
+}
+
+func (te *TryExpression) expressionNode()      {}
+func (te *TryExpression) TokenLiteral() string { return te.Token.Literal }
+func (te *TryExpression) Pos() token.Position  { return te.Token.Pos }
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("try ")
+	out.WriteString(te.Body.String())
+	if te.Catch != nil {
+		out.WriteString("catch(" + te.CatchName.String() + ") ")
+		out.WriteString(te.Catch.String())
+	}
+	if te.Finally != nil {
+		out.WriteString("finally ")
+		out.WriteString(te.Finally.String())
+	}
+	return out.String()
+}
+
+// BreakStatement exits the innermost enclosing loop.
+type BreakStatement struct {
+	Token token.Token
+}
+
+func (bs *BreakStatement) statementNode()       {}
+func (bs *BreakStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BreakStatement) Pos() token.Position  { return bs.Token.Pos }
+func (bs *BreakStatement) String() string       { return bs.Token.Literal + ";" }
+
+// ContinueStatement skips to the next iteration of the innermost enclosing
+// loop.
+type ContinueStatement struct {
+	Token token.Token
+}
+
+func (cs *ContinueStatement) statementNode()       {}
+func (cs *ContinueStatement) TokenLiteral() string { return cs.Token.Literal }
+func (cs *ContinueStatement) Pos() token.Position  { return cs.Token.Pos }
+func (cs *ContinueStatement) String() string       { return cs.Token.Literal + ";" }
+
+type StringLiteral struct {
+	Token token.Token
+	Value string
+}
+
+func (sl *StringLiteral) expressionNode()      {}
+func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos }
+func (sl *StringLiteral) String() string       { return quote(sl.Value) }
+
+// quoteReplacer escapes the characters the lexer reads escape sequences for.
+var quoteReplacer = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"\n", `\n`,
+	"\t", `\t`,
+	"\r", `\r`,
+)
+
+// quote returns s as a string literal which the lexer reads back as s.
+func quote(s string) string {
+	return `"` + quoteReplacer.Replace(s) + `"`
+}
+
+// ArrayLiteral represents an array literal, e.g. [1, 2 * 2, x]
+type ArrayLiteral struct {
+	// The '[' token
+	Token    token.Token
+	Elements []Expression
+}
+
+func (al *ArrayLiteral) expressionNode()      {}
+func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos }
+func (al *ArrayLiteral) String() string {
+	var out bytes.Buffer
+	elements := []string{}
+	for _, el := range al.Elements {
+		elements = append(elements, el.String())
+	}
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+	return out.String()
+}
+
+// TupleLiteral represents a tuple literal, e.g. (1, "two")
+// A function returns several values by returning a tuple.
+type TupleLiteral struct {
+	// The '(' token
+	Token    token.Token
+	Elements []Expression
+}
+
+func (tl *TupleLiteral) expressionNode()      {}
+func (tl *TupleLiteral) TokenLiteral() string { return tl.Token.Literal }
+func (tl *TupleLiteral) Pos() token.Position  { return tl.Token.Pos }
+func (tl *TupleLiteral) String() string {
+	elements := []string{}
+	for _, el := range tl.Elements {
+		elements = append(elements, el.String())
+	}
+	return "(" + strings.Join(elements, ", ") + ")"
+}
+
+// ArrayPattern destructures an array or tuple in a let statement, e.g. the
+// [a, b, ...rest] in let [a, b, ...rest] = xs;
+type ArrayPattern struct {
+	// The '[' token
+	Token token.Token
+	// Each element is an Identifier, or a nested ArrayPattern or HashPattern.
+	Elements []Expression
+	// Rest, if set, is bound to an array of the elements after Elements.
+	Rest *Identifier
+}
+
+func (ap *ArrayPattern) expressionNode()      {}
+func (ap *ArrayPattern) TokenLiteral() string { return ap.Token.Literal }
+func (ap *ArrayPattern) Pos() token.Position  { return ap.Token.Pos }
+func (ap *ArrayPattern) String() string {
+	elements := []string{}
+	for _, el := range ap.Elements {
+		elements = append(elements, el.String())
+	}
+	if ap.Rest != nil {
+		elements = append(elements, "..."+ap.Rest.String())
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// HashPattern destructures a hash, or an object with members such as a
+// module, in a let statement, e.g. the {x, y: alias} in
+// let {x, y: alias} = point;
+type HashPattern struct {
+	// The '{' token
+	Token token.Token
+	Pairs []HashPatternPair
+}
+
+// HashPatternPair binds the value of the string key Key to Value, which is an
+// Identifier or a nested ArrayPattern or HashPattern. The shorthand {x} is
+// parsed as {x: x}.
+type HashPatternPair struct {
+	Key   *Identifier
+	Value Expression
+}
+
+func (hp *HashPattern) expressionNode()      {}
+func (hp *HashPattern) TokenLiteral() string { return hp.Token.Literal }
+func (hp *HashPattern) Pos() token.Position  { return hp.Token.Pos }
+func (hp *HashPattern) String() string {
+	pairs := []string{}
+	for _, pair := range hp.Pairs {
+		if ident, ok := pair.Value.(*Identifier); ok &&
+			ident.Value == pair.Key.Value {
+			pairs = append(pairs, pair.Key.String())
+			continue
+		}
+		pairs = append(pairs, pair.Key.String()+": "+pair.Value.String())
+	}
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// IndexExpression represents indexing into a value, e.g. myArray[1]
+type IndexExpression struct {
+	// The '[' token
+	Token token.Token
+	Left  Expression
+	Index Expression
+}
+
+func (ie *IndexExpression) expressionNode()      {}
+func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position  { return ie.Token.Pos }
+func (ie *IndexExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(ie.Left.String())
+	out.WriteString("[")
+	out.WriteString(ie.Index.String())
+	out.WriteString("])")
+	return out.String()
+}
+
+// PropagateExpression unwraps an ok result, or returns an err result from
+// the enclosing function, e.g. the read(path)? in let text = read(path)?;
+type PropagateExpression struct {
+	// The '?' token
+	Token token.Token
+	Value Expression
+}
+
+func (pe *PropagateExpression) expressionNode()      {}
+func (pe *PropagateExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PropagateExpression) Pos() token.Position  { return pe.Token.Pos }
+func (pe *PropagateExpression) String() string {
+	return "(" + pe.Value.String() + "?)"
+}
+
+// WithExpression copies a struct, with some of its fields changed, e.g.
+// p with { x: 3 }
+type WithExpression struct {
+	// The 'with' token
+	Token  token.Token
+	Struct Expression
+	Fields []WithField
+}
+
+// WithField sets the field Name to Value in a with expression.
+type WithField struct {
+	Name  *Identifier
+	Value Expression
+}
+
+func (we *WithExpression) expressionNode()      {}
+func (we *WithExpression) TokenLiteral() string { return we.Token.Literal }
+func (we *WithExpression) Pos() token.Position  { return we.Token.Pos }
+func (we *WithExpression) String() string {
+	fields := []string{}
+	for _, f := range we.Fields {
+		fields = append(fields, f.Name.String()+": "+f.Value.String())
+	}
+	return "(" + we.Struct.String() + " with {" + strings.Join(fields, ", ") +
+		"})"
+}
+
+// MemberExpression represents access to a named member of a value,
+// e.g. point.x
+type MemberExpression struct {
+	// The '.' token
+	Token  token.Token
+	Object Expression
+	Member *Identifier
+}
+
+func (me *MemberExpression) expressionNode()      {}
+func (me *MemberExpression) TokenLiteral() string { return me.Token.Literal }
+func (me *MemberExpression) Pos() token.Position  { return me.Token.Pos }
+func (me *MemberExpression) String() string {
+	return "(" + me.Object.String() + "." + me.Member.String() + ")"
+}
+
+// ImportExpression represents the import of a module, e.g. import "lib/list"
+type ImportExpression struct {
+	// The 'import' token
+	Token token.Token
+	Path  string
+}
+
+func (ie *ImportExpression) expressionNode()      {}
+func (ie *ImportExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *ImportExpression) Pos() token.Position  { return ie.Token.Pos }
+func (ie *ImportExpression) String() string {
+	return ie.TokenLiteral() + " " + quote(ie.Path)
+}
+
+// HashPair is a single key: value entry in a HashLiteral.
+type HashPair struct {
+	Key   Expression
+	Value Expression
+}
+
+// HashLiteral represents a hash literal, e.g. {"one": 1, "two": 2}
+// Pairs are kept in source order.
+type HashLiteral struct {
+	// The '{' token
+	Token token.Token
+	Pairs []HashPair
+}
+
+func (hl *HashLiteral) expressionNode()      {}
+func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Pos }
+func (hl *HashLiteral) String() string {
+	var out bytes.Buffer
+	pairs := []string{}
+	for _, pair := range hl.Pairs {
+		pairs = append(pairs, pair.Key.String()+": "+pair.Value.String())
+	}
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+	return out.String()
+}
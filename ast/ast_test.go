@@ -1,8 +1,10 @@
 package ast
 
 import (
-	"github.com/jamesroutley/monkey/token"
+	"strings"
 	"testing"
+
+	"github.com/jamesroutley/monkey/token"
 )
 
 func TestString(t *testing.T) {
@@ -35,3 +37,55 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got %q", program.String())
 	}
 }
+
+func TestInspect(t *testing.T) {
+	// if (x) { y } else { z }
+	ident := func(name string) *Identifier {
+		return &Identifier{
+			Token: token.Token{Type: token.IDENT, Literal: name},
+			Value: name,
+		}
+	}
+	block := func(name string) *BlockStatement {
+		return &BlockStatement{
+			Statements: []Statement{
+				&ExpressionStatement{Expression: ident(name)},
+			},
+		}
+	}
+	program := &Program{
+		Statements: []Statement{
+			&ExpressionStatement{
+				Expression: &IfExpression{
+					Condition:   ident("x"),
+					Consequence: block("y"),
+					Alternative: block("z"),
+				},
+			},
+		},
+	}
+
+	var visited []string
+	Inspect(program, func(node Node) bool {
+		if ident, ok := node.(*Identifier); ok {
+			visited = append(visited, ident.Value)
+		}
+		// Don't descend into the else branch.
+		return node != program.Statements[0].(*ExpressionStatement).
+			Expression.(*IfExpression).Alternative
+	})
+
+	if strings.Join(visited, ",") != "x,y" {
+		t.Errorf("wrong identifiers visited. got %v", visited)
+	}
+
+	// A missing else branch is skipped rather than visited as a nil node.
+	program.Statements[0].(*ExpressionStatement).
+		Expression.(*IfExpression).Alternative = nil
+	Inspect(program, func(node Node) bool {
+		if node == nil {
+			t.Errorf("visited nil node")
+		}
+		return true
+	})
+}
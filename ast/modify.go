@@ -0,0 +1,303 @@
+package ast
+
+// ModifierFunc is called by Modify with each node of an AST, and returns the
+// node to replace it with.
+type ModifierFunc func(Node) Node
+
+// Modify returns a copy of the AST rooted at node, in which each node has
+// been replaced by the result of calling modifier on it. Nodes are modified
+// depth first, so modifier is passed a node after its children have been
+// replaced. The original AST isn't changed, so modifier must return a new
+// node rather than changing the one it's passed.
+//
+// A child which modifier replaces with a node of the wrong kind, e.g. a
+// statement where an expression is needed, is left as it was.
+func Modify(node Node, modifier ModifierFunc) Node {
+	if isNilNode(node) {
+		return node
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		copied := *node
+		copied.Statements = modifyStatements(node.Statements, modifier)
+		return modifier(&copied)
+	case *LetStatement:
+		copied := *node
+		copied.Name = modifyIdentifier(node.Name, modifier)
+		copied.Pattern = modifyExpression(node.Pattern, modifier)
+		copied.Value = modifyExpression(node.Value, modifier)
+		return modifier(&copied)
+	case *ReturnStatement:
+		copied := *node
+		copied.ReturnValue = modifyExpression(node.ReturnValue, modifier)
+		return modifier(&copied)
+	case *ExpressionStatement:
+		copied := *node
+		copied.Expression = modifyExpression(node.Expression, modifier)
+		return modifier(&copied)
+	case *BlockStatement:
+		return modifier(modifyBlock(node, modifier))
+	case *WhileStatement:
+		copied := *node
+		copied.Condition = modifyExpression(node.Condition, modifier)
+		copied.Body = modifyBlock(node.Body, modifier)
+		return modifier(&copied)
+	case *ForStatement:
+		copied := *node
+		copied.Variable = modifyIdentifier(node.Variable, modifier)
+		copied.Iterable = modifyExpression(node.Iterable, modifier)
+		copied.Body = modifyBlock(node.Body, modifier)
+		return modifier(&copied)
+	case *StructStatement:
+		copied := *node
+		copied.Name = modifyIdentifier(node.Name, modifier)
+		copied.Fields = modifyIdentifiers(node.Fields, modifier)
+		return modifier(&copied)
+	case *EnumStatement:
+		copied := *node
+		copied.Name = modifyIdentifier(node.Name, modifier)
+		copied.Variants = make([]*EnumVariant, len(node.Variants))
+		for i, v := range node.Variants {
+			copied.Variants[i] = &EnumVariant{
+				Name:   modifyIdentifier(v.Name, modifier),
+				Fields: modifyIdentifiers(v.Fields, modifier),
+			}
+		}
+		return modifier(&copied)
+	case *ImplStatement:
+		copied := *node
+		copied.Type = modifyIdentifier(node.Type, modifier)
+		copied.Methods = make([]*Method, len(node.Methods))
+		for i, m := range node.Methods {
+			copied.Methods[i] = &Method{
+				Name:     modifyIdentifier(m.Name, modifier),
+				Function: m.Function,
+			}
+			if fn, ok := Modify(m.Function, modifier).(*FunctionLiteral); ok {
+				copied.Methods[i].Function = fn
+			}
+		}
+		return modifier(&copied)
+	case *ThrowStatement:
+		copied := *node
+		copied.Value = modifyExpression(node.Value, modifier)
+		return modifier(&copied)
+	case *TryExpression:
+		copied := *node
+		copied.Body = modifyBlock(node.Body, modifier)
+		copied.CatchName = modifyIdentifier(node.CatchName, modifier)
+		copied.Catch = modifyBlock(node.Catch, modifier)
+		copied.Finally = modifyBlock(node.Finally, modifier)
+		return modifier(&copied)
+	case *PrefixExpression:
+		copied := *node
+		copied.Right = modifyExpression(node.Right, modifier)
+		return modifier(&copied)
+	case *InfixExpression:
+		copied := *node
+		copied.Left = modifyExpression(node.Left, modifier)
+		copied.Right = modifyExpression(node.Right, modifier)
+		return modifier(&copied)
+	case *AssignExpression:
+		copied := *node
+		copied.Name = modifyIdentifier(node.Name, modifier)
+		copied.Value = modifyExpression(node.Value, modifier)
+		return modifier(&copied)
+	case *IfExpression:
+		copied := *node
+		copied.Condition = modifyExpression(node.Condition, modifier)
+		copied.Consequence = modifyBlock(node.Consequence, modifier)
+		copied.Alternative = modifyBlock(node.Alternative, modifier)
+		return modifier(&copied)
+	case *SwitchExpression:
+		copied := *node
+		copied.Value = modifyExpression(node.Value, modifier)
+		copied.Cases = make([]*SwitchCase, len(node.Cases))
+		for i, c := range node.Cases {
+			copied.Cases[i] = &SwitchCase{
+				Token:  c.Token,
+				Values: modifyExpressions(c.Values, modifier),
+				Body:   modifyBlock(c.Body, modifier),
+			}
+		}
+		copied.Default = modifyBlock(node.Default, modifier)
+		return modifier(&copied)
+	case *MatchExpression:
+		copied := *node
+		copied.Value = modifyExpression(node.Value, modifier)
+		copied.Arms = make([]*MatchArm, len(node.Arms))
+		for i, arm := range node.Arms {
+			copied.Arms[i] = &MatchArm{
+				Patterns: modifyExpressions(arm.Patterns, modifier),
+				Guard:    modifyExpression(arm.Guard, modifier),
+				Body:     modifyExpression(arm.Body, modifier),
+			}
+		}
+		return modifier(&copied)
+	case *VariantPattern:
+		copied := *node
+		copied.Enum = modifyIdentifier(node.Enum, modifier)
+		copied.Variant = modifyIdentifier(node.Variant, modifier)
+		copied.Fields = modifyExpressions(node.Fields, modifier)
+		return modifier(&copied)
+	case *FunctionLiteral:
+		copied := *node
+		copied.Parameters = modifyParameters(node.Parameters, modifier)
+		copied.Body = modifyBlock(node.Body, modifier)
+		return modifier(&copied)
+	case *MacroLiteral:
+		copied := *node
+		copied.Parameters = modifyIdentifiers(node.Parameters, modifier)
+		copied.Body = modifyBlock(node.Body, modifier)
+		return modifier(&copied)
+	case *CallExpression:
+		copied := *node
+		copied.Function = modifyExpression(node.Function, modifier)
+		copied.Arguments = modifyExpressions(node.Arguments, modifier)
+		return modifier(&copied)
+	case *NamedArgument:
+		copied := *node
+		copied.Name = modifyIdentifier(node.Name, modifier)
+		copied.Value = modifyExpression(node.Value, modifier)
+		return modifier(&copied)
+	case *SpreadExpression:
+		copied := *node
+		copied.Value = modifyExpression(node.Value, modifier)
+		return modifier(&copied)
+	case *ArrayLiteral:
+		copied := *node
+		copied.Elements = modifyExpressions(node.Elements, modifier)
+		return modifier(&copied)
+	case *TupleLiteral:
+		copied := *node
+		copied.Elements = modifyExpressions(node.Elements, modifier)
+		return modifier(&copied)
+	case *ArrayPattern:
+		copied := *node
+		copied.Elements = modifyExpressions(node.Elements, modifier)
+		copied.Rest = modifyIdentifier(node.Rest, modifier)
+		return modifier(&copied)
+	case *HashPattern:
+		copied := *node
+		copied.Pairs = make([]HashPatternPair, len(node.Pairs))
+		for i, pair := range node.Pairs {
+			copied.Pairs[i] = HashPatternPair{
+				Key:   modifyIdentifier(pair.Key, modifier),
+				Value: modifyExpression(pair.Value, modifier),
+			}
+		}
+		return modifier(&copied)
+	case *IndexExpression:
+		copied := *node
+		copied.Left = modifyExpression(node.Left, modifier)
+		copied.Index = modifyExpression(node.Index, modifier)
+		return modifier(&copied)
+	case *PropagateExpression:
+		copied := *node
+		copied.Value = modifyExpression(node.Value, modifier)
+		return modifier(&copied)
+	case *WithExpression:
+		copied := *node
+		copied.Struct = modifyExpression(node.Struct, modifier)
+		copied.Fields = make([]WithField, len(node.Fields))
+		for i, field := range node.Fields {
+			copied.Fields[i] = WithField{
+				Name:  modifyIdentifier(field.Name, modifier),
+				Value: modifyExpression(field.Value, modifier),
+			}
+		}
+		return modifier(&copied)
+	case *MemberExpression:
+		copied := *node
+		copied.Object = modifyExpression(node.Object, modifier)
+		copied.Member = modifyIdentifier(node.Member, modifier)
+		return modifier(&copied)
+	case *HashLiteral:
+		copied := *node
+		copied.Pairs = make([]HashPair, len(node.Pairs))
+		for i, pair := range node.Pairs {
+			copied.Pairs[i] = HashPair{
+				Key:   modifyExpression(pair.Key, modifier),
+				Value: modifyExpression(pair.Value, modifier),
+			}
+		}
+		return modifier(&copied)
+	default:
+		// Nodes without children, like literals and identifiers.
+		return modifier(node)
+	}
+}
+
+func modifyStatements(stmts []Statement, modifier ModifierFunc) []Statement {
+	modified := make([]Statement, len(stmts))
+	for i, stmt := range stmts {
+		modified[i] = stmt
+		if stmt, ok := Modify(stmt, modifier).(Statement); ok {
+			modified[i] = stmt
+		}
+	}
+	return modified
+}
+
+func modifyExpressions(exps []Expression, modifier ModifierFunc) []Expression {
+	modified := make([]Expression, len(exps))
+	for i, exp := range exps {
+		modified[i] = modifyExpression(exp, modifier)
+	}
+	return modified
+}
+
+func modifyIdentifiers(idents []*Identifier, modifier ModifierFunc) []*Identifier {
+	modified := make([]*Identifier, len(idents))
+	for i, ident := range idents {
+		modified[i] = modifyIdentifier(ident, modifier)
+	}
+	return modified
+}
+
+// modifyParameters returns copies of params with their names and default
+// values modified. Like blocks, parameters themselves aren't passed to
+// modifier.
+func modifyParameters(params []*Parameter, modifier ModifierFunc) []*Parameter {
+	modified := make([]*Parameter, len(params))
+	for i, param := range params {
+		copied := *param
+		copied.Name = modifyIdentifier(param.Name, modifier)
+		copied.Default = modifyExpression(param.Default, modifier)
+		modified[i] = &copied
+	}
+	return modified
+}
+
+func modifyExpression(exp Expression, modifier ModifierFunc) Expression {
+	if isNilNode(exp) {
+		return exp
+	}
+	if modified, ok := Modify(exp, modifier).(Expression); ok {
+		return modified
+	}
+	return exp
+}
+
+func modifyIdentifier(ident *Identifier, modifier ModifierFunc) *Identifier {
+	if ident == nil {
+		return nil
+	}
+	if modified, ok := Modify(ident, modifier).(*Identifier); ok {
+		return modified
+	}
+	return ident
+}
+
+// modifyBlock returns a copy of block with its statements modified. Unlike
+// the other helpers, it doesn't pass block itself to modifier, as a block
+// can only be replaced by another block.
+func modifyBlock(block *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	if block == nil {
+		return nil
+	}
+	copied := *block
+	copied.Statements = modifyStatements(block.Statements, modifier)
+	return &copied
+}
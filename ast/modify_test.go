@@ -0,0 +1,176 @@
+package ast
+
+import (
+	"testing"
+
+	"github.com/jamesroutley/monkey/token"
+)
+
+func TestModify(t *testing.T) {
+	one := func() Expression {
+		return &IntegerLiteral{
+			Token: token.Token{Type: token.INT, Literal: "1"},
+			Value: 1,
+		}
+	}
+	two := func() Expression {
+		return &IntegerLiteral{
+			Token: token.Token{Type: token.INT, Literal: "2"},
+			Value: 2,
+		}
+	}
+	block := func(exp Expression) *BlockStatement {
+		return &BlockStatement{
+			Statements: []Statement{&ExpressionStatement{Expression: exp}},
+		}
+	}
+	ident := func(name string) *Identifier {
+		return &Identifier{
+			Token: token.Token{Type: token.IDENT, Literal: name},
+			Value: name,
+		}
+	}
+
+	turnOneIntoTwo := func(node Node) Node {
+		integer, ok := node.(*IntegerLiteral)
+		if !ok || integer.Value != 1 {
+			return node
+		}
+		return two()
+	}
+
+	tests := []struct {
+		input    Node
+		expected string
+	}{
+		{one(), "2"},
+		{&Program{Statements: []Statement{
+			&ExpressionStatement{Expression: one()},
+		}}, "2"},
+		{&InfixExpression{Left: one(), Operator: "+", Right: two()}, "(2 + 2)"},
+		{&InfixExpression{Left: two(), Operator: "+", Right: one()}, "(2 + 2)"},
+		{&PrefixExpression{Operator: "-", Right: one()}, "(-2)"},
+		{&IndexExpression{Left: one(), Index: one()}, "(2[2])"},
+		{&MemberExpression{Object: one(), Member: ident("x")}, "(2.x)"},
+		{&IfExpression{Condition: one(), Consequence: block(one()),
+			Alternative: block(one())}, "if2 2else 2"},
+		{&ReturnStatement{
+			Token:       token.Token{Type: token.RETURN, Literal: "return"},
+			ReturnValue: one(),
+		}, "return 2;"},
+		{&LetStatement{
+			Token: token.Token{Type: token.LET, Literal: "let"},
+			Name:  ident("x"),
+			Value: one(),
+		}, "let x = 2;"},
+		{&LetStatement{
+			Token: token.Token{Type: token.LET, Literal: "let"},
+			Pattern: &ArrayPattern{
+				Elements: []Expression{ident("a"), &HashPattern{
+					Pairs: []HashPatternPair{{Key: ident("b"), Value: ident("b")}},
+				}},
+				Rest: ident("c"),
+			},
+			Value: one(),
+		}, "let [a, {b}, ...c] = 2;"},
+		{&PropagateExpression{Value: one()}, "(2?)"},
+		{&TupleLiteral{Elements: []Expression{one(), two()}}, "(2, 2)"},
+		{&AssignExpression{Name: ident("x"), Operator: "=", Value: one()},
+			"(x = 2)"},
+		{&MatchExpression{Value: one(), Arms: []*MatchArm{
+			{Patterns: []Expression{one(), two()}, Guard: one(), Body: one()},
+			{Patterns: []Expression{ident("_")}, Body: one()},
+		}}, "match(2) {2 | 2 if 2 => 2, _ => 2}"},
+		{&SwitchExpression{Value: one(), Cases: []*SwitchCase{
+			{Values: []Expression{one(), two()}, Body: block(one())},
+		}, Default: block(one())}, "switch(2) {case 2, 2: 2 default: 2}"},
+		{&StructStatement{Token: token.Token{Type: token.STRUCT, Literal: "struct"},
+			Name: ident("P"), Fields: []*Identifier{ident("x")}}, "struct P {x}"},
+		{&EnumStatement{Token: token.Token{Type: token.ENUM, Literal: "enum"},
+			Name: ident("E"), Variants: []*EnumVariant{
+				{Name: ident("A"), Fields: []*Identifier{ident("x")}},
+				{Name: ident("B")},
+			}}, "enum E {A(x), B}"},
+		{&MatchExpression{Value: one(), Arms: []*MatchArm{{
+			Patterns: []Expression{&VariantPattern{Enum: ident("E"),
+				Variant: ident("A"), Fields: []Expression{one()}, HasFields: true}},
+			Body: one(),
+		}}}, "match(2) {E.A(2) => 2}"},
+		{&ImplStatement{Type: ident("P"), Methods: []*Method{{
+			Name: ident("m"),
+			Function: &FunctionLiteral{
+				Token:      token.Token{Type: token.FUNCTION, Literal: "fn"},
+				Parameters: []*Parameter{{Name: ident("self")}},
+				Body:       block(one()),
+			},
+		}}}, "impl P {fn m(self)2}"},
+		{&WithExpression{Struct: one(), Fields: []WithField{
+			{Name: ident("x"), Value: one()},
+		}}, "(2 with {x: 2})"},
+		{&ThrowStatement{Token: token.Token{Type: token.THROW, Literal: "throw"},
+			Value: one()}, "throw 2;"},
+		{&TryExpression{Body: block(one()), CatchName: ident("e"),
+			Catch: block(one()), Finally: block(one())},
+			"try 2catch(e) 2finally 2"},
+		{&WhileStatement{Condition: one(), Body: block(one())}, "while2 2"},
+		{&ForStatement{Variable: ident("x"), Iterable: one(),
+			Body: block(one())}, "for(x in 2) 2"},
+		{&FunctionLiteral{
+			Token: token.Token{Type: token.FUNCTION, Literal: "fn"},
+			Parameters: []*Parameter{
+				{Name: ident("x")},
+				{Name: ident("y"), Default: one()},
+				{Name: ident("z"), Rest: true},
+			},
+			Body: block(one()),
+		}, "fn(x, y = 2, ...z)2"},
+		{&MacroLiteral{
+			Token:      token.Token{Type: token.MACRO, Literal: "macro"},
+			Parameters: []*Identifier{ident("x")},
+			Body:       block(one()),
+		}, "macro(x)2"},
+		{&CallExpression{Function: ident("f"), Arguments: []Expression{
+			one(), two(), one(),
+		}}, "f(2, 2, 2)"},
+		{&CallExpression{Function: ident("f"), Arguments: []Expression{
+			&SpreadExpression{Value: one()},
+			&NamedArgument{Name: ident("x"), Value: one()},
+		}}, "f(...2, x: 2)"},
+		{&ArrayLiteral{Elements: []Expression{one(), one()}}, "[2, 2]"},
+		{&HashLiteral{Pairs: []HashPair{
+			{Key: one(), Value: one()},
+		}}, "{2: 2}"},
+	}
+
+	for _, tt := range tests {
+		before := tt.input.String()
+		modified := Modify(tt.input, turnOneIntoTwo)
+		if modified.String() != tt.expected {
+			t.Errorf("expected %q. got %q", tt.expected, modified.String())
+		}
+		if tt.input.String() != before {
+			t.Errorf("Modify changed its input from %q to %q", before,
+				tt.input.String())
+		}
+	}
+}
+
+func TestModifyKeepsNodesOfTheRightKind(t *testing.T) {
+	// Replacing an expression with a statement would break the AST.
+	infix := &InfixExpression{
+		Left:     &Identifier{Value: "a"},
+		Operator: "+",
+		Right:    &Identifier{Value: "b"},
+	}
+	modified := Modify(infix, func(node Node) Node {
+		if ident, ok := node.(*Identifier); ok && ident.Value == "a" {
+			return &BreakStatement{
+				Token: token.Token{Type: token.BREAK, Literal: "break"},
+			}
+		}
+		return node
+	})
+	if modified.String() != "(a + b)" {
+		t.Errorf("expected %q. got %q", "(a + b)", modified.String())
+	}
+}
@@ -0,0 +1,172 @@
+package ast
+
+import (
+	"reflect"
+)
+
+// Inspect traverses an AST in depth-first order. It calls f(node) for each
+// node; if f returns true, Inspect then visits each of node's children.
+// Nil children are skipped.
+func Inspect(node Node, f func(Node) bool) {
+	if isNilNode(node) || !f(node) {
+		return
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		for _, s := range node.Statements {
+			Inspect(s, f)
+		}
+	case *LetStatement:
+		Inspect(node.Name, f)
+		Inspect(node.Pattern, f)
+		Inspect(node.Value, f)
+	case *ReturnStatement:
+		Inspect(node.ReturnValue, f)
+	case *ExpressionStatement:
+		Inspect(node.Expression, f)
+	case *BlockStatement:
+		for _, s := range node.Statements {
+			Inspect(s, f)
+		}
+	case *WhileStatement:
+		Inspect(node.Condition, f)
+		Inspect(node.Body, f)
+	case *ForStatement:
+		Inspect(node.Variable, f)
+		Inspect(node.Iterable, f)
+		Inspect(node.Body, f)
+	case *StructStatement:
+		Inspect(node.Name, f)
+		for _, field := range node.Fields {
+			Inspect(field, f)
+		}
+	case *EnumStatement:
+		Inspect(node.Name, f)
+		for _, v := range node.Variants {
+			Inspect(v.Name, f)
+			for _, field := range v.Fields {
+				Inspect(field, f)
+			}
+		}
+	case *ImplStatement:
+		Inspect(node.Type, f)
+		for _, m := range node.Methods {
+			Inspect(m.Name, f)
+			Inspect(m.Function, f)
+		}
+	case *ThrowStatement:
+		Inspect(node.Value, f)
+	case *TryExpression:
+		Inspect(node.Body, f)
+		Inspect(node.CatchName, f)
+		Inspect(node.Catch, f)
+		Inspect(node.Finally, f)
+	case *PrefixExpression:
+		Inspect(node.Right, f)
+	case *InfixExpression:
+		Inspect(node.Left, f)
+		Inspect(node.Right, f)
+	case *AssignExpression:
+		Inspect(node.Name, f)
+		Inspect(node.Value, f)
+	case *IfExpression:
+		Inspect(node.Condition, f)
+		Inspect(node.Consequence, f)
+		Inspect(node.Alternative, f)
+	case *SwitchExpression:
+		Inspect(node.Value, f)
+		for _, c := range node.Cases {
+			for _, v := range c.Values {
+				Inspect(v, f)
+			}
+			Inspect(c.Body, f)
+		}
+		Inspect(node.Default, f)
+	case *MatchExpression:
+		Inspect(node.Value, f)
+		for _, arm := range node.Arms {
+			for _, p := range arm.Patterns {
+				Inspect(p, f)
+			}
+			Inspect(arm.Guard, f)
+			Inspect(arm.Body, f)
+		}
+	case *VariantPattern:
+		Inspect(node.Enum, f)
+		Inspect(node.Variant, f)
+		for _, field := range node.Fields {
+			Inspect(field, f)
+		}
+	case *FunctionLiteral:
+		for _, p := range node.Parameters {
+			Inspect(p, f)
+		}
+		Inspect(node.Body, f)
+	case *Parameter:
+		Inspect(node.Name, f)
+		Inspect(node.Default, f)
+	case *MacroLiteral:
+		for _, p := range node.Parameters {
+			Inspect(p, f)
+		}
+		Inspect(node.Body, f)
+	case *CallExpression:
+		Inspect(node.Function, f)
+		for _, a := range node.Arguments {
+			Inspect(a, f)
+		}
+	case *NamedArgument:
+		Inspect(node.Name, f)
+		Inspect(node.Value, f)
+	case *SpreadExpression:
+		Inspect(node.Value, f)
+	case *ArrayLiteral:
+		for _, el := range node.Elements {
+			Inspect(el, f)
+		}
+	case *TupleLiteral:
+		for _, el := range node.Elements {
+			Inspect(el, f)
+		}
+	case *ArrayPattern:
+		for _, el := range node.Elements {
+			Inspect(el, f)
+		}
+		Inspect(node.Rest, f)
+	case *HashPattern:
+		for _, pair := range node.Pairs {
+			Inspect(pair.Key, f)
+			Inspect(pair.Value, f)
+		}
+	case *IndexExpression:
+		Inspect(node.Left, f)
+		Inspect(node.Index, f)
+	case *PropagateExpression:
+		Inspect(node.Value, f)
+	case *WithExpression:
+		Inspect(node.Struct, f)
+		for _, field := range node.Fields {
+			Inspect(field.Name, f)
+			Inspect(field.Value, f)
+		}
+	case *MemberExpression:
+		Inspect(node.Object, f)
+		Inspect(node.Member, f)
+	case *HashLiteral:
+		for _, pair := range node.Pairs {
+			Inspect(pair.Key, f)
+			Inspect(pair.Value, f)
+		}
+	}
+}
+
+// isNilNode reports whether node is nil, including a nil pointer wrapped in
+// the Node interface, such as an IfExpression's missing Alternative.
+func isNilNode(node Node) bool {
+	if node == nil {
+		return true
+	}
+	v := reflect.ValueOf(node)
+	return v.Kind() == reflect.Ptr && v.IsNil()
+}
@@ -0,0 +1,270 @@
+package evaluator
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jamesroutley/monkey/object"
+)
+
+// builtins are the functions available in every Monkey program. They're
+// looked up after the environment, so user bindings can shadow them.
+var builtins = map[string]*object.Builtin{
+	"is_ok":     {Name: "is_ok", Fn: isOkBuiltin},
+	"unwrap":    {Name: "unwrap", Fn: unwrapBuiltin},
+	"unwrap_or": {Name: "unwrap_or", Fn: unwrapOrBuiltin},
+}
+
+// newBuiltins returns the builtins available to programs run by e: the
+// shared builtins, plus those which depend on e's Options.
+func (e *Evaluator) newBuiltins() map[string]*object.Builtin {
+	b := make(map[string]*object.Builtin, len(builtins)+6)
+	for name, builtin := range builtins {
+		b[name] = builtin
+	}
+
+	stdout := e.opts.Stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	b["puts"] = &object.Builtin{
+		Name:        "puts",
+		Fn:          e.putsBuiltin(stdout),
+		SideEffects: true,
+	}
+	b["ok"] = &object.Builtin{Name: "ok", Fn: e.okBuiltin}
+	b["err"] = &object.Builtin{Name: "err", Fn: e.errBuiltin}
+	b["map_err"] = &object.Builtin{Name: "map_err", Fn: e.mapErrBuiltin}
+	b["len"] = &object.Builtin{Name: "len", Fn: e.lenMethodBuiltin}
+	b["range"] = &object.Builtin{Name: "range", Fn: e.rangeBuiltin}
+	b["str"] = &object.Builtin{Name: "str", Fn: e.strBuiltin}
+	return b
+}
+
+// putsBuiltin returns a builtin which writes each of its arguments to out,
+// converted to strings as by str, separated by spaces and followed by a
+// newline.
+func (e *Evaluator) putsBuiltin(out io.Writer) object.BuiltinFunction {
+	return func(args ...object.Object) object.Object {
+		strs := make([]string, len(args))
+		for i, arg := range args {
+			str := e.toString(arg)
+			if isError(str) {
+				return str
+			}
+			strs[i] = str.(*object.String).Value
+		}
+		fmt.Fprintln(out, strings.Join(strs, " "))
+		return NULL
+	}
+}
+
+// strBuiltin converts its argument to a string. Values of types with a str
+// method are converted by calling it.
+func (e *Evaluator) strBuiltin(args ...object.Object) object.Object {
+	if err := checkArgCount("str", args, 1, 1); err != nil {
+		return err
+	}
+	return e.toString(args[0])
+}
+
+// lenMethodBuiltin extends lenBuiltin to values of types with a len method.
+func (e *Evaluator) lenMethodBuiltin(args ...object.Object) object.Object {
+	if len(args) == 1 {
+		if method, ok := methodOf(args[0], "len"); ok {
+			return e.callMethod(method, args[0])
+		}
+		if hint := missingMethod(args[0], "len"); hint != "" {
+			return newError("argument to `len` not supported, got %s%s",
+				object.TypeName(args[0]), hint)
+		}
+	}
+	return e.lenBuiltin(args...)
+}
+
+// lenBuiltin returns the number of characters in a string, elements in an
+// array or entries in a hash.
+func (e *Evaluator) lenBuiltin(args ...object.Object) object.Object {
+	if err := checkArgCount("len", args, 1, 1); err != nil {
+		return err
+	}
+	var n int
+	switch arg := args[0].(type) {
+	case *object.String:
+		n = utf8.RuneCountInString(arg.Value)
+	case *object.Array:
+		n = len(arg.Elements)
+	case *object.Tuple:
+		n = len(arg.Elements)
+	case *object.Hash:
+		n = len(arg.Keys)
+	default:
+		return newError("argument to `len` not supported, got %s",
+			object.TypeName(args[0]))
+	}
+	return e.alloc(&object.Integer{Value: int64(n)})
+}
+
+// rangeBuiltin implements range(stop), range(start, stop) and
+// range(start, stop, step).
+func (e *Evaluator) rangeBuiltin(args ...object.Object) object.Object {
+	values, err := integerArgs("range", args, 1, 3)
+	if err != nil {
+		return err
+	}
+
+	r := &object.Range{Start: 0, Step: 1}
+	switch len(values) {
+	case 1:
+		r.Stop = values[0]
+	case 2:
+		r.Start, r.Stop = values[0], values[1]
+	case 3:
+		r.Start, r.Stop, r.Step = values[0], values[1], values[2]
+	}
+	if r.Step == 0 {
+		return newError("`range` step must not be zero")
+	}
+	return e.alloc(r)
+}
+
+// okBuiltin returns a successful result holding its argument.
+func (e *Evaluator) okBuiltin(args ...object.Object) object.Object {
+	if err := checkArgCount("ok", args, 1, 1); err != nil {
+		return err
+	}
+	return e.alloc(&object.Result{Ok: true, Value: args[0]})
+}
+
+// errBuiltin returns a failed result holding its argument.
+func (e *Evaluator) errBuiltin(args ...object.Object) object.Object {
+	if err := checkArgCount("err", args, 1, 1); err != nil {
+		return err
+	}
+	return e.alloc(&object.Result{Ok: false, Value: args[0]})
+}
+
+// isOkBuiltin reports whether a result is ok.
+func isOkBuiltin(args ...object.Object) object.Object {
+	if err := checkArgs("is_ok", args, 1, object.RESULT_OBJ); err != nil {
+		return err
+	}
+	return nativeBoolToBooleanObject(args[0].(*object.Result).Ok)
+}
+
+// unwrapBuiltin returns the value of an ok result. Unwrapping an err result
+// is an error, whose value is the err's.
+func unwrapBuiltin(args ...object.Object) object.Object {
+	if err := checkArgs("unwrap", args, 1, object.RESULT_OBJ); err != nil {
+		return err
+	}
+	result := args[0].(*object.Result)
+	if !result.Ok {
+		err := newError("cannot unwrap %s", result.Inspect())
+		err.Value = result.Value
+		return err
+	}
+	return result.Value
+}
+
+// unwrapOrBuiltin returns the value of an ok result, or its second argument
+// if the result is err.
+func unwrapOrBuiltin(args ...object.Object) object.Object {
+	if err := checkArgCount("unwrap_or", args, 2, 2); err != nil {
+		return err
+	}
+	result, ok := args[0].(*object.Result)
+	if !ok {
+		return newError("argument to `unwrap_or` must be RESULT, got %s",
+			object.TypeName(args[0]))
+	}
+	if !result.Ok {
+		return args[1]
+	}
+	return result.Value
+}
+
+// mapErrBuiltin implements map_err(result, f), which returns an err result
+// holding f applied to the value of an err result. An ok result is returned
+// as it is.
+func (e *Evaluator) mapErrBuiltin(args ...object.Object) object.Object {
+	if err := checkArgCount("map_err", args, 2, 2); err != nil {
+		return err
+	}
+	result, ok := args[0].(*object.Result)
+	if !ok {
+		return newError("argument to `map_err` must be RESULT, got %s",
+			object.TypeName(args[0]))
+	}
+	if result.Ok {
+		return result
+	}
+	mapped := e.Apply(args[1], []object.Object{result.Value})
+	if isError(mapped) {
+		return mapped
+	}
+	return e.alloc(&object.Result{Ok: false, Value: mapped})
+}
+
+// checkArgCount checks that the builtin name was called with between min
+// and max arguments. A negative max means there's no upper limit.
+func checkArgCount(name string, args []object.Object, min, max int) *object.Error {
+	switch {
+	case min == max && len(args) != min:
+		return newError("wrong number of arguments to `%s`: want %d, got %d",
+			name, min, len(args))
+	case max < 0 && len(args) < min:
+		return newError("wrong number of arguments to `%s`: "+
+			"want at least %d, got %d", name, min, len(args))
+	case max >= 0 && (len(args) < min || len(args) > max):
+		return newError("wrong number of arguments to `%s`: "+
+			"want %d to %d, got %d", name, min, max, len(args))
+	}
+	return nil
+}
+
+// checkArgs checks that the builtin name was called with arguments of the
+// given types. The first min arguments are required, the rest optional.
+func checkArgs(
+	name string,
+	args []object.Object,
+	min int,
+	types ...object.ObjectType,
+) *object.Error {
+	if err := checkArgCount(name, args, min, len(types)); err != nil {
+		return err
+	}
+	for i, arg := range args {
+		if arg.Type() != types[i] {
+			return newError("argument to `%s` must be %s, got %s", name,
+				types[i], object.TypeName(arg))
+		}
+	}
+	return nil
+}
+
+// integerArgs checks that the builtin name was called with between min and
+// max arguments, all integers, and returns their values. A negative max
+// means there's no upper limit.
+func integerArgs(
+	name string,
+	args []object.Object,
+	min, max int,
+) ([]int64, *object.Error) {
+	if err := checkArgCount(name, args, min, max); err != nil {
+		return nil, err
+	}
+	values := make([]int64, len(args))
+	for i, arg := range args {
+		integer, ok := arg.(*object.Integer)
+		if !ok {
+			return nil, newError("argument to `%s` must be INTEGER, got %s",
+				name, object.TypeName(arg))
+		}
+		values[i] = integer.Value
+	}
+	return values, nil
+}
@@ -1,6 +1,11 @@
 package evaluator
 
 import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
 	"github.com/jamesroutley/monkey/ast"
 	"github.com/jamesroutley/monkey/object"
 )
@@ -11,39 +16,1837 @@ var (
 	TRUE  = &object.Boolean{Value: true}
 	FALSE = &object.Boolean{Value: false}
 	NULL  = &object.Null{}
+
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
 )
 
-// Eval recursively evaluates an AST.
-func Eval(node ast.Node) object.Object {
+// Eval recursively evaluates an AST, with no limits on the resources it may
+// use. Bindings are looked up in, and created in, env.
+func Eval(node ast.Node, env *object.Environment) object.Object {
+	return New(context.Background(), Options{}).Eval(node, env)
+}
+
+// Eval recursively evaluates an AST. Bindings are looked up in, and created
+// in, env. If a limit set in the Evaluator's Options is reached, evaluation
+// stops and an error is returned.
+//
+// Errors are given the position of the innermost node whose evaluation
+// produced them.
+func (e *Evaluator) Eval(node ast.Node, env *object.Environment) object.Object {
+	var result object.Object
+	if err := e.step(); err != nil {
+		result = err
+	} else {
+		result = e.eval(node, env)
+	}
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = node.Pos()
+	}
+	return result
+}
+
+func (e *Evaluator) eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 
 	case *ast.Program:
-		return evalStatements(node.Statements)
+		return e.evalProgram(node.Statements, env)
 
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression)
+		return e.Eval(node.Expression, env)
+
+	case *ast.BlockStatement:
+		return e.evalBlockStatement(node, env)
+
+	case *ast.LetStatement:
+		val := e.Eval(node.Value, env)
+		if unwinds(val) {
+			return val
+		}
+		if node.Pattern != nil {
+			if err := e.bindPattern(node.Pattern, val, env); err != nil {
+				return err
+			}
+			return nil
+		}
+		env.Set(node.Name.Value, val)
+
+	case *ast.ReturnStatement:
+		val := e.Eval(node.ReturnValue, env)
+		if unwinds(val) {
+			return val
+		}
+		return &object.ReturnValue{Value: val}
+
+	case *ast.WhileStatement:
+		return e.evalWhileStatement(node, env)
+
+	case *ast.ForStatement:
+		return e.evalForStatement(node, env)
+
+	case *ast.BreakStatement:
+		return BREAK
+
+	case *ast.ContinueStatement:
+		return CONTINUE
+
+	case *ast.ThrowStatement:
+		return e.evalThrowStatement(node, env)
+
+	case *ast.StructStatement:
+		fields := make([]string, len(node.Fields))
+		for i, field := range node.Fields {
+			fields[i] = field.Value
+		}
+		def := e.alloc(&object.StructType{Name: node.Name.Value, Fields: fields})
+		if isError(def) {
+			return def
+		}
+		env.Set(node.Name.Value, def)
+
+	case *ast.EnumStatement:
+		def := e.evalEnumStatement(node)
+		if isError(def) {
+			return def
+		}
+		env.Set(node.Name.Value, def)
+
+	case *ast.ImplStatement:
+		return e.evalImplStatement(node, env)
 
 	case *ast.IntegerLiteral:
-		return &object.Integer{Value: node.Value}
+		return e.alloc(&object.Integer{Value: node.Value})
 
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
+
+	case *ast.StringLiteral:
+		return e.alloc(&object.String{Value: node.Value})
+
+	case *ast.ArrayLiteral:
+		elements := e.evalExpressions(node.Elements, env)
+		if len(elements) == 1 && unwinds(elements[0]) {
+			return elements[0]
+		}
+		return e.alloc(&object.Array{Elements: elements})
+
+	case *ast.TupleLiteral:
+		elements := e.evalExpressions(node.Elements, env)
+		if len(elements) == 1 && unwinds(elements[0]) {
+			return elements[0]
+		}
+		return e.alloc(&object.Tuple{Elements: elements})
+
+	case *ast.HashLiteral:
+		return e.evalHashLiteral(node, env)
+
+	case *ast.IndexExpression:
+		left := e.Eval(node.Left, env)
+		if unwinds(left) {
+			return left
+		}
+		index := e.Eval(node.Index, env)
+		if unwinds(index) {
+			return index
+		}
+		return e.evalIndexExpression(left, index)
+
+	case *ast.ImportExpression:
+		return e.evalImportExpression(node)
+
+	case *ast.WithExpression:
+		return e.evalWithExpression(node, env)
+
+	case *ast.PropagateExpression:
+		value := e.Eval(node.Value, env)
+		if unwinds(value) {
+			return value
+		}
+		return evalPropagateExpression(value)
+
+	case *ast.MemberExpression:
+		obj := e.Eval(node.Object, env)
+		if unwinds(obj) {
+			return obj
+		}
+		return evalMemberExpression(obj, node.Member.Value)
+
+	case *ast.Identifier:
+		return e.evalIdentifier(node, env)
+
+	case *ast.PrefixExpression:
+		right := e.Eval(node.Right, env)
+		if unwinds(right) {
+			return right
+		}
+		return e.evalPrefixExpression(node.Operator, right)
+
+	case *ast.InfixExpression:
+		// The logical operators short-circuit, so their right operand must
+		// only be evaluated once we've looked at the left one.
+		if node.Operator == "&&" || node.Operator == "||" {
+			return e.evalLogicalExpression(node, env)
+		}
+		left := e.Eval(node.Left, env)
+		if unwinds(left) {
+			return left
+		}
+		right := e.Eval(node.Right, env)
+		if unwinds(right) {
+			return right
+		}
+		return e.evalInfixExpression(node.Operator, left, right)
+
+	case *ast.AssignExpression:
+		return e.evalAssignExpression(node, env)
+
+	case *ast.IfExpression:
+		return e.evalIfExpression(node, env)
+
+	case *ast.SwitchExpression:
+		return e.evalSwitchExpression(node, env)
+
+	case *ast.TryExpression:
+		return e.evalTryExpression(node, env)
+
+	case *ast.MatchExpression:
+		return e.evalMatchExpression(node, env)
+
+	case *ast.FunctionLiteral:
+		return e.alloc(&object.Function{
+			Parameters: node.Parameters,
+			Body:       node.Body,
+			Env:        env,
+			Name:       node.Name,
+		})
+
+	case *ast.MacroLiteral:
+		return newError("macros must be bound by a let statement at the " +
+			"top level of a program")
+
+	case *ast.CallExpression:
+		// quote's argument isn't evaluated, so it can't be a function.
+		if ident, ok := node.Function.(*ast.Identifier); ok &&
+			ident.Value == "quote" {
+			return e.evalQuote(node, env)
+		}
+		function := e.Eval(node.Function, env)
+		if unwinds(function) {
+			return function
+		}
+		args, named, err := e.evalArguments(node.Arguments, env)
+		if err != nil {
+			return err
+		}
+		// The function whose body this call is the tail of will make the
+		// call for us, once its own call has finished.
+		if node.Tail {
+			switch fn := function.(type) {
+			case *object.Function:
+				return e.alloc(&object.TailCall{
+					Function:  fn,
+					Arguments: args,
+					Named:     named,
+				})
+			case *object.BoundMethod:
+				return e.alloc(&object.TailCall{
+					Function:  fn.Method,
+					Arguments: append([]object.Object{fn.Receiver}, args...),
+					Named:     named,
+				})
+			}
+		}
+		result := e.applyFunction(function, args, named)
+		// Record where the function whose frame was added to the error's
+		// stack was called from.
+		if err, ok := result.(*object.Error); ok && len(err.Stack) > 0 {
+			frame := &err.Stack[len(err.Stack)-1]
+			switch function.(type) {
+			case *object.Function, *object.BoundMethod:
+				if !frame.Pos.IsValid() {
+					frame.Pos = node.Pos()
+				}
+			}
+		}
+		return result
 	}
 
 	return nil
 }
 
-func evalStatements(stmts []ast.Statement) object.Object {
+// evalProgram evaluates each statement in turn, stopping at the first error
+// or return statement. An err result propagated by ? to the top level has
+// nowhere left to go, so it's reported as an error.
+func (e *Evaluator) evalProgram(
+	stmts []ast.Statement,
+	env *object.Environment,
+) object.Object {
 	var result object.Object
 	for _, statement := range stmts {
-		result = Eval(statement)
+		result = e.Eval(statement, env)
+		switch result := result.(type) {
+		case *object.ReturnValue:
+			if result.Propagated {
+				err := newError("unhandled %s", result.Value.Inspect())
+				err.Pos = statement.Pos()
+				err.Value = result.Value.(*object.Result).Value
+				return err
+			}
+			return result.Value
+		case *object.Error:
+			return result
+		}
+	}
+	return result
+}
+
+// evalBlockStatement evaluates each statement in a block. Unlike
+// evalProgram, return values are left wrapped, so they keep unwinding
+// through any enclosing blocks until they reach the function boundary.
+// Likewise break and continue unwind to the innermost loop.
+func (e *Evaluator) evalBlockStatement(
+	block *ast.BlockStatement,
+	env *object.Environment,
+) object.Object {
+	var result object.Object
+	for _, statement := range block.Statements {
+		result = e.Eval(statement, env)
+		if unwinds(result) {
+			return result
+		}
+	}
+	return result
+}
+
+func (e *Evaluator) evalWhileStatement(
+	ws *ast.WhileStatement,
+	env *object.Environment,
+) object.Object {
+	for {
+		condition := e.Eval(ws.Condition, env)
+		if unwinds(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return NULL
+		}
+		result := e.Eval(ws.Body, env)
+		if result, done := loopBodyResult(result); done {
+			return result
+		}
+	}
+}
+
+// evalForStatement runs the loop body once per item in the iterable. Each
+// iteration gets its own environment, so closures created in the body
+// capture that iteration's item.
+func (e *Evaluator) evalForStatement(
+	fs *ast.ForStatement,
+	env *object.Environment,
+) object.Object {
+	iterable := e.Eval(fs.Iterable, env)
+	if unwinds(iterable) {
+		return iterable
+	}
+	next, ok := e.iterate(iterable)
+	if !ok {
+		return newError("cannot iterate over %s", object.TypeName(iterable))
+	}
+	for item, ok := next(); ok; item, ok = next() {
+		if isError(item) {
+			return item
+		}
+		loopEnv, err := e.newEnclosedEnvironment(env)
+		if err != nil {
+			return err
+		}
+		loopEnv.Set(fs.Variable.Value, item)
+		result := e.Eval(fs.Body, loopEnv)
+		if result, done := loopBodyResult(result); done {
+			return result
+		}
+	}
+	return NULL
+}
+
+// loopBodyResult interprets the result of evaluating one iteration of a loop
+// body. done reports whether the loop should stop, in which case result is
+// what the loop evaluates to. Return values and errors are passed on so they
+// keep unwinding past the loop.
+func loopBodyResult(result object.Object) (object.Object, bool) {
+	switch result {
+	case BREAK:
+		return NULL, true
+	case CONTINUE:
+		return nil, false
+	}
+	if result != nil {
+		rt := result.Type()
+		if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ {
+			return result, true
+		}
+	}
+	return nil, false
+}
+
+// iterate returns a function which yields each item of obj in turn, and
+// false once there are no more. ok is false if obj isn't iterable. An item
+// may be an error, if allocating it took the program over its limits.
+func (e *Evaluator) iterate(
+	obj object.Object,
+) (next func() (object.Object, bool), ok bool) {
+	switch obj := obj.(type) {
+	case *object.Range:
+		i, done := obj.Start, false
+		return func() (object.Object, bool) {
+			if done || (obj.Step > 0 && i >= obj.Stop) ||
+				(obj.Step < 0 && i <= obj.Stop) {
+				return nil, false
+			}
+			item := e.alloc(&object.Integer{Value: i})
+			// Stop, rather than wrapping around, if the next item would be
+			// out of the range of an integer.
+			if (obj.Step > 0 && i > math.MaxInt64-obj.Step) ||
+				(obj.Step < 0 && i < math.MinInt64-obj.Step) {
+				done = true
+			} else {
+				i += obj.Step
+			}
+			return item, true
+		}, true
+	case *object.Array, *object.Tuple:
+		elements, _ := sequenceElements(obj)
+		i := 0
+		return func() (object.Object, bool) {
+			if i >= len(elements) {
+				return nil, false
+			}
+			i++
+			return elements[i-1], true
+		}, true
+	case *object.String:
+		// Strings are iterated over by character, not by byte.
+		chars := []rune(obj.Value)
+		i := 0
+		return func() (object.Object, bool) {
+			if i >= len(chars) {
+				return nil, false
+			}
+			i++
+			return e.alloc(&object.String{Value: string(chars[i-1])}), true
+		}, true
+	case *object.Hash:
+		// Hashes are iterated over by key, in the order they were added.
+		pairs := obj.OrderedPairs()
+		i := 0
+		return func() (object.Object, bool) {
+			if i >= len(pairs) {
+				return nil, false
+			}
+			i++
+			return pairs[i-1].Key, true
+		}, true
+	default:
+		return nil, false
+	}
+}
+
+func (e *Evaluator) evalIdentifier(
+	node *ast.Identifier,
+	env *object.Environment,
+) object.Object {
+	if val, ok := env.Get(node.Value); ok {
+		return val
+	}
+	if builtin, ok := e.builtins[node.Value]; ok {
+		return builtin
+	}
+	return newError("identifier not found: %s", node.Value)
+}
+
+// evalAssignExpression updates an existing binding, wherever it's defined in
+// the scope chain, and returns the new value. Compound assignments like
+// 'x += 1' apply the matching infix operator to the current value first.
+func (e *Evaluator) evalAssignExpression(
+	node *ast.AssignExpression,
+	env *object.Environment,
+) object.Object {
+	name := node.Name.Value
+	current, ok := env.Get(name)
+	if !ok {
+		return newError("assignment to undeclared identifier: %s", name)
+	}
+	val := e.Eval(node.Value, env)
+	if unwinds(val) {
+		return val
+	}
+	if node.Operator != "=" {
+		operator := strings.TrimSuffix(node.Operator, "=")
+		val = e.evalInfixExpression(operator, current, val)
+		if isError(val) {
+			return val
+		}
+	}
+	env.Assign(name, val)
+	return val
+}
+
+func (e *Evaluator) evalIfExpression(
+	ie *ast.IfExpression,
+	env *object.Environment,
+) object.Object {
+	condition := e.Eval(ie.Condition, env)
+	if unwinds(condition) {
+		return condition
+	}
+	if isTruthy(condition) {
+		return e.Eval(ie.Consequence, env)
+	} else if ie.Alternative != nil {
+		return e.Eval(ie.Alternative, env)
+	}
+	return NULL
+}
+
+// evalThrowStatement raises the value of ts as an exception. Throwing an
+// exception which has been caught raises it again, with its original
+// message and stack.
+func (e *Evaluator) evalThrowStatement(
+	ts *ast.ThrowStatement,
+	env *object.Environment,
+) object.Object {
+	value := e.Eval(ts.Value, env)
+	if unwinds(value) {
+		return value
+	}
+	if exception, ok := value.(*object.Exception); ok {
+		err := *exception.Err
+		err.Stack = append([]object.Frame(nil), exception.Err.Stack...)
+		return &err
+	}
+	return &object.Error{Message: value.Inspect(), Value: value}
+}
+
+// evalTryExpression evaluates te's body. If that raises an exception, te's
+// catch block is evaluated, in a new scope with the exception bound. te's
+// finally block is then evaluated, however the body or catch block was
+// left, including by a return, break or continue. If the finally block is
+// itself left early, that overrides how the rest of te was left.
+//
+// Fatal errors aren't caught, and skip the finally block.
+func (e *Evaluator) evalTryExpression(
+	te *ast.TryExpression,
+	env *object.Environment,
+) object.Object {
+	result := e.Eval(te.Body, env)
+	if err, ok := result.(*object.Error); ok && !err.Fatal && te.Catch != nil {
+		result = e.evalCatch(te, err, env)
+	}
+	if isFatal(result) || te.Finally == nil {
+		return result
+	}
+
+	finally := e.Eval(te.Finally, env)
+	if unwinds(finally) {
+		return finally
+	}
+	return result
+}
+
+// evalCatch evaluates te's catch block, with err bound to its name as an
+// Exception.
+func (e *Evaluator) evalCatch(
+	te *ast.TryExpression,
+	err *object.Error,
+	env *object.Environment,
+) object.Object {
+	exception := e.newException(err)
+	if isError(exception) {
+		return exception
+	}
+	catchEnv, errObj := e.newEnclosedEnvironment(env)
+	if errObj != nil {
+		return errObj
+	}
+	catchEnv.Set(te.CatchName.Value, exception)
+	return e.Eval(te.Catch, catchEnv)
+}
+
+// newException returns an Exception describing err. Errors raised by the
+// interpreter, rather than thrown, have their message as their value.
+func (e *Evaluator) newException(err *object.Error) object.Object {
+	message := e.alloc(&object.String{Value: err.Message})
+	if isError(message) {
+		return message
+	}
+	value := err.Value
+	if value == nil {
+		value = message
+	}
+	frames := make([]object.Object, len(err.Stack))
+	for i, frame := range err.Stack {
+		frames[i] = e.alloc(&object.String{Value: frame.String()})
+		if isError(frames[i]) {
+			return frames[i]
+		}
+	}
+	stack := e.alloc(&object.Array{Elements: frames})
+	if isError(stack) {
+		return stack
+	}
+	return e.alloc(&object.Exception{
+		Value:   value,
+		Message: message.(*object.String),
+		Stack:   stack.(*object.Array),
+		Err:     err,
+	})
+}
+
+// evalSwitchExpression evaluates the body of the first case of se with a
+// value equal to se's value, or its default case, if it has one and no case
+// matches.
+func (e *Evaluator) evalSwitchExpression(
+	se *ast.SwitchExpression,
+	env *object.Environment,
+) object.Object {
+	value := e.Eval(se.Value, env)
+	if unwinds(value) {
+		return value
+	}
+	for _, c := range se.Cases {
+		for _, exp := range c.Values {
+			candidate := e.Eval(exp, env)
+			if unwinds(candidate) {
+				return candidate
+			}
+			// Values of different types are never equal, rather than an
+			// error as they are for ==.
+			if candidate.Type() != value.Type() {
+				continue
+			}
+			equal := e.evalInfixExpression("==", value, candidate)
+			if isError(equal) {
+				return equal
+			}
+			if equal == TRUE {
+				return e.Eval(c.Body, env)
+			}
+		}
+	}
+	if se.Default != nil {
+		return e.Eval(se.Default, env)
+	}
+	return NULL
+}
+
+// evalMatchExpression evaluates the body of the first arm of me which
+// matches its value, in a new scope holding the names the arm's pattern
+// binds. It's an error for no arm to match.
+func (e *Evaluator) evalMatchExpression(
+	me *ast.MatchExpression,
+	env *object.Environment,
+) object.Object {
+	value := e.Eval(me.Value, env)
+	if unwinds(value) {
+		return value
+	}
+	for _, arm := range me.Arms {
+		// Each pattern gets its own bindings, so one which partly matched
+		// doesn't leave any behind.
+		var bindings map[string]object.Object
+		for _, pattern := range arm.Patterns {
+			candidate := map[string]object.Object{}
+			ok, err := e.matchPattern(pattern, value, env, candidate)
+			if err != nil {
+				return err
+			}
+			if ok {
+				bindings = candidate
+				break
+			}
+		}
+		if bindings == nil {
+			continue
+		}
+
+		armEnv, err := e.newEnclosedEnvironment(env)
+		if err != nil {
+			return err
+		}
+		for name, value := range bindings {
+			armEnv.Set(name, value)
+		}
+		if arm.Guard != nil {
+			guard := e.Eval(arm.Guard, armEnv)
+			if unwinds(guard) {
+				return guard
+			}
+			if !isTruthy(guard) {
+				continue
+			}
+		}
+		return e.Eval(arm.Body, armEnv)
+	}
+	return newError("no match for %s", value.Inspect())
+}
+
+// bindPattern binds the names in pattern, the target of a let statement, in
+// env. pattern is a name, which is bound to value, unless it's _; or an
+// array or hash pattern, which binds the names in its own patterns to the
+// parts of value they correspond to. It's an error for value not to have the
+// shape pattern describes.
+func (e *Evaluator) bindPattern(
+	pattern ast.Expression,
+	value object.Object,
+	env *object.Environment,
+) *object.Error {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value != "_" {
+			env.Set(pattern.Value, value)
+		}
+		return nil
+	case *ast.ArrayPattern:
+		return e.bindArrayPattern(pattern, value, env)
+	case *ast.HashPattern:
+		return e.bindHashPattern(pattern, value, env)
+	default:
+		err := newError("invalid pattern: %s", pattern.String())
+		err.Pos = pattern.Pos()
+		return err
+	}
+}
+
+func (e *Evaluator) bindArrayPattern(
+	pattern *ast.ArrayPattern,
+	value object.Object,
+	env *object.Environment,
+) *object.Error {
+	elements, ok := sequenceElements(value)
+	if !ok {
+		return destructureError(pattern, value, "want ARRAY or TUPLE")
+	}
+	n := len(pattern.Elements)
+	if pattern.Rest == nil && len(elements) != n {
+		return destructureError(pattern, value,
+			fmt.Sprintf("want %d elements, got %d", n, len(elements)))
+	}
+	if len(elements) < n {
+		return destructureError(pattern, value,
+			fmt.Sprintf("want at least %d elements, got %d", n, len(elements)))
+	}
+
+	for i, element := range pattern.Elements {
+		if err := e.bindPattern(element, elements[i], env); err != nil {
+			return err
+		}
+	}
+	if pattern.Rest != nil {
+		rest := make([]object.Object, len(elements)-n)
+		copy(rest, elements[n:])
+		array := e.alloc(&object.Array{Elements: rest})
+		if err, ok := array.(*object.Error); ok {
+			return err
+		}
+		return e.bindPattern(pattern.Rest, array, env)
+	}
+	return nil
+}
+
+// bindHashPattern binds the patterns in pattern to the values of the string
+// keys of a hash, or the members of an object with members, such as a module.
+func (e *Evaluator) bindHashPattern(
+	pattern *ast.HashPattern,
+	value object.Object,
+	env *object.Environment,
+) *object.Error {
+	for _, pair := range pattern.Pairs {
+		var member object.Object
+		switch value := value.(type) {
+		case *object.Hash:
+			var ok bool
+			member, ok = value.Get(&object.String{Value: pair.Key.Value})
+			if !ok {
+				return destructureError(pattern, value,
+					fmt.Sprintf("no key %q", pair.Key.Value))
+			}
+		case object.MemberAccessor:
+			var err error
+			member, err = value.Member(pair.Key.Value)
+			if err != nil {
+				return destructureError(pattern, value, err.Error())
+			}
+		default:
+			return destructureError(pattern, value, "want HASH")
+		}
+		if err := e.bindPattern(pair.Value, member, env); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// destructureError returns an error, positioned at pattern, explaining why
+// value doesn't have the shape pattern describes.
+func destructureError(
+	pattern ast.Expression,
+	value object.Object,
+	reason string,
+) *object.Error {
+	err := newError("cannot destructure %s with %s: %s", value.Type(),
+		pattern.String(), reason)
+	err.Pos = pattern.Pos()
+	return err
+}
+
+// matchPattern reports whether pattern matches value, adding any name it
+// binds to bindings. The enums named by variant patterns are looked up in
+// env.
+func (e *Evaluator) matchPattern(
+	pattern ast.Expression,
+	value object.Object,
+	env *object.Environment,
+	bindings map[string]object.Object,
+) (bool, *object.Error) {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value != "_" {
+			bindings[pattern.Value] = value
+		}
+		return true, nil
+	case *ast.IntegerLiteral:
+		integer, ok := value.(*object.Integer)
+		return ok && integer.Value == pattern.Value, nil
+	case *ast.Boolean:
+		return value == nativeBoolToBooleanObject(pattern.Value), nil
+	case *ast.StringLiteral:
+		str, ok := value.(*object.String)
+		return ok && str.Value == pattern.Value, nil
+	case *ast.VariantPattern:
+		return e.matchVariantPattern(pattern, value, env, bindings)
+	default:
+		err := newError("invalid pattern: %s", pattern.String())
+		err.Pos = pattern.Pos()
+		return false, err
+	}
+}
+
+// matchVariantPattern reports whether value is of the variant pattern
+// names, and, if the pattern has fields, whether they match its payload.
+func (e *Evaluator) matchVariantPattern(
+	pattern *ast.VariantPattern,
+	value object.Object,
+	env *object.Environment,
+	bindings map[string]object.Object,
+) (bool, *object.Error) {
+	def := e.Eval(pattern.Enum, env)
+	if err, ok := def.(*object.Error); ok {
+		return false, err
+	}
+	enum, ok := def.(*object.EnumType)
+	if !ok {
+		err := newError("invalid pattern: %s: want ENUM, got %s",
+			pattern.String(), def.Type())
+		err.Pos = pattern.Pos()
+		return false, err
+	}
+	variant := enum.Variant(pattern.Variant.Value)
+	if variant == nil {
+		err := newError("%s has no variant %s", enum.Name,
+			pattern.Variant.Value)
+		err.Pos = pattern.Variant.Pos()
+		return false, err
+	}
+	if pattern.HasFields && len(pattern.Fields) != len(variant.Fields) {
+		err := newError("invalid pattern: %s: want %d fields, got %d",
+			pattern.String(), len(variant.Fields), len(pattern.Fields))
+		err.Pos = pattern.Pos()
+		return false, err
+	}
+
+	ev, ok := value.(*object.EnumValue)
+	if !ok || ev.Variant != variant {
+		return false, nil
+	}
+	for i, field := range pattern.Fields {
+		ok, err := e.matchPattern(field, ev.Values[i], env, bindings)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (e *Evaluator) evalHashLiteral(
+	node *ast.HashLiteral,
+	env *object.Environment,
+) object.Object {
+	hash := object.NewHash()
+	for _, pair := range node.Pairs {
+		key := e.Eval(pair.Key, env)
+		if unwinds(key) {
+			return key
+		}
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", object.TypeName(key))
+		}
+		value := e.Eval(pair.Value, env)
+		if unwinds(value) {
+			return value
+		}
+		hash.Set(hashKey, value)
+	}
+	return e.alloc(hash)
+}
+
+func (e *Evaluator) evalIndexExpression(left, index object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ,
+		left.Type() == object.TUPLE_OBJ && index.Type() == object.INTEGER_OBJ:
+		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && index.Type() == object.INTEGER_OBJ:
+		return e.evalStringIndexExpression(left, index)
+	case left.Type() == object.HASH_OBJ:
+		return evalHashIndexExpression(left, index)
+	default:
+		if method, ok := methodOf(left, "index"); ok {
+			return e.callMethod(method, left, index)
+		}
+		return newError("index operator not supported: %s[%s]%s",
+			object.TypeName(left), object.TypeName(index), missingMethod(left, "index"))
+	}
+}
+
+// evalImplStatement adds the methods declared by is to a struct or enum
+// type.
+func (e *Evaluator) evalImplStatement(
+	is *ast.ImplStatement,
+	env *object.Environment,
+) object.Object {
+	value := e.Eval(is.Type, env)
+	if unwinds(value) {
+		return value
+	}
+	var (
+		name    string
+		methods *map[string]*object.Function
+		// clash describes the member which a method called method would be
+		// hidden by, if there is one.
+		clash func(method string) string
+	)
+	switch def := value.(type) {
+	case *object.StructType:
+		name, methods = def.Name, &def.Methods
+		clash = func(method string) string {
+			if def.FieldIndex(method) >= 0 {
+				return "field"
+			}
+			return ""
+		}
+	case *object.EnumType:
+		name, methods = def.Name, &def.Methods
+		clash = func(method string) string {
+			if def.Variant(method) != nil {
+				return "variant"
+			}
+			for _, v := range def.Variants {
+				if v.FieldIndex(method) >= 0 {
+					return "field"
+				}
+			}
+			return ""
+		}
+	default:
+		err := newError("cannot impl %s: want a struct or enum type",
+			object.TypeName(value))
+		err.Pos = is.Type.Pos()
+		return err
+	}
+	if *methods == nil {
+		*methods = map[string]*object.Function{}
+	}
+	for _, m := range is.Methods {
+		if member := clash(m.Name.Value); member != "" {
+			err := newError("%s has a %s called %s", name, member, m.Name.Value)
+			err.Pos = m.Name.Pos()
+			return err
+		}
+		method := e.Eval(m.Function, env)
+		if isError(method) {
+			return method
+		}
+		(*methods)[m.Name.Value] = method.(*object.Function)
+	}
+	return nil
+}
+
+// methodOf returns the method called name of obj's type, if obj is of a
+// user-defined type with such a method.
+func methodOf(obj object.Object, name string) (*object.Function, bool) {
+	var methods map[string]*object.Function
+	switch obj := obj.(type) {
+	case *object.Struct:
+		methods = obj.Def.Methods
+	case *object.EnumValue:
+		methods = obj.Variant.Enum.Methods
+	}
+	method, ok := methods[name]
+	return method, ok
+}
+
+// missingMethod explains, for an error message, that obj's type doesn't
+// have the method called name, if obj is of a user-defined type which could
+// have it.
+func missingMethod(obj object.Object, name string) string {
+	switch obj.(type) {
+	case *object.Struct, *object.EnumValue:
+		return fmt.Sprintf(": %s has no %s method", object.TypeName(obj), name)
+	}
+	return ""
+}
+
+// callMethod calls method with receiver as its first argument, followed by
+// args.
+func (e *Evaluator) callMethod(
+	method *object.Function,
+	receiver object.Object,
+	args ...object.Object,
+) object.Object {
+	return e.applyFunction(method, append([]object.Object{receiver}, args...),
+		nil)
+}
+
+// operatorMethods are the names of the methods which implement operators for
+// user-defined types. != is implemented by negating eq.
+var operatorMethods = map[string]string{
+	"+":  "add",
+	"-":  "sub",
+	"==": "eq",
+	"!=": "eq",
+	"<":  "lt",
+}
+
+// reflectedOperatorMethods are the names of the methods of the right
+// operand's type which implement operators, used if the left operand's type
+// doesn't implement them. They're called with the right operand as self, so
+// `1 - p` calls `p.rsub(1)`. Equality is symmetric, so uses eq. < has no
+// reflected method.
+var reflectedOperatorMethods = map[string]string{
+	"+":  "radd",
+	"-":  "rsub",
+	"==": "eq",
+	"!=": "eq",
+}
+
+// evalOperatorMethod applies operator to left and right by calling the
+// method which implements it, if left is of a user-defined type with one,
+// or else the reflected method, if right is. ok is false if there isn't a
+// method to call.
+func (e *Evaluator) evalOperatorMethod(
+	operator string,
+	left, right object.Object,
+) (result object.Object, ok bool) {
+	name, ok := operatorMethods[operator]
+	if !ok {
+		return nil, false
+	}
+	self, other := left, right
+	method, ok := methodOf(left, name)
+	if !ok {
+		name, ok = reflectedOperatorMethods[operator]
+		if !ok {
+			return nil, false
+		}
+		self, other = right, left
+		method, ok = methodOf(right, name)
+		if !ok {
+			return nil, false
+		}
+	}
+	result = e.callMethod(method, self, other)
+	if isError(result) || operator == "+" || operator == "-" {
+		return result, true
+	}
+	if result.Type() != object.BOOLEAN_OBJ {
+		return newError("method %s of %s must return BOOLEAN, got %s", name,
+			object.TypeName(self), object.TypeName(result)), true
+	}
+	if operator == "!=" {
+		return nativeBoolToBooleanObject(result == FALSE), true
+	}
+	return result, true
+}
+
+// toString converts obj to a string: a string as it is, a value of a type
+// with a str method by calling it, and anything else as it's printed.
+func (e *Evaluator) toString(obj object.Object) object.Object {
+	if str, ok := obj.(*object.String); ok {
+		return str
+	}
+	method, ok := methodOf(obj, "str")
+	if !ok {
+		return e.alloc(&object.String{Value: obj.Inspect()})
+	}
+	result := e.callMethod(method, obj)
+	if isError(result) {
+		return result
+	}
+	if result.Type() != object.STRING_OBJ {
+		return newError("method str of %s must return STRING, got %s",
+			object.TypeName(obj), object.TypeName(result))
 	}
 	return result
 }
 
+// constructStruct returns a new Struct of type def, with its fields set to
+// the positional arguments args and the named arguments named. Each field
+// must be given a value.
+func (e *Evaluator) constructStruct(
+	def *object.StructType,
+	args []object.Object,
+	named []object.NamedArgument,
+) object.Object {
+	values, err := fieldValues(def.Name, def.Fields, args, named)
+	if err != nil {
+		return err
+	}
+	return e.alloc(&object.Struct{Def: def, Values: values})
+}
+
+// fieldValues returns the values of fields, in order, given by the
+// positional args and named arguments to the constructor called name.
+func fieldValues(
+	name string,
+	fields []string,
+	args []object.Object,
+	named []object.NamedArgument,
+) ([]object.Object, *object.Error) {
+	n := len(fields)
+	if len(args) > n || (len(named) == 0 && len(args) < n) {
+		return nil, checkArgCount(name, args, n, n)
+	}
+	values := make([]object.Object, n)
+	copy(values, args)
+	for _, arg := range named {
+		i := indexOf(fields, arg.Name)
+		switch {
+		case i < 0:
+			return nil, newError("%s has no field %s", name, arg.Name)
+		case values[i] != nil:
+			return nil, newError("duplicate argument for field %s of `%s`",
+				arg.Name, name)
+		}
+		values[i] = arg.Value
+	}
+	for i, value := range values {
+		if value == nil {
+			return nil, newError("missing argument for field %s of `%s`",
+				fields[i], name)
+		}
+	}
+	return values, nil
+}
+
+// indexOf returns the index of s in strs, or -1 if it isn't there.
+func indexOf(strs []string, s string) int {
+	for i, str := range strs {
+		if str == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// evalEnumStatement returns the enum type declared by es.
+func (e *Evaluator) evalEnumStatement(es *ast.EnumStatement) object.Object {
+	def := &object.EnumType{Name: es.Name.Value}
+	for _, v := range es.Variants {
+		variant := &object.Variant{Enum: def, Name: v.Name.Value}
+		for _, field := range v.Fields {
+			variant.Fields = append(variant.Fields, field.Value)
+		}
+		if len(variant.Fields) == 0 {
+			variant.Value = &object.EnumValue{Variant: variant}
+		}
+		def.Variants = append(def.Variants, variant)
+	}
+	return e.alloc(def)
+}
+
+// evalWithExpression returns a copy of a struct, with the fields given in
+// we changed.
+func (e *Evaluator) evalWithExpression(
+	we *ast.WithExpression,
+	env *object.Environment,
+) object.Object {
+	value := e.Eval(we.Struct, env)
+	if unwinds(value) {
+		return value
+	}
+	s, ok := value.(*object.Struct)
+	if !ok {
+		return newError("cannot use with on %s: want a struct", object.TypeName(value))
+	}
+	values := append([]object.Object(nil), s.Values...)
+	for _, field := range we.Fields {
+		i := s.Def.FieldIndex(field.Name.Value)
+		if i < 0 {
+			err := newError("%s has no field %s", s.Def.Name, field.Name.Value)
+			err.Pos = field.Name.Pos()
+			return err
+		}
+		fieldValue := e.Eval(field.Value, env)
+		if unwinds(fieldValue) {
+			return fieldValue
+		}
+		values[i] = fieldValue
+	}
+	return e.alloc(&object.Struct{Def: s.Def, Values: values})
+}
+
+// evalPropagateExpression unwraps an ok result. An err result is returned
+// from the enclosing function, as though by a return statement.
+func evalPropagateExpression(value object.Object) object.Object {
+	result, ok := value.(*object.Result)
+	if !ok {
+		return newError("cannot use ? on %s: want RESULT", object.TypeName(value))
+	}
+	if !result.Ok {
+		return &object.ReturnValue{Value: result, Propagated: true}
+	}
+	return result.Value
+}
+
+func evalMemberExpression(obj object.Object, name string) object.Object {
+	accessor, ok := obj.(object.MemberAccessor)
+	if !ok {
+		return newError("member access not supported: %s.%s", object.TypeName(obj),
+			name)
+	}
+	member, err := accessor.Member(name)
+	if err != nil {
+		return newError("%s", err)
+	}
+	return member
+}
+
+// evalStringIndexExpression returns the character at index, as a string, or
+// null if index is out of range. Strings are indexed by character, not byte,
+// like len counts them.
+func (e *Evaluator) evalStringIndexExpression(str, index object.Object) object.Object {
+	runes := []rune(str.(*object.String).Value)
+	i := index.(*object.Integer).Value
+	if i < 0 || i >= int64(len(runes)) {
+		return NULL
+	}
+	return e.alloc(&object.String{Value: string(runes[i])})
+}
+
+// evalArrayIndexExpression returns the element of an array or tuple at
+// index, or null if index is out of range.
+func evalArrayIndexExpression(array, index object.Object) object.Object {
+	elements, _ := sequenceElements(array)
+	i := index.(*object.Integer).Value
+	if i < 0 || i >= int64(len(elements)) {
+		return NULL
+	}
+	return elements[i]
+}
+
+// sequenceElements returns the elements of obj, if it's an array or tuple.
+func sequenceElements(obj object.Object) ([]object.Object, bool) {
+	switch obj := obj.(type) {
+	case *object.Array:
+		return obj.Elements, true
+	case *object.Tuple:
+		return obj.Elements, true
+	default:
+		return nil, false
+	}
+}
+
+// evalHashIndexExpression returns the value for index, or null if the hash
+// has no such key.
+func evalHashIndexExpression(hash, index object.Object) object.Object {
+	key, ok := index.(object.Hashable)
+	if !ok {
+		return newError("unusable as hash key: %s", object.TypeName(index))
+	}
+	value, ok := hash.(*object.Hash).Get(key)
+	if !ok {
+		return NULL
+	}
+	return value
+}
+
+// evalExpressions evaluates exps from left to right. If any evaluates to an
+// error, a slice containing only that error is returned.
+func (e *Evaluator) evalExpressions(
+	exps []ast.Expression,
+	env *object.Environment,
+) []object.Object {
+	var result []object.Object
+	for _, exp := range exps {
+		evaluated := e.Eval(exp, env)
+		if unwinds(evaluated) {
+			return []object.Object{evaluated}
+		}
+		result = append(result, evaluated)
+	}
+	return result
+}
+
+// Apply calls fn, which must be a Monkey function or builtin, with args.
+// It lets Go code call back into functions defined by a Monkey program.
+func (e *Evaluator) Apply(fn object.Object, args []object.Object) object.Object {
+	return e.applyFunction(fn, args, nil)
+}
+
+// evalArguments evaluates the arguments of a call. The elements of spread
+// expressions are added to the positional arguments, args, and named
+// arguments are returned separately. If evaluating an argument unwinds, its
+// result is returned as err.
+func (e *Evaluator) evalArguments(
+	exps []ast.Expression,
+	env *object.Environment,
+) (args []object.Object, named []object.NamedArgument, err object.Object) {
+	for _, exp := range exps {
+		var value object.Object
+		switch exp := exp.(type) {
+		case *ast.NamedArgument:
+			value = e.Eval(exp.Value, env)
+		case *ast.SpreadExpression:
+			value = e.Eval(exp.Value, env)
+		default:
+			value = e.Eval(exp, env)
+		}
+		if unwinds(value) {
+			return nil, nil, value
+		}
+
+		switch exp := exp.(type) {
+		case *ast.NamedArgument:
+			named = append(named, object.NamedArgument{
+				Name:  exp.Name.Value,
+				Value: value,
+			})
+		case *ast.SpreadExpression:
+			elements, ok := sequenceElements(value)
+			if !ok {
+				err := newError("cannot spread %s: want ARRAY or TUPLE",
+					object.TypeName(value))
+				err.Pos = exp.Pos()
+				return nil, nil, err
+			}
+			args = append(args, elements...)
+		default:
+			args = append(args, value)
+		}
+	}
+	return args, named, nil
+}
+
+func (e *Evaluator) applyFunction(
+	fn object.Object,
+	args []object.Object,
+	named []object.NamedArgument,
+) object.Object {
+	switch function := fn.(type) {
+	case *object.Function:
+		if err := e.enterCall(); err != nil {
+			return err
+		}
+		defer e.exitCall()
+
+		// Calls in tail position come back as TailCalls rather than being
+		// made recursively. Making them here, in a loop, means deep tail
+		// recursion runs in constant stack space.
+		for {
+			extendedEnv, err := e.extendFunctionEnv(function, args, named)
+			if err != nil {
+				return unwrapReturnValue(err)
+			}
+			evaluated := unwrapReturnValue(e.Eval(function.Body, extendedEnv))
+			if err, ok := evaluated.(*object.Error); ok {
+				err.Stack = append(err.Stack, object.Frame{
+					Function: functionName(function),
+				})
+				return err
+			}
+			tailCall, ok := evaluated.(*object.TailCall)
+			if !ok {
+				return evaluated
+			}
+			function, args, named = tailCall.Function, tailCall.Arguments,
+				tailCall.Named
+		}
+	case *object.Builtin:
+		if function.SideEffects && e.opts.DisableSideEffects {
+			return newError("side effects disabled: cannot call `%s`",
+				function.Name)
+		}
+		if len(named) > 0 {
+			return newError("`%s` doesn't accept named arguments",
+				function.Name)
+		}
+		return function.Fn(args...)
+	case *object.StructType:
+		return e.constructStruct(function, args, named)
+	case *object.Variant:
+		values, err := fieldValues(function.QualifiedName(), function.Fields,
+			args, named)
+		if err != nil {
+			return err
+		}
+		return e.alloc(&object.EnumValue{Variant: function, Values: values})
+	case *object.BoundMethod:
+		args = append([]object.Object{function.Receiver}, args...)
+		return e.applyFunction(function.Method, args, named)
+	default:
+		return newError("not a function: %s", object.TypeName(fn))
+	}
+}
+
+// extendFunctionEnv returns a new Environment, enclosed by the one the
+// function was defined in, with the function's parameters bound to the
+// positional arguments args and the named arguments named. A rest parameter
+// is bound to an array of any positional arguments left over. Parameters
+// without an argument are bound to their default values, which are
+// evaluated in the new Environment, so can refer to earlier parameters. If
+// evaluating a default value unwinds, its result is returned as the error.
+func (e *Evaluator) extendFunctionEnv(
+	fn *object.Function,
+	args []object.Object,
+	named []object.NamedArgument,
+) (*object.Environment, object.Object) {
+	name := functionName(fn)
+	params := fn.Parameters
+	var rest *ast.Parameter
+	if n := len(params); n > 0 && params[n-1].Rest {
+		params, rest = params[:n-1], params[n-1]
+	}
+
+	required := 0
+	for _, param := range params {
+		if param.Default == nil {
+			required++
+		}
+	}
+	max := len(params)
+	if rest != nil {
+		max = -1
+	}
+	// Missing arguments may be passed by name, so are reported below.
+	if (max >= 0 && len(args) > max) ||
+		(len(named) == 0 && len(args) < required) {
+		return nil, checkArgCount(name, args, required, max)
+	}
+
+	values := make([]object.Object, len(params))
+	copy(values, args)
+	for _, arg := range named {
+		i := parameterIndex(params, arg.Name)
+		switch {
+		case i < 0 && rest != nil && rest.Name.Value == arg.Name:
+			return nil, newError("rest parameter %s of `%s` can't be passed "+
+				"by name", arg.Name, name)
+		case i < 0:
+			return nil, newError("`%s` has no parameter %s", name, arg.Name)
+		case values[i] != nil:
+			return nil, newError("duplicate argument for parameter %s of `%s`",
+				arg.Name, name)
+		}
+		values[i] = arg.Value
+	}
+
+	env, err := e.newEnclosedEnvironment(fn.Env)
+	if err != nil {
+		return nil, err
+	}
+	for i, param := range params {
+		value := values[i]
+		if value == nil {
+			if param.Default == nil {
+				return nil, newError("missing argument for parameter %s of "+
+					"`%s`", param.Name.Value, name)
+			}
+			value = e.Eval(param.Default, env)
+			if unwinds(value) {
+				return nil, value
+			}
+		}
+		env.Set(param.Name.Value, value)
+	}
+	if rest != nil {
+		extra := []object.Object{}
+		if len(args) > len(params) {
+			extra = append(extra, args[len(params):]...)
+		}
+		array := e.alloc(&object.Array{Elements: extra})
+		if err, ok := array.(*object.Error); ok {
+			return nil, err
+		}
+		env.Set(rest.Name.Value, array)
+	}
+	return env, nil
+}
+
+// parameterIndex returns the index of the parameter called name in params,
+// or -1 if there isn't one.
+func parameterIndex(params []*ast.Parameter, name string) int {
+	for i, param := range params {
+		if param.Name.Value == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// functionName returns how fn is referred to in error messages: by the name
+// it was bound to, or if it wasn't, by its parameter list.
+func functionName(fn *object.Function) string {
+	if fn.Name != "" {
+		return fn.Name
+	}
+	params := make([]string, len(fn.Parameters))
+	for i, param := range fn.Parameters {
+		params[i] = param.String()
+	}
+	return "fn(" + strings.Join(params, ", ") + ")"
+}
+
+// unwrapReturnValue stops a return value unwinding any further than the
+// function it was returned from.
+func unwrapReturnValue(obj object.Object) object.Object {
+	if returnValue, ok := obj.(*object.ReturnValue); ok {
+		return returnValue.Value
+	}
+	return obj
+}
+
+func (e *Evaluator) evalPrefixExpression(
+	operator string,
+	right object.Object,
+) object.Object {
+	switch operator {
+	case "!":
+		return evalBangOperatorExpression(right)
+	case "-":
+		return e.evalMinusPrefixOperatorExpression(right)
+	default:
+		return newError("unknown operator: %s%s", operator, object.TypeName(right))
+	}
+}
+
+func evalBangOperatorExpression(right object.Object) object.Object {
+	return nativeBoolToBooleanObject(!isTruthy(right))
+}
+
+func (e *Evaluator) evalMinusPrefixOperatorExpression(
+	right object.Object,
+) object.Object {
+	if right.Type() != object.INTEGER_OBJ {
+		return newError("unknown operator: -%s", object.TypeName(right))
+	}
+	value := right.(*object.Integer).Value
+	return e.alloc(&object.Integer{Value: -value})
+}
+
+// evalLogicalExpression evaluates '&&' and '||'. The right operand is only
+// evaluated if the left one doesn't already decide the result.
+func (e *Evaluator) evalLogicalExpression(
+	node *ast.InfixExpression,
+	env *object.Environment,
+) object.Object {
+	left := e.Eval(node.Left, env)
+	if unwinds(left) {
+		return left
+	}
+	if node.Operator == "&&" && !isTruthy(left) {
+		return FALSE
+	}
+	if node.Operator == "||" && isTruthy(left) {
+		return TRUE
+	}
+	right := e.Eval(node.Right, env)
+	if unwinds(right) {
+		return right
+	}
+	return nativeBoolToBooleanObject(isTruthy(right))
+}
+
+func (e *Evaluator) evalInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	if result, ok := e.evalOperatorMethod(operator, left, right); ok {
+		return result
+	}
+	switch {
+	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
+		return e.evalIntegerInfixExpression(operator, left, right)
+	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
+		return e.evalStringInfixExpression(operator, left, right)
+	case left.Type() != right.Type() ||
+		object.TypeName(left) != object.TypeName(right):
+		return newError("type mismatch: %s %s %s%s",
+			object.TypeName(left), operator, object.TypeName(right),
+			missingOperatorMethod(operator, left, right))
+	case operator == "==" || operator == "!=":
+		return e.evalEqualityExpression(operator, left, right)
+	default:
+		return newError("unknown operator: %s %s %s%s",
+			object.TypeName(left), operator, object.TypeName(right),
+			missingOperatorMethod(operator, left, right))
+	}
+}
+
+// missingOperatorMethod explains, for an error message, that left's type
+// doesn't have the method implementing operator, or if left couldn't have
+// one, that right's type doesn't have the reflected method.
+func missingOperatorMethod(operator string, left, right object.Object) string {
+	name, ok := operatorMethods[operator]
+	if !ok {
+		return ""
+	}
+	if hint := missingMethod(left, name); hint != "" {
+		return hint
+	}
+	name, ok = reflectedOperatorMethods[operator]
+	if !ok {
+		return ""
+	}
+	return missingMethod(right, name)
+}
+
+// evalEqualityExpression compares two values of the same type. Results,
+// structs and enum values are compared by their contents, see
+// structuralEquality. Other
+// values are compared by identity: booleans and null are singletons, so are
+// equal if they have the same value.
+func (e *Evaluator) evalEqualityExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	equal, ok := e.structuralEquality(left, right)
+	if !ok {
+		equal = nativeBoolToBooleanObject(left == right)
+	}
+	if isError(equal) || operator == "==" {
+		return equal
+	}
+	return nativeBoolToBooleanObject(equal == FALSE)
+}
+
+// structuralEquality compares two values of the same type which are equal
+// if their contents are: results, which are equal if both are ok or both
+// err, with equal values; structs, which are equal if they're of the same
+// struct type, with equal fields; and enum values, which are equal if
+// they're of the same variant, with equal payloads. ok is false if left and
+// right aren't compared this way.
+func (e *Evaluator) structuralEquality(
+	left, right object.Object,
+) (equal object.Object, ok bool) {
+	switch left := left.(type) {
+	case *object.Result:
+		right := right.(*object.Result)
+		if left.Ok != right.Ok {
+			return FALSE, true
+		}
+		return e.valuesEqual(left.Value, right.Value), true
+	case *object.Struct:
+		right, ok := right.(*object.Struct)
+		if !ok || left.Def != right.Def {
+			return FALSE, true
+		}
+		for i := range left.Values {
+			equal := e.valuesEqual(left.Values[i], right.Values[i])
+			if equal != TRUE {
+				return equal, true
+			}
+		}
+		return TRUE, true
+	case *object.EnumValue:
+		right, ok := right.(*object.EnumValue)
+		if !ok || left.Variant != right.Variant {
+			return FALSE, true
+		}
+		for i := range left.Values {
+			equal := e.valuesEqual(left.Values[i], right.Values[i])
+			if equal != TRUE {
+				return equal, true
+			}
+		}
+		return TRUE, true
+	}
+	return nil, false
+}
+
+// valuesEqual reports whether left and right are equal by ==, returning TRUE
+// or FALSE, or an error. Unlike ==, values of different types are unequal,
+// rather than an error.
+func (e *Evaluator) valuesEqual(left, right object.Object) object.Object {
+	if left.Type() != right.Type() {
+		return FALSE
+	}
+	return e.evalInfixExpression("==", left, right)
+}
+
+func (e *Evaluator) evalIntegerInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	leftVal := left.(*object.Integer).Value
+	rightVal := right.(*object.Integer).Value
+
+	switch operator {
+	case "+":
+		return e.alloc(&object.Integer{Value: leftVal + rightVal})
+	case "-":
+		return e.alloc(&object.Integer{Value: leftVal - rightVal})
+	case "*":
+		return e.alloc(&object.Integer{Value: leftVal * rightVal})
+	case "/":
+		if rightVal == 0 {
+			return newError("division by zero: %d / %d", leftVal, rightVal)
+		}
+		return e.alloc(&object.Integer{Value: leftVal / rightVal})
+	case "%":
+		if rightVal == 0 {
+			return newError("modulo by zero: %d %% %d", leftVal, rightVal)
+		}
+		return e.alloc(&object.Integer{Value: leftVal % rightVal})
+	case "**":
+		if rightVal < 0 {
+			return newError("negative exponent: %d ** %d", leftVal, rightVal)
+		}
+		return e.alloc(&object.Integer{Value: integerPow(leftVal, rightVal)})
+	case "&":
+		return e.alloc(&object.Integer{Value: leftVal & rightVal})
+	case "|":
+		return e.alloc(&object.Integer{Value: leftVal | rightVal})
+	case "^":
+		return e.alloc(&object.Integer{Value: leftVal ^ rightVal})
+	case "<<":
+		if rightVal < 0 {
+			return newError("negative shift count: %d << %d", leftVal, rightVal)
+		}
+		return e.alloc(&object.Integer{Value: leftVal << uint64(rightVal)})
+	case ">>":
+		if rightVal < 0 {
+			return newError("negative shift count: %d >> %d", leftVal, rightVal)
+		}
+		return e.alloc(&object.Integer{Value: leftVal >> uint64(rightVal)})
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+}
+
+func (e *Evaluator) evalStringInfixExpression(
+	operator string,
+	left, right object.Object,
+) object.Object {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		return e.alloc(&object.String{Value: leftVal + rightVal})
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s",
+			left.Type(), operator, right.Type())
+	}
+}
+
+// integerPow raises base to the power exp using exponentiation by squaring.
+// exp must not be negative.
+func integerPow(base, exp int64) int64 {
+	result := int64(1)
+	for exp > 0 {
+		if exp&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exp >>= 1
+	}
+	return result
+}
+
+// isTruthy reports whether obj counts as true in a condition. null and false
+// are falsy, everything else is truthy.
+func isTruthy(obj object.Object) bool {
+	switch obj {
+	case NULL:
+		return false
+	case TRUE:
+		return true
+	case FALSE:
+		return false
+	default:
+		return true
+	}
+}
+
 func nativeBoolToBooleanObject(input bool) *object.Boolean {
 	if input {
 		return TRUE
 	}
 	return FALSE
 }
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+// newFatalError returns an error which can't be caught, for when the program
+// must stop, e.g. because it's reached a resource limit.
+func newFatalError(format string, a ...interface{}) *object.Error {
+	err := newError(format, a...)
+	err.Fatal = true
+	return err
+}
+
+// isFatal reports whether obj is an error which can't be caught.
+func isFatal(obj object.Object) bool {
+	err, ok := obj.(*object.Error)
+	return ok && err.Fatal
+}
+
+// unwinds reports whether obj stops the evaluation of the expressions
+// enclosing the one which produced it: an error, which unwinds until it's
+// caught; a return value, which unwinds to the function it returns from; or
+// a break or continue, which unwinds to the innermost loop.
+func unwinds(obj object.Object) bool {
+	if obj != nil {
+		switch obj.Type() {
+		case object.ERROR_OBJ, object.RETURN_VALUE_OBJ,
+			object.BREAK_OBJ, object.CONTINUE_OBJ:
+			return true
+		}
+	}
+	return false
+}
+
+func isError(obj object.Object) bool {
+	if obj != nil {
+		return obj.Type() == object.ERROR_OBJ
+	}
+	return false
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/jamesroutley/monkey/lexer"
 	"github.com/jamesroutley/monkey/object"
 	"github.com/jamesroutley/monkey/parser"
+	"github.com/jamesroutley/monkey/token"
 )
 
 func init() {
@@ -22,6 +23,31 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}{
 		{"5", 5},
 		{"10", 10},
+		{"-5", -5},
+		{"-10", -10},
+		{"5 + 5 + 5 + 5 - 10", 10},
+		{"2 * 2 * 2 * 2 * 2", 32},
+		{"-50 + 100 + -50", 0},
+		{"5 * 2 + 10", 20},
+		{"5 + 2 * 10", 25},
+		{"50 / 2 * 2 + 10", 60},
+		{"2 * (5 + 10)", 30},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 + 7 % 4 * 2", 8},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"(-2) ** 3", -8},
+		{"5 ** 0", 1},
+		{"6 & 3", 2},
+		{"6 | 3", 7},
+		{"6 ^ 3", 5},
+		{"1 << 4", 16},
+		{"256 >> 4", 16},
+		{"-16 >> 2", -4},
+		{"1 | 2 ^ 6 & 3", 1},
 	}
 
 	for _, tt := range tests {
@@ -37,6 +63,30 @@ func TestEvalBooleanExpression(t *testing.T) {
 	}{
 		{"true", true},
 		{"false", false},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 < 1", false},
+		{"1 > 1", false},
+		{"1 <= 1", true},
+		{"2 <= 1", false},
+		{"1 >= 1", true},
+		{"1 >= 2", false},
+		{"1 == 1", true},
+		{"1 != 1", false},
+		{"1 == 2", false},
+		{"1 != 2", true},
+		{"true == true", true},
+		{"false == false", true},
+		{"true == false", false},
+		{"true != false", true},
+		{"(1 < 2) == true", true},
+		{"(1 >= 2) == true", false},
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
 	}
 
 	for _, tt := range tests {
@@ -45,12 +95,1614 @@ func TestEvalBooleanExpression(t *testing.T) {
 	}
 }
 
+func TestBangOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"!true", false},
+		{"!false", true},
+		{"!5", false},
+		{"!!true", true},
+		{"!!false", false},
+		{"!!5", true},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestLogicalOperatorsShortCircuit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		// The right operand would produce a division by zero error if it
+		// were evaluated.
+		{"false && 1 / 0 == 0", false},
+		{"true || 1 / 0 == 0", true},
+		{"1 > 2 && 1 % 0 == 0", false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testBooleanObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestErrorHandling(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedMessage string
+	}{
+		{"5 + true;", "type mismatch: INTEGER + BOOLEAN"},
+		{"5 + true; 5;", "type mismatch: INTEGER + BOOLEAN"},
+		{"-true", "unknown operator: -BOOLEAN"},
+		{"true + false;", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"5; true + false; 5", "unknown operator: BOOLEAN + BOOLEAN"},
+		{"true < false", "unknown operator: BOOLEAN < BOOLEAN"},
+		{"10 / 0", "division by zero: 10 / 0"},
+		{"10 % (5 - 5)", "modulo by zero: 10 % 0"},
+		{"2 ** -1", "negative exponent: 2 ** -1"},
+		{"1 << -1", "negative shift count: 1 << -1"},
+		{"true && 5 / 0", "division by zero: 5 / 0"},
+		{"-(1 / 0)", "division by zero: 1 / 0"},
+		{
+			`if (10 > 1) {
+				if (10 > 1) {
+					return true + false;
+				}
+				return 1;
+			}`,
+			"unknown operator: BOOLEAN + BOOLEAN",
+		},
+		{"foobar", "identifier not found: foobar"},
+		{"x = 5", "assignment to undeclared identifier: x"},
+		{"x += 5", "assignment to undeclared identifier: x"},
+		{"let f = fn() { y = 1 }; f()", "assignment to undeclared identifier: y"},
+		{"let x = 1; x += true", "type mismatch: INTEGER + BOOLEAN"},
+		{"let x = 1; x /= 0", "division by zero: 1 / 0"},
+		{"let x = 5; x(1)", "not a function: INTEGER"},
+		{"fn(x) { x }()", "wrong number of arguments to `fn(x)`: want 1, got 0"},
+		{`"Hello" - "World"`, "unknown operator: STRING - STRING"},
+		{`{"name": "Monkey"}[fn(x) { x }];`, "unusable as hash key: FUNCTION"},
+		{`{[1]: 2}`, "unusable as hash key: ARRAY"},
+		{"1[0]", "index operator not supported: INTEGER[INTEGER]"},
+		{`[1, 2]["a"]`, "index operator not supported: ARRAY[STRING]"},
+		{"let x = 1; x.y", "member access not supported: INTEGER.y"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got %T(%+v)", evaluated,
+				evaluated)
+			continue
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected %q, got %q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestIfElseExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (true) { 10 }", 10},
+		{"if (false) { 10 }", nil},
+		{"if (1) { 10 }", 10},
+		{"if (1 < 2) { 10 }", 10},
+		{"if (1 > 2) { 10 }", nil},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 < 2) { 10 } else { 20 }", 10},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestElseIfExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"if (false) { 1 } else if (true) { 2 } else { 3 }", 2},
+		{"if (false) { 1 } else if (false) { 2 } else { 3 }", 3},
+		{"if (true) { 1 } else if (true) { 2 } else { 3 }", 1},
+		{"if (false) { 1 } else if (false) { 2 }", nil},
+		{`let sign = fn(n) {
+			if (n < 0) { -1 } else if (n == 0) { 0 } else { 1 }
+		};
+		sign(-5) * 100 + sign(0) * 10 + sign(5)`, -99},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestSwitchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"switch (1) { case 1: 10; case 2: 20 }", 10},
+		{"switch (2) { case 1: 10; case 2: 20 }", 20},
+		{"switch (3) { case 1: 10; case 2: 20 }", nil},
+		{"switch (3) { case 1, 2: 10; default: 20 }", 20},
+		{"switch (2) { case 1, 2: 10; default: 20 }", 10},
+		{"switch (1) { default: 20; case 1: 10 }", 10},
+		{`switch ("b") { case "a": 1; case "b": 2 }`, 2},
+		{"switch (1 < 2) { case false: 0; case true: 1 }", 1},
+		{"let x = 4; switch (x * 2) { case x + x: 1; default: 0 }", 1},
+		// The body is the statements up to the next case.
+		{"switch (1) { case 1: let y = 2; y * 3 case 2: 0 }", 6},
+		// Values of different types don't match.
+		{`switch ("1") { case 1, true: 10; default: 20 }`, 20},
+		// Cases are tried in order, and only until one matches.
+		{"let n = 0; switch (1) { case 2: 0; case 1: 0; case n = 5: 0 }; n", 0},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestExceptions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`try { 1 } catch (e) { 2 }`, "1"},
+		{`try { throw "boom"; 1 } catch (e) { e.message }`, "boom"},
+		{`try { throw [1, 2] } catch (e) { e.value }`, "[1, 2]"},
+		{`try { throw [1, 2] } catch (e) { e.message }`, "[1, 2]"},
+		{`try { throw "boom" } catch (e) { e }`, "exception(boom)"},
+		// Errors raised by the interpreter can be caught too.
+		{`try { 1 / 0 } catch (e) { e.message }`, "division by zero: 1 / 0"},
+		{`try { 1 + true } catch (e) { e.value }`,
+			"type mismatch: INTEGER + BOOLEAN"},
+		{`try { len(1, 2) } catch (e) { e.message }`,
+			"wrong number of arguments to `len`: want 1, got 2"},
+		{`try { x } catch (e) { e.message }`, "identifier not found: x"},
+		// Exceptions unwind through function calls.
+		{`let f = fn(n) { if (n == 0) { throw "zero" } n };
+		let g = fn(n) { f(n) + 1 };
+		try { g(0) } catch (e) { e.message }`, "zero"},
+		{`let f = fn() { 1 / 0 };
+let g = fn() { let x = f(); x };
+try { g() } catch (e) { e.stack }`,
+			"[f called at 2:25, g called at 3:8]"},
+		{`try { 1 / 0 } catch (e) { e.stack }`, "[]"},
+		// The catch block has its own scope.
+		{`let e = 1; try { throw 2 } catch (e) { e.value }; e`, "1"},
+		{`try { throw 1 } catch (e) { let y = 2; }; y`,
+			"ERROR: identifier not found: y"},
+		// Nested try expressions, and rethrowing.
+		{`try { try { throw 1 } catch (e) { throw e.value + 1 } } catch (e) { e.value }`,
+			"2"},
+		{`let f = fn() { throw "inner" };
+try { try { f() } catch (e) { throw e } } catch (e) { e.stack }`,
+			"[f called at 2:14]"},
+		// finally runs however the try expression is left.
+		{`let n = 0; try { 1 } finally { n = 5 }; n`, "5"},
+		{`let n = 0; try { throw 1 } catch (e) { 2 } finally { n = 5 }; n`, "5"},
+		{`let n = 0; try { try { throw 1 } finally { n = 5 } } catch (e) { n }`,
+			"5"},
+		{`let n = 0; let f = fn() { try { return 1; } finally { n = 10 } }; f() + n`,
+			"11"},
+		{`let n = 0;
+		for (i in range(5)) { try { if (i == 3) { break; } } finally { n += 1 } }
+		n`, "4"},
+		{`try { 1 } finally { 2 }`, "1"},
+		{`try { throw 1 } catch (e) { 2 } finally { 3 }`, "2"},
+		// Leaving finally early overrides how the body was left.
+		{`let f = fn() { try { return 1; } finally { return 2; } }; f()`, "2"},
+		{`let f = fn() { try { throw 1 } finally { return 2; } }; f()`, "2"},
+		{`try { try { 1 } finally { throw "f" } } catch (e) { e.value }`, "f"},
+		// Uncaught exceptions stop the program.
+		{`throw "boom"; 1`, "ERROR: boom"},
+		{`try { throw "boom" } finally { 1 }`, "ERROR: boom"},
+		{`try { throw 1 } catch (e) { e.nope }`,
+			"ERROR: exception has no member nope"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil {
+			t.Errorf("%s - expected %q. got nil", tt.input, tt.expected)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s - expected %q. got %q", tt.input, tt.expected,
+				evaluated.Inspect())
+		}
+	}
+}
+
+func TestResults(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`ok(1)`, "ok(1)"},
+		{`err("boom")`, "err(boom)"},
+		{`[is_ok(ok(1)), is_ok(err(1))]`, "[true, false]"},
+		{`unwrap(ok(1))`, "1"},
+		{`unwrap_or(ok(1), 2)`, "1"},
+		{`unwrap_or(err(1), 2)`, "2"},
+		{`map_err(err(1), fn(e) { e * 10 })`, "err(10)"},
+		{`map_err(ok(1), fn(e) { e * 10 })`, "ok(1)"},
+		{`[ok(1) == ok(1), ok(1) == err(1), ok(1) == ok(2), ok("a") == ok(1)]`,
+			"[true, false, false, false]"},
+		{`[err([1]) != err([1]), ok(ok(1)) == ok(ok(1))]`, "[true, true]"},
+		// ? unwraps an ok result, and returns an err from the function.
+		{`let f = fn() { let x = ok(2)?; x * 10 }; f()`, "20"},
+		{`let f = fn() { let x = err("bad")?; x * 10 }; f()`, "err(bad)"},
+		{`let half = fn(n) { if (n % 2 == 0) { ok(n / 2) } else { err(n) } };
+		let quarter = fn(n) { ok(half(half(n)?)?) };
+		[quarter(8), quarter(6), quarter(5)]`,
+			"[ok(2), err(3), err(5)]"},
+		// Propagation unwinds enclosing expressions and loops, but only to
+		// the nearest function.
+		{`let f = fn() { 1 + [2, err("e")?][0] }; f()`, "err(e)"},
+		{`let f = fn(xs) {
+			let total = 0;
+			for (x in xs) { total += x?; }
+			ok(total)
+		};
+		[f([ok(1), ok(2)]), f([ok(1), err("no"), ok(3)])]`,
+			"[ok(3), err(no)]"},
+		{`let f = fn() { g(err(1)?) }; let g = fn(x) { x }; f()`, "err(1)"},
+		{`let f = fn(x = err(1)?) { x }; f()`, "err(1)"},
+		{`let f = fn() { if (err(1)?) { 1 } }; f()`, "err(1)"},
+		{`let f = fn() { match (err(1)?) { _ => 2 } }; f()`, "err(1)"},
+		{`let f = fn() { let inner = fn() { err(1)? }; inner(); 2 }; f()`,
+			"2"},
+		{`let f = fn() { try { err(1)? } finally { 2 } }; f()`, "err(1)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil {
+			t.Errorf("%s - expected %q. got nil", tt.input, tt.expected)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s - expected %q. got %q", tt.input, tt.expected,
+				evaluated.Inspect())
+		}
+	}
+}
+
+func TestResultErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`1?`, "cannot use ? on INTEGER: want RESULT"},
+		// At the top level, ? ends the program with an error.
+		{`err(1)?; 2`, "unhandled err(1)"},
+		{`let f = fn() { err("boom") }; let x = f()?; x`, "unhandled err(boom)"},
+		{`unwrap(err("boom"))`, "cannot unwrap err(boom)"},
+		{`unwrap(1)`, "argument to `unwrap` must be RESULT, got INTEGER"},
+		{`is_ok()`, "wrong number of arguments to `is_ok`: want 1, got 0"},
+		{`unwrap_or(1, 2)`, "argument to `unwrap_or` must be RESULT, got INTEGER"},
+		{`map_err(err(1), fn(e) { e / 0 })`, "division by zero: 1 / 0"},
+		{`map_err(err(1), 2)`, "not a function: INTEGER"},
+		{`ok(1) + ok(2)`, "unknown operator: RESULT + RESULT"},
+	}
+	for _, tt := range tests {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestStructs(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`struct Point { x, y }; Point`, "struct Point"},
+		{`struct Point { x, y }; Point(1, 2)`, "Point{x: 1, y: 2}"},
+		{`struct Point { x, y }; Point(y: 2, x: 1)`, "Point{x: 1, y: 2}"},
+		{`struct Point { x, y }; Point(1, y: "two")`, "Point{x: 1, y: two}"},
+		{`struct Empty {}; Empty()`, "Empty{}"},
+		{`struct Point { x, y }; let p = Point(1, 2); p.x + p.y`, "3"},
+		{`struct Point { x, y }; let {x, y} = Point(1, 2); [x, y]`, "[1, 2]"},
+		// with makes an updated copy.
+		{`struct Point { x, y }; let p = Point(1, 2); let q = p with { x: 3 }; [p, q]`,
+			"[Point{x: 1, y: 2}, Point{x: 3, y: 2}]"},
+		{`struct Point { x, y }; Point(1, 2) with { y: 4, x: 3 }`,
+			"Point{x: 3, y: 4}"},
+		// Structs are compared by their fields.
+		{`struct Point { x, y };
+		[Point(1, 2) == Point(1, 2), Point(1, 2) == Point(1, 3), Point(1, 2) != Point(2, 1)]`,
+			"[true, false, true]"},
+		{`struct Line { a, b }; struct Point { x, y };
+		Line(Point(0, 0), Point(1, 1)) == Line(Point(0, 0), Point(1, 1))`, "true"},
+		{`struct Point { x, y }; Point(1, "a") == Point(1, 1)`, "false"},
+		{`struct Point { x, y }; let P = Point; struct Point { x, y }; P(1, 2) == Point(1, 2)`,
+			"false"},
+		{`struct Point { x, y }; let xs = [1]; Point(xs, 1) == Point(xs, 1)`, "true"},
+		{`struct Point { x, y }; Point([1], 1) == Point([1], 1)`, "false"},
+		{`struct Point { x, y }; match (Point(1, 2) == Point(1, 2)) { true => 1, _ => 0 }`,
+			"1"},
+		// A struct's type is its name.
+		{`struct Point { x, y }; Point(1, 2) == 1`,
+			"ERROR: type mismatch: Point == INTEGER: Point has no eq method"},
+		// A struct type named like a builtin type isn't mistaken for it.
+		{`struct INTEGER { x }; INTEGER(1) + 1`,
+			"ERROR: type mismatch: INTEGER + INTEGER: INTEGER has no add method"},
+		{`struct RESULT { x }; ok(1) == RESULT(1)`,
+			"ERROR: type mismatch: RESULT == RESULT: RESULT has no eq method"},
+		{`struct STRING { s }; len(STRING("abc"))`,
+			"ERROR: argument to `len` not supported, got STRING: STRING has no len method"},
+		{`struct ERROR { x }; let e = ERROR(1); e.x`, "1"},
+		{`struct Point { x, y }; struct Vec { x, y }; Point(1, 2) == Vec(1, 2)`,
+			"ERROR: type mismatch: Point == Vec: Point has no eq method"},
+		// Structs are constructed like functions are called.
+		{`struct Point { x, y }; let make = fn(...args) { Point(...args) }; make(5, 6)`,
+			"Point{x: 5, y: 6}"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil {
+			t.Errorf("%s - expected %q. got nil", tt.input, tt.expected)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s - expected %q. got %q", tt.input, tt.expected,
+				evaluated.Inspect())
+		}
+	}
+}
+
+func TestStructErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`struct Point { x, y }; Point(1)`,
+			"wrong number of arguments to `Point`: want 2, got 1"},
+		{`struct Point { x, y }; Point(1, 2, 3)`,
+			"wrong number of arguments to `Point`: want 2, got 3"},
+		{`struct Point { x, y }; Point(x: 1)`,
+			"missing argument for field y of `Point`"},
+		{`struct Point { x, y }; Point(1, x: 2)`,
+			"duplicate argument for field x of `Point`"},
+		{`struct Point { x, y }; Point(1, z: 2)`, "Point has no field z"},
+		{`struct Point { x, y }; Point(1, 2).z`, "Point has no field or method z"},
+		{`struct Point { x, y }; Point(1, 2) with { z: 3 }`,
+			"Point has no field z"},
+		{`1 with { x: 1 }`, "cannot use with on INTEGER: want a struct"},
+		{`struct Point { x, y }; Point(1, 2) + Point(1, 2)`,
+			"unknown operator: Point + Point: Point has no add method"},
+		{`struct Point { x, y }; Point(1, 2) with { x: 1 / 0 }`,
+			"division by zero: 1 / 0"},
+	}
+	for _, tt := range tests {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestMethods(t *testing.T) {
+	point := `struct Point { x, y };
+	impl Point {
+		fn add(self, other) { Point(self.x + other.x, self.y + other.y) }
+		fn sub(self, other) { Point(self.x - other.x, self.y - other.y) }
+		fn radd(self, n) { Point(n + self.x, n + self.y) }
+		fn rsub(self, n) { Point(n - self.x, n - self.y) }
+		fn eq(self, other) { self.x * self.y == other.x * other.y }
+		fn lt(self, other) { self.x * self.y < other.x * other.y }
+		fn index(self, i) { if (i == 0) { self.x } else { self.y } }
+		fn len(self) { 2 }
+		fn str(self) { "(" + str(self.x) + ", " + str(self.y) + ")" }
+		fn scale(self, by = 2) { Point(self.x * by, self.y * by) }
+	}
+	`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{point + `Point(1, 2) + Point(3, 4)`, "Point{x: 4, y: 6}"},
+		{point + `Point(3, 4) - Point(1, 2)`, "Point{x: 2, y: 2}"},
+		// If the left operand's type doesn't have the method, the right
+		// operand's reflected method is called with it as self.
+		{point + `10 + Point(1, 2)`, "Point{x: 11, y: 12}"},
+		{point + `10 - Point(1, 2)`, "Point{x: 9, y: 8}"},
+		{`struct Cents { n }; impl Cents { fn eq(self, other) { self.n == other } };
+		[1 == Cents(1), 1 != Cents(1), 2 == Cents(1)]`, "[true, false, false]"},
+		{point + `[Point(1, 6) == Point(2, 3), Point(1, 6) == Point(1, 2)]`,
+			"[true, false]"},
+		{point + `[Point(1, 6) != Point(2, 3), Point(1, 6) != Point(1, 2)]`,
+			"[false, true]"},
+		{point + `[Point(1, 2) < Point(2, 2), Point(2, 2) < Point(1, 2)]`,
+			"[true, false]"},
+		{point + `[Point(5, 6)[0], Point(5, 6)[1]]`, "[5, 6]"},
+		{point + `len(Point(5, 6))`, "2"},
+		{point + `str(Point(5, 6))`, "(5, 6)"},
+		{point + `[str(1), str("a"), str([1, "a"])]`, "[1, a, [1, a]]"},
+		// Methods are called with the value before the dot as self.
+		{point + `Point(1, 2).scale()`, "Point{x: 2, y: 4}"},
+		{point + `Point(1, 2).scale(by: 3)`, "Point{x: 3, y: 6}"},
+		{point + `let f = Point(1, 2).scale; f(10)`, "Point{x: 10, y: 20}"},
+		{point + `Point.scale(Point(1, 2), 5)`, "Point{x: 5, y: 10}"},
+		{point + `Point(1, 2).scale`, "method Point.scale"},
+		// Fields shadow nothing, as methods can't share their names.
+		{point + `Point(1, 2).x`, "1"},
+		// A later impl replaces methods with the same name.
+		{point + `impl Point { fn len(self) { 3 } }; len(Point(1, 2))`, "3"},
+		// Methods can call themselves in tail position without growing the
+		// stack.
+		{`struct Counter { n };
+		impl Counter {
+			fn down(self, total) {
+				if (self.n == 0) { return total; }
+				Counter(self.n - 1).down(total + 1)
+			}
+		}
+		Counter(10000).down(0)`, "10000"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil {
+			t.Errorf("%s - expected %q. got nil", tt.input, tt.expected)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s - expected %q. got %q", tt.input, tt.expected,
+				evaluated.Inspect())
+		}
+	}
+}
+
+func TestMethodErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`struct Point { x, y }; Point(1, 2) < Point(1, 2)`,
+			"unknown operator: Point < Point: Point has no lt method"},
+		{`struct Point { x, y }; Point(1, 2) * Point(1, 2)`,
+			"unknown operator: Point * Point"},
+		{`struct Point { x, y }; Point(1, 2) - 1`,
+			"type mismatch: Point - INTEGER: Point has no sub method"},
+		// The right operand's reflected methods are used if the left
+		// operand's type doesn't have the method.
+		{`struct Point { x, y }; impl Point { fn add(self, other) { self } }; 1 + Point(1, 2)`,
+			"type mismatch: INTEGER + Point: Point has no radd method"},
+		{`struct Point { x, y }; impl Point { fn lt(self, other) { true } }; 1 < Point(1, 2)`,
+			"type mismatch: INTEGER < Point"},
+		{`struct Point { x, y }; impl Point { fn eq(self, other) { 1 } }; 1 == Point(1, 2)`,
+			"method eq of Point must return BOOLEAN, got INTEGER"},
+		{`struct Point { x, y }; Point(1, 2)[0]`,
+			"index operator not supported: Point[INTEGER]: Point has no index method"},
+		{`struct Point { x, y }; len(Point(1, 2))`,
+			"argument to `len` not supported, got Point: Point has no len method"},
+		{`len(fn() {})`, "argument to `len` not supported, got FUNCTION"},
+		{`struct Point { x, y }; impl Point { fn eq(self, other) { 1 } }; Point(1, 2) == Point(1, 2)`,
+			"method eq of Point must return BOOLEAN, got INTEGER"},
+		{`struct Point { x, y }; impl Point { fn str(self) { 1 } }; str(Point(1, 2))`,
+			"method str of Point must return STRING, got INTEGER"},
+		{`struct Point { x, y }; impl Point { fn str(self) { 1 } }; puts(Point(1, 2))`,
+			"method str of Point must return STRING, got INTEGER"},
+		{`struct Point { x, y }; impl Point { fn x(self) { 1 } }`,
+			"Point has a field called x"},
+		{`let Point = 1; impl Point { fn len(self) { 1 } }`,
+			"cannot impl INTEGER: want a struct or enum type"},
+		{`impl Point { fn len(self) { 1 } }`, "identifier not found: Point"},
+		{`struct Point { x, y }; Point.add`, "Point has no method add"},
+		{`struct Point { x, y }; Point(1, 2).add`, "Point has no field or method add"},
+		{`struct Point { x, y }; impl Point { fn add(self, other) { 1 / 0 } };
+		Point(1, 2) + Point(1, 2)`, "division by zero: 1 / 0"},
+		{`str(1, 2)`, "wrong number of arguments to `str`: want 1, got 2"},
+	}
+	for _, tt := range tests {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestEnums(t *testing.T) {
+	shape := "enum Shape { Circle(r), Rect(w, h), Empty };\n"
+	area := shape + `let area = fn(s) {
+		match (s) {
+			Shape.Circle(r) => 3 * r * r,
+			Shape.Rect(w, h) => w * h,
+			Shape.Empty => 0,
+		}
+	};
+	`
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{shape + `Shape`, "enum Shape"},
+		{shape + `Shape.Circle`, "variant Shape.Circle"},
+		{shape + `Shape.Circle(2)`, "Shape.Circle(2)"},
+		{shape + `Shape.Rect(h: 2, w: 1)`, "Shape.Rect(1, 2)"},
+		{shape + `Shape.Empty`, "Shape.Empty"},
+		{shape + `[Shape.Circle("a"), Shape.Empty]`, "[Shape.Circle(a), Shape.Empty]"},
+		{shape + `Shape.Rect(3, 4).h`, "4"},
+		{shape + `let {w, h} = Shape.Rect(3, 4); w * h`, "12"},
+		// Enum values are compared by their variants and payloads.
+		{shape + `[Shape.Rect(1, 2) == Shape.Rect(1, 2), Shape.Rect(1, 2) == Shape.Rect(2, 1)]`,
+			"[true, false]"},
+		{shape + `[Shape.Empty == Shape.Empty, Shape.Empty != Shape.Circle(1)]`,
+			"[true, true]"},
+		{shape + `Shape.Circle(1) == Shape.Circle("1")`, "false"},
+		{shape + `enum Other { Empty }; Shape.Empty == Other.Empty`,
+			"ERROR: type mismatch: Shape == Other: Shape has no eq method"},
+		{shape + `struct Shape {}; Shape() == Shape()`, "true"},
+		// An enum type named like a builtin type isn't mistaken for it.
+		{`enum STRING { A }; STRING.A + "b"`,
+			"ERROR: type mismatch: STRING + STRING: STRING has no add method"},
+		{`enum STRING { A }; len(STRING.A)`,
+			"ERROR: argument to `len` not supported, got STRING: STRING has no len method"},
+		{`enum INTEGER { A }; -INTEGER.A`, "ERROR: unknown operator: -INTEGER"},
+		{`enum ERROR { A }; let e = ERROR.A; e`, "ERROR.A"},
+		{shape + `let s = Shape.Empty; struct Shape {}; s == Shape()`,
+			"ERROR: type mismatch: Shape == Shape: Shape has no eq method"},
+		{shape + `switch (Shape.Circle(1)) { case Shape.Empty: 0 case Shape.Circle(1): 1 }`,
+			"1"},
+		// Match patterns test a value's variant and extract its payload.
+		{area + `[area(Shape.Circle(2)), area(Shape.Rect(3, 4)), area(Shape.Empty)]`,
+			"[12, 12, 0]"},
+		{shape + `match (Shape.Rect(1, 2)) { Shape.Circle => "circle", Shape.Rect => "rect" }`,
+			"rect"},
+		{shape + `match (Shape.Rect(1, 2)) { Shape.Rect(2, h) => h, Shape.Rect(1, h) => -h }`,
+			"-2"},
+		{shape + `match (Shape.Rect(1, 2)) { Shape.Rect(_, h) if h > 5 => 1, _ => 0 }`,
+			"0"},
+		{shape + `match (Shape.Empty) { Shape.Circle | Shape.Rect => 1, Shape.Empty => 0 }`,
+			"0"},
+		{shape + `match (1) { Shape.Empty => 0, _ => 1 }`, "1"},
+		// Or-patterns bind the names of the pattern which matched.
+		{shape + `match (Shape.Circle(4)) { Shape.Circle(x) | Shape.Rect(x, _) => x }`,
+			"4"},
+		{shape + `match (Shape.Rect(3, 5)) { Shape.Rect(x, 3) | Shape.Rect(3, x) => x }`,
+			"5"},
+		{shape + `match (Shape.Rect(3, 5)) { Shape.Rect(x, 3) | Shape.Rect(3, x) if x > 9 => x, _ => 0 }`,
+			"0"},
+		{shape + `enum Option { Some(value), None };
+		match (Option.Some(Shape.Circle(5))) { Option.Some(Shape.Circle(r)) => r, _ => 0 }`,
+			"5"},
+		// Enums can have methods.
+		{area + `impl Shape { fn area(self) { area(self) } fn str(self) { "shape" } };
+		[Shape.Rect(2, 3).area(), str(Shape.Empty)]`, "[6, shape]"},
+		{shape + `let make = fn(...args) { Shape.Rect(...args) }; make(5, 6)`,
+			"Shape.Rect(5, 6)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated == nil {
+			t.Errorf("%s - expected %q. got nil", tt.input, tt.expected)
+			continue
+		}
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s - expected %q. got %q", tt.input, tt.expected,
+				evaluated.Inspect())
+		}
+	}
+}
+
+func TestEnumErrors(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    string
+		expectedPos token.Position
+	}{
+		{
+			"enum Shape { Circle(r), Empty }\nShape.Circle()",
+			"wrong number of arguments to `Shape.Circle`: want 1, got 0",
+			token.Position{Line: 2, Column: 13},
+		},
+		{
+			"enum Shape { Circle(r), Empty }\nShape.Circle(d: 1)",
+			"Shape.Circle has no field d",
+			token.Position{Line: 2, Column: 13},
+		},
+		{
+			"enum Shape { Circle(r), Empty }\nShape.Square",
+			"Shape has no variant or method Square",
+			token.Position{Line: 2, Column: 6},
+		},
+		{
+			"enum Shape { Circle(r), Empty }\nShape.Circle(1).d",
+			"Shape.Circle has no field or method d",
+			token.Position{Line: 2, Column: 16},
+		},
+		{
+			"enum Shape { Circle(r), Empty }\nShape.Empty()",
+			"not a function: Shape",
+			token.Position{Line: 2, Column: 12},
+		},
+		{
+			"enum Shape { Circle(r), Empty }\nmatch (1) { Shape.Square => 1 }",
+			"Shape has no variant Square",
+			token.Position{Line: 2, Column: 19},
+		},
+		{
+			"enum Shape { Circle(r), Empty }\nmatch (1) { Shape.Circle(a, b) => 1 }",
+			"invalid pattern: Shape.Circle(a, b): want 1 fields, got 2",
+			token.Position{Line: 2, Column: 13},
+		},
+		{
+			"let Shape = 1;\nmatch (1) { Shape.Circle => 1 }",
+			"invalid pattern: Shape.Circle: want ENUM, got INTEGER",
+			token.Position{Line: 2, Column: 13},
+		},
+		{
+			"match (1) { Shape.Circle => 1 }",
+			"identifier not found: Shape",
+			token.Position{Line: 1, Column: 13},
+		},
+		{
+			"enum Shape { Circle(r), Empty }\nmatch (Shape.Circle(1)) { Shape.Empty => 1 }",
+			"no match for Shape.Circle(1)",
+			token.Position{Line: 2, Column: 1},
+		},
+		{
+			"enum Shape { Circle(r), Empty }\nShape.Empty < Shape.Empty",
+			"unknown operator: Shape < Shape: Shape has no lt method",
+			token.Position{Line: 2, Column: 13},
+		},
+		{
+			"enum Shape { Circle(r), Empty }\nimpl Shape { fn Empty(self) { 1 } }",
+			"Shape has a variant called Empty",
+			token.Position{Line: 2, Column: 17},
+		},
+		{
+			"enum Shape { Circle(r), Empty }\nimpl Shape { fn r(self) { 1 } }",
+			"Shape has a field called r",
+			token.Position{Line: 2, Column: 17},
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if !testErrorObject(t, evaluated, tt.expected) {
+			continue
+		}
+		if pos := evaluated.(*object.Error).Pos; pos != tt.expectedPos {
+			t.Errorf("%q - wrong position. expected %s. got %s", tt.input,
+				tt.expectedPos, pos)
+		}
+	}
+}
+
+func TestMatchExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"match (1) { 1 => 10, _ => 20 }", 10},
+		{"match (2) { 1 => 10, _ => 20 }", 20},
+		{"match (-3) { 3 => 1, -3 => 2 }", 2},
+		{"match (1 < 2) { false => 0, true => 1 }", 1},
+		{`match ("b") { "a" => 1, "b" => 2 }`, 2},
+		{"match (5) { n => n * 2 }", 10},
+		{"match (3) { 1 | 2 => 10, 3 | 4 => 20, _ => 30 }", 20},
+		{"match (7) { n if n < 0 => -1, n if n > 0 => 1, _ => 0 }", 1},
+		{"match (0) { n if n < 0 => -1, n if n > 0 => 1, _ => 0 }", 0},
+		{"match (1) { 1 | 2 if false => 10, _ => 20 }", 20},
+		// Patterns only match values of the same type.
+		{`match ("1") { 1 => 10, true => 20, _ => 30 }`, 30},
+		{"match (true) { 1 => 10, _ => 20 }", 20},
+		{"match ([1]) { x => len(x) }", 1},
+		{"match (1) { 1 => if (false) { 1 } }", nil},
+		// Names are bound in a new scope.
+		{"let n = 1; match (2) { n => n }; n", 1},
+		{"let n = 1; match (2) { x => n }", 1},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestMatchErrors(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    string
+		expectedPos token.Position
+	}{
+		{
+			"let x = 3;\nmatch (x) { 1 => 1, 2 => 2 }",
+			"no match for 3",
+			token.Position{Line: 2, Column: 1},
+		},
+		{
+			`match ([1, "a"]) { n if n > 0 => n }`,
+			`type mismatch: ARRAY > INTEGER`,
+			token.Position{Line: 1, Column: 27},
+		},
+		{
+			"match (1) { 1 => y }",
+			"identifier not found: y",
+			token.Position{Line: 1, Column: 18},
+		},
+		{
+			"match (1) { n if true => 1 }; n",
+			"identifier not found: n",
+			token.Position{Line: 1, Column: 31},
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if !testErrorObject(t, evaluated, tt.expected) {
+			continue
+		}
+		if pos := evaluated.(*object.Error).Pos; pos != tt.expectedPos {
+			t.Errorf("%q - wrong position. expected %s. got %s", tt.input,
+				tt.expectedPos, pos)
+		}
+	}
+}
+
+func TestDestructuring(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let [a, b] = [1, 2]; a * 10 + b", 12},
+		{"let [a, b, ...rest] = [1, 2, 3, 4]; a + b + len(rest) + rest[1]", 9},
+		{"let [a, ...rest] = [1]; a + len(rest)", 1},
+		{"let [a, _, c] = [1, 2, 3]; a + c", 4},
+		{"let [[a, b], [c]] = [[1, 2], [3]]; a + b + c", 6},
+		{"let [] = []; 1", 1},
+		{`let {x, y: alias} = {"x": 1, "y": 2}; x * 10 + alias`, 12},
+		{`let {p: {x}, q: [y]} = {"p": {"x": 1}, "q": [2]}; x + y`, 3},
+		{`let {abs} = import "math"; abs(-3)`, 3},
+		// Functions return several values as a tuple.
+		{`let divmod = fn(a, b) { return a / b, a % b; };
+		let [q, r] = divmod(17, 5);
+		q * 10 + r`, 32},
+		{`let swap = fn(a, b) { (b, a) };
+		let [x, y] = swap(1, 2);
+		x * 10 + y`, 21},
+		{"let [a, ...rest] = (1, 2, 3); len(rest)", 2},
+		// Bindings are made in the current scope.
+		{"let a = 1; let f = fn() { let [a] = [2]; a }; f() * 10 + a", 21},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestDestructuringErrors(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    string
+		expectedPos token.Position
+	}{
+		{
+			"let [a, b] = [1, 2, 3];",
+			"cannot destructure ARRAY with [a, b]: want 2 elements, got 3",
+			token.Position{Line: 1, Column: 5},
+		},
+		{
+			"let [a, b, ...c] = [1];",
+			"cannot destructure ARRAY with [a, b, ...c]: want at least 2 " +
+				"elements, got 1",
+			token.Position{Line: 1, Column: 5},
+		},
+		{
+			"let [a, b] = 1;",
+			"cannot destructure INTEGER with [a, b]: want ARRAY or TUPLE",
+			token.Position{Line: 1, Column: 5},
+		},
+		{
+			"let [a, [b, c]] = [1, [2]];",
+			"cannot destructure ARRAY with [b, c]: want 2 elements, got 1",
+			token.Position{Line: 1, Column: 9},
+		},
+		{
+			`let {x, y} = {"x": 1};`,
+			`cannot destructure HASH with {x, y}: no key "y"`,
+			token.Position{Line: 1, Column: 5},
+		},
+		{
+			"let {x} = [1];",
+			"cannot destructure ARRAY with {x}: want HASH",
+			token.Position{Line: 1, Column: 5},
+		},
+		{
+			`let {nope} = import "math";`,
+			"cannot destructure MODULE with {nope}: module math has no " +
+				"member nope",
+			token.Position{Line: 1, Column: 5},
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if !testErrorObject(t, evaluated, tt.expected) {
+			continue
+		}
+		if pos := evaluated.(*object.Error).Pos; pos != tt.expectedPos {
+			t.Errorf("%q - wrong position. expected %s. got %s", tt.input,
+				tt.expectedPos, pos)
+		}
+	}
+}
+
+func TestTuples(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`(1, "two", [3])`, `(1, two, [3])`},
+		{"let t = (1, 2 + 3); t[1]", "5"},
+		{"(1, 2)[2]", "null"},
+		{"len((1, 2, 3))", "3"},
+		{"let total = 0; for (x in (1, 2, 3)) { total += x; } total", "6"},
+		{"(1)", "1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%q - expected %q. got %q", tt.input, tt.expected,
+				evaluated.Inspect())
+		}
+	}
+}
+
+func TestReturnStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"return 10;", 10},
+		{"return 10; 9;", 10},
+		{"return 2 * 5; 9;", 10},
+		{"9; return 2 * 5; 9;", 10},
+		{
+			`if (10 > 1) {
+				if (10 > 1) {
+					return 10;
+				}
+				return 1;
+			}`,
+			10,
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestLetStatements(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let a = 5; a;", 5},
+		{"let a = 5 * 5; a;", 25},
+		{"let a = 5; let b = a; b;", 5},
+		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestFunctionApplication(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let identity = fn(x) { x; }; identity(5);", 5},
+		{"let identity = fn(x) { return x; }; identity(5);", 5},
+		{"let double = fn(x) { x * 2; }; double(5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5, 5);", 10},
+		{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+		{"fn(x) { x; }(5)", 5},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestClosures(t *testing.T) {
+	input := `
+let newAdder = fn(x) {
+	fn(y) { x + y };
+};
+
+let addTwo = newAdder(2);
+addTwo(2);`
+
+	testIntegerObject(t, testEval(input), 4)
+}
+
+func TestFunctionArguments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// Default values
+		{"let f = fn(a, b = 10) { a + b }; f(1)", "11"},
+		{"let f = fn(a, b = 10) { a + b }; f(1, 2)", "3"},
+		{"let f = fn(a, b = a * 2) { b }; f(4)", "8"},
+		{"let f = fn(a = []) { a }; f() == f()", "false"},
+		// Defaults are evaluated in the scope the function was defined in.
+		{`let n = 1;
+		let f = fn(a = n) { a };
+		let g = fn() { let n = 2; f() };
+		g()`, "1"},
+		{"let n = 1; let f = fn(a = n) { a }; n = 5; f()", "5"},
+		// Named arguments
+		{"let f = fn(a, b) { a - b }; f(b: 1, a: 10)", "9"},
+		{"let f = fn(a, b) { a - b }; f(10, b: 1)", "9"},
+		{"let f = fn(a, b = 2, c = 3) { [a, b, c] }; f(1, c: 30)", "[1, 2, 30]"},
+		// Rest parameters and spread arguments
+		{"let f = fn(a, ...rest) { rest }; f(1, 2, 3)", "[2, 3]"},
+		{"let f = fn(a, ...rest) { rest }; f(1)", "[]"},
+		{"let f = fn(a, b) { a - b }; f(...[10, 1])", "9"},
+		{"let f = fn(...xs) { len(xs) }; f(1, ...[2, 3], ...(4, 5), 6)", "6"},
+		{"let f = fn(a, b = 0, ...rest) { [a, b, rest] }; f(...[1, 2, 3])",
+			"[1, 2, [3]]"},
+		{"len(...[[1, 2]])", "2"},
+		{"let f = fn(...xs) { xs }; f(...range(3))",
+			"ERROR: cannot spread RANGE: want ARRAY or TUPLE"},
+		// Named arguments are kept for tail calls.
+		{`let count = fn(n, acc = 0) {
+			if (n == 0) { acc } else { count(n - 1, acc: acc + 1) }
+		};
+		count(100000)`, "100000"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		actual := evaluated.Inspect()
+		if err, ok := evaluated.(*object.Error); ok {
+			actual = "ERROR: " + err.Message
+		}
+		if actual != tt.expected {
+			t.Errorf("%q - expected %q. got %q", tt.input, tt.expected, actual)
+		}
+	}
+}
+
+func TestFunctionArgumentErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let f = fn(a, b = 1) { a }; f()",
+			"wrong number of arguments to `f`: want 1 to 2, got 0"},
+		{"let f = fn(a, b = 1) { a }; f(1, 2, 3)",
+			"wrong number of arguments to `f`: want 1 to 2, got 3"},
+		{"let f = fn(a, ...rest) { a }; f()",
+			"wrong number of arguments to `f`: want at least 1, got 0"},
+		{"fn(a, b = 1) { a }(1, 2, 3)",
+			"wrong number of arguments to `fn(a, b = 1)`: want 1 to 2, got 3"},
+		{"let f = fn(a, b) { a }; f(1, c: 2)", "`f` has no parameter c"},
+		{"let f = fn(a, b) { a }; f(1, a: 2)",
+			"duplicate argument for parameter a of `f`"},
+		{"let f = fn(a, b) { a }; f(b: 1, b: 2)",
+			"duplicate argument for parameter b of `f`"},
+		{"let f = fn(a, b) { a }; f(b: 1)",
+			"missing argument for parameter a of `f`"},
+		{"let f = fn(a, ...rest) { a }; f(1, rest: [])",
+			"rest parameter rest of `f` can't be passed by name"},
+		{"let f = fn(a = nope) { a }; f()", "identifier not found: nope"},
+		{"let f = fn(a) { a }; f(...1)", "cannot spread INTEGER: want ARRAY or TUPLE"},
+		{`len(x: "a")`, "`len` doesn't accept named arguments"},
+	}
+
+	for _, tt := range tests {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let x = 1; x = 5; x;", 5},
+		{"let x = 1; x = 5;", 5},
+		{"let x = 1; let y = 2; x = y = 7; x + y;", 14},
+		{"let x = 10; x += 5; x;", 15},
+		{"let x = 10; x -= 5; x;", 5},
+		{"let x = 10; x *= 5; x;", 50},
+		{"let x = 10; x /= 5; x;", 2},
+		{"let x = 10; x += x; x;", 20},
+		{"let x = 1; x = x + 1; x = x + 1; x;", 3},
+		// Assignment updates the binding in the scope that defines it.
+		{"let x = 1; let f = fn() { x = 2; }; f(); x;", 2},
+		{"let x = 1; let f = fn() { x += 10; }; f(); f(); x;", 21},
+		// A let inside a function shadows rather than updates.
+		{"let x = 1; let f = fn() { let x = 5; x = 6; }; f(); x;", 1},
+		{
+			`let counter = fn() {
+				let count = 0;
+				fn() { count += 1; count };
+			};
+			let next = counter();
+			next();
+			next();
+			next();`,
+			3,
+		},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestWhileLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let i = 0; while (i < 10) { i += 1; } i;", 10},
+		{"let i = 0; while (false) { i += 1; } i;", 0},
+		{"let i = 0; while (true) { i += 1; if (i == 5) { break; } } i;", 5},
+		{
+			`let i = 0; let odd = 0;
+			while (i < 10) {
+				i += 1;
+				if (i % 2 == 0) { continue; }
+				odd += 1;
+			}
+			odd;`,
+			5,
+		},
+		{
+			`let f = fn() {
+				let i = 0;
+				while (true) {
+					while (true) {
+						i += 1;
+						if (i > 3) { return i * 10; }
+					}
+				}
+				return -1;
+			};
+			f();`,
+			40,
+		},
+		{
+			// break only exits the innermost loop.
+			`let outer = 0; let inner = 0;
+			while (outer < 3) {
+				outer += 1;
+				while (true) {
+					inner += 1;
+					break;
+				}
+			}
+			inner;`,
+			3,
+		},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestForLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let total = 0; for (i in range(5)) { total += i; } total;", 10},
+		{"let total = 0; for (i in range(2, 5)) { total += i; } total;", 9},
+		{"let total = 0; for (i in range(0, 10, 3)) { total += i; } total;", 18},
+		{"let total = 0; for (i in range(5, 0, -2)) { total += i; } total;", 9},
+		{"let total = 0; for (i in range(5, 0)) { total += i; } total;", 0},
+		// Ranges stop at the limits of an integer rather than wrapping
+		// around.
+		{`let n = 0;
+		for (i in range(9223372036854775800, 9223372036854775807, 5)) { n += 1; }
+		n;`, 2},
+		{`let last = 0;
+		for (i in range(9223372036854775805, 9223372036854775807)) { last = i; }
+		last - 9223372036854775800;`, 6},
+		{`let n = 0;
+		for (i in range(-9223372036854775807 - 1 + 7, -9223372036854775807 - 1, -5)) { n += 1; }
+		n;`, 2},
+		{
+			`let total = 0;
+			for (i in range(100)) {
+				if (i % 2 == 1) { continue; }
+				if (i > 6) { break; }
+				total += i;
+			}
+			total;`,
+			12,
+		},
+		{
+			`let find = fn(target) {
+				for (i in range(10)) {
+					for (j in range(10)) {
+						if (i * j == target) { return i * 100 + j; }
+					}
+				}
+				-1;
+			};
+			find(12);`,
+			206,
+		},
+		{
+			// The loop variable doesn't leak out of the loop.
+			`let i = 42; for (i in range(3)) { } i;`,
+			42,
+		},
+		{
+			// Each iteration binds a fresh variable for closures to capture.
+			`let first = 0;
+			for (i in range(3)) {
+				if (i == 0) { first = fn() { i }; }
+			}
+			first();`,
+			0,
+		},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestLoopsEvaluateToNull(t *testing.T) {
+	testNullObject(t, testEval("while (false) { }"))
+	testNullObject(t, testEval("for (i in range(3)) { break; }"))
+}
+
+// break and continue inside an expression stop the evaluation of the
+// statement they're in, rather than becoming its value.
+func TestLoopControlInExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{
+			`let i = 0; let last = 0;
+			while (true) {
+				i += 1;
+				let y = if (i > 3) { break; } else { i };
+				last = y;
+			}
+			last * 10 + i;`,
+			34,
+		},
+		{
+			`let total = 0;
+			for (i in range(6)) {
+				let y = if (i % 2 == 0) { continue; } else { i };
+				total += y;
+			}
+			total;`,
+			9,
+		},
+		{
+			`let x = 0;
+			for (i in range(5)) {
+				x = if (i == 3) { break; } else { i };
+			}
+			x;`,
+			2,
+		},
+		{
+			`let total = 0;
+			for (i in range(5)) {
+				total += if (i == 1) { continue; } else { i };
+			}
+			total;`,
+			9,
+		},
+		{
+			`let calls = 0;
+			let f = fn(a, b) { calls += 1; a + b };
+			for (i in range(5)) {
+				f(i, if (i == 2) { break; } else { i });
+			}
+			calls;`,
+			2,
+		},
+		{
+			`let total = 0;
+			for (i in range(5)) {
+				total = total + (if (i == 4) { continue; } else { i }) * 10;
+			}
+			total;`,
+			60,
+		},
+		{
+			// The break belongs to the outer loop, as the condition isn't
+			// part of the inner loop.
+			`let n = 0;
+			for (i in range(10)) {
+				while (if (i == 3) { break; } else { false }) { }
+				n += 1;
+			}
+			n;`,
+			3,
+		},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestRangeBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"range(10)", "range(0, 10)"},
+		{"range(2, 10)", "range(2, 10)"},
+		{"range(10, 0, -1)", "range(10, 0, -1)"},
+		{"range()", "ERROR: wrong number of arguments to `range`: " +
+			"want 1 to 3, got 0"},
+		{"range(true)", "ERROR: argument to `range` must be INTEGER, " +
+			"got BOOLEAN"},
+		{"range(0, 10, 0)", "ERROR: `range` step must not be zero"},
+		{"for (i in 5) { }", "ERROR: cannot iterate over INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("expected %q. got %q", tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestTailCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{
+			// Without tail calls each step of the recursion would use
+			// more Go stack, and this would crash the process.
+			`let countdown = fn(n) {
+				if (n == 0) { 0 } else { countdown(n - 1) }
+			};
+			countdown(1000000);`,
+			0,
+		},
+		{
+			`let countdown = fn(n) {
+				match (n) { 0 => 0, _ => countdown(n - 1) }
+			};
+			countdown(1000000);`,
+			0,
+		},
+		{
+			`let countdown = fn(n) {
+				switch (n) { case 0: 0; default: countdown(n - 1) }
+			};
+			countdown(1000000);`,
+			0,
+		},
+		{
+			`let sum = fn(n, acc) {
+				if (n == 0) { return acc; }
+				return sum(n - 1, acc + n);
+			};
+			sum(100000, 0);`,
+			5000050000,
+		},
+		{
+			`let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } };
+			let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } };
+			if (isEven(100001)) { 1 } else { 0 }`,
+			0,
+		},
+		{
+			`let loop = fn(n) {
+				while (true) {
+					if (n == 0) { return 42; }
+					return loop(n - 1);
+				}
+			};
+			loop(100000);`,
+			42,
+		},
+		{
+			// Calls which aren't in tail position are still evaluated
+			// normally.
+			`let fact = fn(n) { if (n == 0) { 1 } else { n * fact(n - 1) } };
+			fact(10);`,
+			3628800,
+		},
+		{
+			`let double = fn(x) { x * 2 };
+			let apply = fn(f, x) { f(x) };
+			apply(double, 21);`,
+			42,
+		},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestTailCallErrors(t *testing.T) {
+	input := `
+let f = fn(n) { g(n, n) };
+let g = fn(n) { n };
+f(1);`
+	evaluated := testEval(input)
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got %T(%+v)", evaluated,
+			evaluated)
+	}
+	expected := "wrong number of arguments to `g`: want 1, got 2"
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected %q, got %q", expected,
+			errObj.Message)
+	}
+}
+
+func TestStringLiteral(t *testing.T) {
+	evaluated := testEval(`"Hello\t\"World\"!"`)
+	str, ok := evaluated.(*object.String)
+	if !ok {
+		t.Fatalf("object is not String. got %T (%+v)", evaluated, evaluated)
+	}
+	if str.Value != "Hello\t\"World\"!" {
+		t.Errorf("String has wrong value. got %q", str.Value)
+	}
+}
+
+func TestStringOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"Hello" + " " + "World!"`, "Hello World!"},
+		{`"a" == "a"`, true},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`let s = "a"; s += "b"; s`, "ab"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("object is not String. got %T (%+v)", evaluated,
+					evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("String has wrong value. expected %q, got %q",
+					expected, str.Value)
+			}
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestLenBuiltin(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("")`, 0},
+		{`len("four")`, 4},
+		{`len("héllo")`, 5},
+		{`len([1, 2, 3])`, 3},
+		{`len({"a": 1})`, 1},
+		{`len(1)`, "argument to `len` not supported, got INTEGER"},
+		{`len("one", "two")`, "wrong number of arguments to `len`: want 1, got 2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestArrayLiterals(t *testing.T) {
+	evaluated := testEval("[1, 2 * 2, 3 + 3]")
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got %T (%+v)", evaluated, evaluated)
+	}
+	if len(result.Elements) != 3 {
+		t.Fatalf("array has wrong num of elements. got %d",
+			len(result.Elements))
+	}
+	testIntegerObject(t, result.Elements[0], 1)
+	testIntegerObject(t, result.Elements[1], 4)
+	testIntegerObject(t, result.Elements[2], 6)
+}
+
+func TestIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"[1, 2, 3][0]", 1},
+		{"[1, 2, 3][2]", 3},
+		{"let i = 0; [1][i];", 1},
+		{"[1, 2, 3][1 + 1];", 3},
+		{"let myArray = [1, 2, 3]; myArray[0] + myArray[1] + myArray[2];", 6},
+		{"[1, 2, 3][3]", nil},
+		{"[1, 2, 3][-1]", nil},
+		{`{"foo": 5}["foo"]`, 5},
+		{`{"foo": 5}["bar"]`, nil},
+		{`let key = "foo"; {"foo": 5}[key]`, 5},
+		{`{}["foo"]`, nil},
+		{`"héllo"[4] == "o"`, true},
+		{`"héllo"[1] == "é"`, true},
+		{`"abc"[3]`, nil},
+		{`"abc"[-1]`, nil},
+		{`{5: 5}[5]`, 5},
+		{`{true: 5}[true]`, 5},
+		{`{false: 5}[false]`, 5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		default:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestHashLiterals(t *testing.T) {
+	input := `let two = "two";
+	{
+		"one": 10 - 9,
+		two: 1 + 1,
+		"thr" + "ee": 6 / 2,
+		4: 4,
+		true: 5,
+		false: 6
+	}`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Hash)
+	if !ok {
+		t.Fatalf("Eval didn't return Hash. got %T (%+v)", evaluated, evaluated)
+	}
+
+	expected := map[object.HashKey]int64{
+		(&object.String{Value: "one"}).HashKey():   1,
+		(&object.String{Value: "two"}).HashKey():   2,
+		(&object.String{Value: "three"}).HashKey(): 3,
+		(&object.Integer{Value: 4}).HashKey():      4,
+		TRUE.HashKey():                             5,
+		FALSE.HashKey():                            6,
+	}
+	if len(result.Pairs) != len(expected) {
+		t.Fatalf("Hash has wrong num of pairs. got %d", len(result.Pairs))
+	}
+	for expectedKey, expectedValue := range expected {
+		pair, ok := result.Pairs[expectedKey]
+		if !ok {
+			t.Errorf("no pair for given key in Pairs")
+			continue
+		}
+		testIntegerObject(t, pair.Value, expectedValue)
+	}
+
+	// Hashes keep their keys in insertion order.
+	inspected := `{one: 1, two: 2, three: 3, 4: 4, true: 5, false: 6}`
+	if result.Inspect() != inspected {
+		t.Errorf("wrong Inspect. expected %q, got %q", inspected,
+			result.Inspect())
+	}
+}
+
+func TestForLoopsOverCollections(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let s = 0; for (x in [1, 2, 3]) { s = s * 10 + x; } s;`, "123"},
+		{`let s = ""; for (c in "héllo") { s = c + s; } s;`, "olléh"},
+		{`let s = ""; for (k in {"b": 1, "a": 2}) { s += k; } s;`, "ba"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("expected %q. got %q", tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedPos token.Position
+	}{
+		{"5 + true;", token.Position{Line: 1, Column: 3}},
+		{"let x = 1;\nlet y = x / 0;", token.Position{Line: 2, Column: 11}},
+		{"if (true) {\n  throw 1;\n}", token.Position{Line: 2, Column: 3}},
+		{"let f = fn() {\n  foo\n};\nf();", token.Position{Line: 2, Column: 3}},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got %T(%+v)", evaluated,
+				evaluated)
+			continue
+		}
+		if errObj.Pos != tt.expectedPos {
+			t.Errorf("%q - wrong error position. expected %s, got %s",
+				tt.input, tt.expectedPos, errObj.Pos)
+		}
+	}
+}
+
 func testEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
 	program := p.ParseProgram()
+	env := object.NewEnvironment()
 
-	return Eval(program)
+	return Eval(program, env)
 }
 
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
@@ -80,3 +1732,11 @@ func testBooleanObject(t *testing.T, obj object.Object, expected bool) bool {
 	}
 	return true
 }
+
+func testNullObject(t *testing.T, obj object.Object) bool {
+	if obj != NULL {
+		t.Errorf("object is not NULL. got %T (%+v)", obj, obj)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,571 @@
+package evaluator
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/jamesroutley/monkey/object"
+	"github.com/jamesroutley/monkey/token"
+)
+
+// maxJSONDepth is the deepest nesting of arrays and objects json.parse and
+// json.stringify accept.
+const maxJSONDepth = 1000
+
+// jsonModule returns the members of the native json module.
+func (e *Evaluator) jsonModule() map[string]object.Object {
+	return map[string]object.Object{
+		"parse":     &object.Builtin{Name: "json.parse", Fn: e.jsonParse},
+		"stringify": &object.Builtin{Name: "json.stringify", Fn: e.jsonStringify},
+	}
+}
+
+// jsonParse implements parse(text), which decodes the JSON value in text.
+// Objects become hashes, with their keys in the order they appear in text.
+// Numbers must be integers.
+func (e *Evaluator) jsonParse(args ...object.Object) object.Object {
+	if err := checkArgs("json.parse", args, 1, object.STRING_OBJ); err != nil {
+		return err
+	}
+	d := &jsonDecoder{
+		evaluator: e,
+		text:      args[0].(*object.String).Value,
+		line:      1,
+		column:    1,
+	}
+	value, err := d.decode()
+	if err != nil {
+		return err
+	}
+	return value
+}
+
+// jsonDecoder decodes JSON text, keeping track of the line and column it's
+// up to for error messages. Like token.Position, columns count bytes. The
+// values it decodes are accounted for by its evaluator.
+type jsonDecoder struct {
+	evaluator *Evaluator
+	text      string
+	offset    int
+	line      int
+	column    int
+	depth     int
+}
+
+// alloc accounts for obj, a value the decoder has built.
+func (d *jsonDecoder) alloc(obj object.Object) (object.Object, *object.Error) {
+	if err := d.evaluator.account(objectSize(obj)); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
+
+func (d *jsonDecoder) decode() (object.Object, *object.Error) {
+	d.skipWhitespace()
+	value, err := d.value()
+	if err != nil {
+		return nil, err
+	}
+	d.skipWhitespace()
+	if d.offset < len(d.text) {
+		return nil, d.errorf("unexpected %s after value", d.describeNext())
+	}
+	return value, nil
+}
+
+func (d *jsonDecoder) errorf(format string, a ...interface{}) *object.Error {
+	pos := token.Position{Line: d.line, Column: d.column}
+	return newError("invalid JSON at %s: %s", pos, fmt.Sprintf(format, a...))
+}
+
+// describeNext describes the next character, for error messages.
+func (d *jsonDecoder) describeNext() string {
+	if d.offset >= len(d.text) {
+		return "end of input"
+	}
+	r, _ := utf8.DecodeRuneInString(d.text[d.offset:])
+	return strconv.QuoteRune(r)
+}
+
+// advance moves past the next n bytes.
+func (d *jsonDecoder) advance(n int) {
+	for i := d.offset; i < d.offset+n; i++ {
+		if d.text[i] == '\n' {
+			d.line++
+			d.column = 1
+		} else {
+			d.column++
+		}
+	}
+	d.offset += n
+}
+
+func (d *jsonDecoder) peek() byte {
+	if d.offset >= len(d.text) {
+		return 0
+	}
+	return d.text[d.offset]
+}
+
+func (d *jsonDecoder) skipWhitespace() {
+	for {
+		switch d.peek() {
+		case ' ', '\t', '\n', '\r':
+			d.advance(1)
+		default:
+			return
+		}
+	}
+}
+
+// expect moves past the next byte if it's ch, and returns an error if it
+// isn't.
+func (d *jsonDecoder) expect(ch byte, context string) *object.Error {
+	if d.peek() != ch {
+		return d.errorf("expected %q %s, got %s", ch, context,
+			d.describeNext())
+	}
+	d.advance(1)
+	return nil
+}
+
+func (d *jsonDecoder) value() (object.Object, *object.Error) {
+	switch ch := d.peek(); {
+	case ch == '{':
+		return d.object()
+	case ch == '[':
+		return d.array()
+	case ch == '"':
+		s, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		return d.alloc(&object.String{Value: s})
+	case ch == '-' || '0' <= ch && ch <= '9':
+		return d.number()
+	case strings.HasPrefix(d.text[d.offset:], "true"):
+		d.advance(len("true"))
+		return TRUE, nil
+	case strings.HasPrefix(d.text[d.offset:], "false"):
+		d.advance(len("false"))
+		return FALSE, nil
+	case strings.HasPrefix(d.text[d.offset:], "null"):
+		d.advance(len("null"))
+		return NULL, nil
+	default:
+		return nil, d.errorf("unexpected %s, expected a value",
+			d.describeNext())
+	}
+}
+
+func (d *jsonDecoder) enter() *object.Error {
+	d.depth++
+	if d.depth > maxJSONDepth {
+		return d.errorf("nesting deeper than %d levels", maxJSONDepth)
+	}
+	return nil
+}
+
+func (d *jsonDecoder) object() (object.Object, *object.Error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { d.depth-- }()
+
+	hash := object.NewHash()
+	d.advance(1)
+	d.skipWhitespace()
+	if d.peek() == '}' {
+		d.advance(1)
+		return d.alloc(hash)
+	}
+	for {
+		if d.peek() != '"' {
+			return nil, d.errorf("expected string for object key, got %s",
+				d.describeNext())
+		}
+		key, err := d.string()
+		if err != nil {
+			return nil, err
+		}
+		d.skipWhitespace()
+		if err := d.expect(':', "after object key"); err != nil {
+			return nil, err
+		}
+		d.skipWhitespace()
+		value, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		keyObj, err := d.alloc(&object.String{Value: key})
+		if err != nil {
+			return nil, err
+		}
+		hash.Set(keyObj.(*object.String), value)
+
+		d.skipWhitespace()
+		if d.peek() == '}' {
+			d.advance(1)
+			return d.alloc(hash)
+		}
+		if err := d.expect(',', "or '}' after object value"); err != nil {
+			return nil, err
+		}
+		d.skipWhitespace()
+	}
+}
+
+func (d *jsonDecoder) array() (object.Object, *object.Error) {
+	if err := d.enter(); err != nil {
+		return nil, err
+	}
+	defer func() { d.depth-- }()
+
+	array := &object.Array{Elements: []object.Object{}}
+	d.advance(1)
+	d.skipWhitespace()
+	if d.peek() == ']' {
+		d.advance(1)
+		return d.alloc(array)
+	}
+	for {
+		value, err := d.value()
+		if err != nil {
+			return nil, err
+		}
+		array.Elements = append(array.Elements, value)
+
+		d.skipWhitespace()
+		if d.peek() == ']' {
+			d.advance(1)
+			return d.alloc(array)
+		}
+		if err := d.expect(',', "or ']' after array element"); err != nil {
+			return nil, err
+		}
+		d.skipWhitespace()
+	}
+}
+
+func (d *jsonDecoder) number() (object.Object, *object.Error) {
+	start, end := d.offset, d.offset
+	if d.text[end] == '-' {
+		end++
+	}
+	digitsStart := end
+	for end < len(d.text) && '0' <= d.text[end] && d.text[end] <= '9' {
+		end++
+	}
+	switch {
+	case end == digitsStart:
+		return nil, d.errorf("invalid number: expected digit after '-'")
+	case d.text[digitsStart] == '0' && end-digitsStart > 1:
+		return nil, d.errorf("invalid number %s: leading zero",
+			d.text[start:end])
+	}
+	if end < len(d.text) && strings.IndexByte(".eE", d.text[end]) >= 0 {
+		for end < len(d.text) &&
+			strings.IndexByte("0123456789.eE+-", d.text[end]) >= 0 {
+			end++
+		}
+		return nil, d.errorf("number %s is not an integer", d.text[start:end])
+	}
+
+	literal := d.text[start:end]
+	value, err := strconv.ParseInt(literal, 10, 64)
+	if err != nil {
+		return nil, d.errorf("number %s is out of range", literal)
+	}
+	d.advance(end - start)
+	return d.alloc(&object.Integer{Value: value})
+}
+
+// string decodes the string starting at the next byte, which is a quote.
+func (d *jsonDecoder) string() (string, *object.Error) {
+	var out strings.Builder
+	d.advance(1)
+	for {
+		if d.offset >= len(d.text) {
+			return "", d.errorf("unterminated string")
+		}
+		ch := d.text[d.offset]
+		switch {
+		case ch == '"':
+			d.advance(1)
+			return out.String(), nil
+		case ch == '\\':
+			r, err := d.escape()
+			if err != nil {
+				return "", err
+			}
+			out.WriteRune(r)
+		case ch < 0x20:
+			return "", d.errorf("invalid control character %q in string",
+				ch)
+		default:
+			out.WriteByte(ch)
+			d.advance(1)
+		}
+	}
+}
+
+var jsonEscapes = map[byte]rune{
+	'"':  '"',
+	'\\': '\\',
+	'/':  '/',
+	'b':  '\b',
+	'f':  '\f',
+	'n':  '\n',
+	'r':  '\r',
+	't':  '\t',
+}
+
+// escape decodes the escape sequence starting at the next byte, which is a
+// backslash.
+func (d *jsonDecoder) escape() (rune, *object.Error) {
+	if d.offset+1 >= len(d.text) {
+		return 0, d.errorf("unterminated string")
+	}
+	ch := d.text[d.offset+1]
+	if r, ok := jsonEscapes[ch]; ok {
+		d.advance(2)
+		return r, nil
+	}
+	if ch != 'u' {
+		return 0, d.errorf("invalid escape sequence \\%c", ch)
+	}
+
+	r, err := d.hex4()
+	if err != nil {
+		return 0, err
+	}
+	if utf16.IsSurrogate(r) && strings.HasPrefix(d.text[d.offset:], `\u`) {
+		offset, line, column := d.offset, d.line, d.column
+		low, err := d.hex4()
+		if err == nil {
+			if decoded := utf16.DecodeRune(r, low); decoded != utf8.RuneError {
+				return decoded, nil
+			}
+		}
+		d.offset, d.line, d.column = offset, line, column
+	}
+	if utf16.IsSurrogate(r) {
+		return utf8.RuneError, nil
+	}
+	return r, nil
+}
+
+// hex4 decodes a \uXXXX escape sequence.
+func (d *jsonDecoder) hex4() (rune, *object.Error) {
+	if d.offset+6 > len(d.text) {
+		return 0, d.errorf("invalid escape sequence %s",
+			d.text[d.offset:])
+	}
+	sequence := d.text[d.offset : d.offset+6]
+	value, err := strconv.ParseUint(sequence[2:], 16, 16)
+	if err != nil {
+		return 0, d.errorf("invalid escape sequence %s", sequence)
+	}
+	d.advance(6)
+	return rune(value), nil
+}
+
+// jsonStringify implements stringify(value) and stringify(value, indent),
+// which encode value as JSON. Hash keys are sorted, so the output is
+// deterministic, and tuples are encoded as arrays. If indent, a number of
+// spaces, is given, the output is spread over multiple lines.
+func (e *Evaluator) jsonStringify(args ...object.Object) object.Object {
+	if err := checkArgCount("json.stringify", args, 1, 2); err != nil {
+		return err
+	}
+	enc := &jsonEncoder{
+		evaluator: e,
+		visiting:  make(map[object.Object]bool),
+	}
+	if len(args) == 2 {
+		indent, ok := args[1].(*object.Integer)
+		if !ok {
+			return newError("argument to `json.stringify` must be INTEGER, "+
+				"got %s", object.TypeName(args[1]))
+		}
+		if indent.Value < 0 || indent.Value > 16 {
+			return newError("`json.stringify` indent must be between 0 and "+
+				"16, got %d", indent.Value)
+		}
+		enc.indent = strings.Repeat(" ", int(indent.Value))
+	}
+	if err := enc.encode(args[0], 0); err != nil {
+		return err
+	}
+	return e.alloc(&object.String{Value: enc.out.String()})
+}
+
+// jsonEncoder encodes objects as JSON. The output is checked against its
+// evaluator's limits as it grows, as a value which refers to the same array
+// many times can encode to much more text than it takes memory.
+type jsonEncoder struct {
+	evaluator *Evaluator
+	out       strings.Builder
+	indent    string // Empty for compact output.
+
+	// visiting holds the arrays and hashes being encoded, to detect cycles.
+	visiting map[object.Object]bool
+}
+
+func (e *jsonEncoder) encode(obj object.Object, depth int) *object.Error {
+	switch obj := obj.(type) {
+	case *object.Null:
+		e.out.WriteString("null")
+	case *object.Boolean:
+		e.out.WriteString(strconv.FormatBool(obj.Value))
+	case *object.Integer:
+		e.out.WriteString(strconv.FormatInt(obj.Value, 10))
+	case *object.String:
+		writeJSONString(&e.out, obj.Value)
+	case *object.Array:
+		if err := e.enter(obj, depth); err != nil {
+			return err
+		}
+		defer delete(e.visiting, obj)
+		if err := e.encodeArray(obj.Elements, depth); err != nil {
+			return err
+		}
+	case *object.Tuple:
+		// Tuples are encoded as arrays.
+		if err := e.enter(obj, depth); err != nil {
+			return err
+		}
+		defer delete(e.visiting, obj)
+		if err := e.encodeArray(obj.Elements, depth); err != nil {
+			return err
+		}
+	case *object.Hash:
+		if err := e.enter(obj, depth); err != nil {
+			return err
+		}
+		defer delete(e.visiting, obj)
+		if err := e.encodeHash(obj, depth); err != nil {
+			return err
+		}
+	default:
+		return newError("`json.stringify` cannot encode %s", object.TypeName(obj))
+	}
+	return e.evaluator.checkStringLength("json.stringify",
+		int64(e.out.Len()))
+}
+
+func (e *jsonEncoder) enter(obj object.Object, depth int) *object.Error {
+	if e.visiting[obj] {
+		return newError("`json.stringify` cannot encode cyclic %s",
+			object.TypeName(obj))
+	}
+	if depth >= maxJSONDepth {
+		return newError("`json.stringify` nesting deeper than %d levels",
+			maxJSONDepth)
+	}
+	e.visiting[obj] = true
+	return nil
+}
+
+func (e *jsonEncoder) encodeArray(
+	elements []object.Object,
+	depth int,
+) *object.Error {
+	if len(elements) == 0 {
+		e.out.WriteString("[]")
+		return nil
+	}
+	e.out.WriteByte('[')
+	for i, el := range elements {
+		if i > 0 {
+			e.out.WriteByte(',')
+		}
+		e.newline(depth + 1)
+		if err := e.encode(el, depth+1); err != nil {
+			return err
+		}
+	}
+	e.newline(depth)
+	e.out.WriteByte(']')
+	return nil
+}
+
+func (e *jsonEncoder) encodeHash(hash *object.Hash, depth int) *object.Error {
+	if len(hash.Keys) == 0 {
+		e.out.WriteString("{}")
+		return nil
+	}
+	pairs := hash.OrderedPairs()
+	keys := make([]string, len(pairs))
+	values := make(map[string]object.Object, len(pairs))
+	for i, pair := range pairs {
+		key, ok := pair.Key.(*object.String)
+		if !ok {
+			return newError("`json.stringify` hash keys must be STRING, "+
+				"got %s", object.TypeName(pair.Key))
+		}
+		keys[i] = key.Value
+		values[key.Value] = pair.Value
+	}
+	sort.Strings(keys)
+
+	e.out.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			e.out.WriteByte(',')
+		}
+		e.newline(depth + 1)
+		writeJSONString(&e.out, key)
+		e.out.WriteByte(':')
+		if e.indent != "" {
+			e.out.WriteByte(' ')
+		}
+		if err := e.encode(values[key], depth+1); err != nil {
+			return err
+		}
+	}
+	e.newline(depth)
+	e.out.WriteByte('}')
+	return nil
+}
+
+// newline starts a new line indented to depth, if the output is indented.
+func (e *jsonEncoder) newline(depth int) {
+	if e.indent == "" {
+		return
+	}
+	e.out.WriteByte('\n')
+	for i := 0; i < depth; i++ {
+		e.out.WriteString(e.indent)
+	}
+}
+
+// writeJSONString writes s to out as a JSON string.
+func writeJSONString(out *strings.Builder, s string) {
+	out.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			out.WriteString(`\"`)
+		case '\\':
+			out.WriteString(`\\`)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\r':
+			out.WriteString(`\r`)
+		case '\t':
+			out.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(out, `\u%04x`, r)
+			} else {
+				out.WriteRune(r)
+			}
+		}
+	}
+	out.WriteByte('"')
+}
@@ -0,0 +1,190 @@
+package evaluator
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/jamesroutley/monkey/lexer"
+	"github.com/jamesroutley/monkey/object"
+	"github.com/jamesroutley/monkey/parser"
+)
+
+func TestJSONParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`1`, `1`},
+		{`-42`, `-42`},
+		{`true`, `true`},
+		{`false`, `false`},
+		{`null`, `null`},
+		{`"hi"`, `hi`},
+		{`"a\"b\\c\/d\né😀"`, "a\"b\\c/d\né😀"},
+		{`[]`, `[]`},
+		{`{}`, `{}`},
+		{` [1, "two", [true, null]] `, `[1, two, [true, null]]`},
+		// Keys keep their order, and later duplicates win.
+		{`{"b": 1, "a": {"c": []}, "b": 2}`, `{b: 2, a: {c: []}}`},
+		{"{\n  \"x\": 9223372036854775807\n}", `{x: 9223372036854775807}`},
+
+		{``, "ERROR: invalid JSON at 1:1: unexpected end of input, expected a value"},
+		{`[1, 2`, "ERROR: invalid JSON at 1:6: expected ',' or ']' after array element, got end of input"},
+		{`[1 2]`, "ERROR: invalid JSON at 1:4: expected ',' or ']' after array element, got '2'"},
+		{`{"a" 1}`, "ERROR: invalid JSON at 1:6: expected ':' after object key, got '1'"},
+		{`{a: 1}`, "ERROR: invalid JSON at 1:2: expected string for object key, got 'a'"},
+		{"{\n  \"a\": tru\n}", "ERROR: invalid JSON at 2:8: unexpected 't', expected a value"},
+		{`1.5`, "ERROR: invalid JSON at 1:1: number 1.5 is not an integer"},
+		{`[1e3]`, "ERROR: invalid JSON at 1:2: number 1e3 is not an integer"},
+		{`01`, "ERROR: invalid JSON at 1:1: invalid number 01: leading zero"},
+		{`-`, "ERROR: invalid JSON at 1:1: invalid number: expected digit after '-'"},
+		{`99999999999999999999`, "ERROR: invalid JSON at 1:1: number 99999999999999999999 is out of range"},
+		{`"abc`, "ERROR: invalid JSON at 1:5: unterminated string"},
+		{`"\x"`, `ERROR: invalid JSON at 1:2: invalid escape sequence \x`},
+		{`"\u12"`, `ERROR: invalid JSON at 1:2: invalid escape sequence \u12"`},
+		{"\"a\tb\"", `ERROR: invalid JSON at 1:3: invalid control character '\t' in string`},
+		{`1 2`, "ERROR: invalid JSON at 1:3: unexpected '2' after value"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithJSON(t, "json.parse(text)", tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s - expected %q. got %q", tt.input, tt.expected,
+				evaluated.Inspect())
+		}
+	}
+
+	evaluated := testEval(`(import "json").parse(1)`)
+	testErrorObject(t, evaluated,
+		"argument to `json.parse` must be STRING, got INTEGER")
+}
+
+func TestJSONParseTypes(t *testing.T) {
+	evaluated := testEvalWithJSON(t,
+		`let v = json.parse(text); [v["n"] + 1, v["b"] == true, v["z"]]`,
+		`{"n": 1, "b": true, "z": null}`)
+	array, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got %T (%+v)", evaluated, evaluated)
+	}
+	testIntegerObject(t, array.Elements[0], 2)
+	testBooleanObject(t, array.Elements[1], true)
+	testNullObject(t, array.Elements[2])
+}
+
+func TestJSONStringify(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`json.stringify(1)`, `1`},
+		{`json.stringify(true)`, `true`},
+		{`json.stringify(if (false) { 1 })`, `null`},
+		{`json.stringify("a\"b\\c\n\té")`, `"a\"b\\c\n\té"`},
+		{`json.stringify([])`, `[]`},
+		{`json.stringify({})`, `{}`},
+		{`json.stringify([1, "two", [true]])`, `[1,"two",[true]]`},
+		// Tuples are encoded as arrays.
+		{`json.stringify((1, "two"))`, `[1,"two"]`},
+		{`json.stringify({"a": (1, (2, [3]))}, 1)`, `{
+ "a": [
+  1,
+  [
+   2,
+   [
+    3
+   ]
+  ]
+ ]
+}`},
+		{`json.stringify((1, fn() {}))`, "ERROR: `json.stringify` cannot encode FUNCTION"},
+		{`json.stringify({"b": 1, "a": [2], "c": {}})`, `{"a":[2],"b":1,"c":{}}`},
+		{`json.stringify({"b": 1, "a": [2, 3], "c": {}}, 2)`, `{
+  "a": [
+    2,
+    3
+  ],
+  "b": 1,
+  "c": {}
+}`},
+		{`json.stringify(json.parse(text))`, `{"a":[1,{"b":null}],"c":"d"}`},
+
+		{`json.stringify(fn(x) { x })`, "ERROR: `json.stringify` cannot encode FUNCTION"},
+		{`json.stringify([1, len])`, "ERROR: `json.stringify` cannot encode BUILTIN"},
+		{`json.stringify({1: 2})`, "ERROR: `json.stringify` hash keys must be STRING, got INTEGER"},
+		{`json.stringify()`, "ERROR: wrong number of arguments to `json.stringify`: want 1 to 2, got 0"},
+		{`json.stringify(1, "  ")`, "ERROR: argument to `json.stringify` must be INTEGER, got STRING"},
+		{`json.stringify(1, -1)`, "ERROR: `json.stringify` indent must be between 0 and 16, got -1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithJSON(t, tt.input,
+			`{"c": "d", "a": [1, {"b": null}]}`)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s - expected %q. got %q", tt.input, tt.expected,
+				evaluated.Inspect())
+		}
+	}
+}
+
+func TestJSONStringifyCycles(t *testing.T) {
+	jsonStringify := New(context.Background(), Options{}).jsonStringify
+	array := &object.Array{}
+	array.Elements = []object.Object{&object.Integer{Value: 1}, array}
+	testErrorObject(t, jsonStringify(array),
+		"`json.stringify` cannot encode cyclic ARRAY")
+
+	hash := object.NewHash()
+	hash.Set(&object.String{Value: "self"}, &object.Array{
+		Elements: []object.Object{hash},
+	})
+	testErrorObject(t, jsonStringify(hash),
+		"`json.stringify` cannot encode cyclic HASH")
+
+	// The same value may appear more than once, as long as it doesn't
+	// contain itself.
+	shared := &object.Array{Elements: []object.Object{}}
+	result := jsonStringify(&object.Array{
+		Elements: []object.Object{shared, shared},
+	})
+	if result.Inspect() != "[[],[]]" {
+		t.Errorf("wrong result. expected %q, got %q", "[[],[]]",
+			result.Inspect())
+	}
+}
+
+// testEvalWithJSON evaluates input with the json module bound to json and
+// text bound to the string text.
+func testEvalWithJSON(t *testing.T, input, text string) object.Object {
+	t.Helper()
+	l := lexer.New(`let json = import "json"; ` + input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	env := object.NewEnvironment()
+	env.Set("text", &object.String{Value: text})
+	return Eval(program, env)
+}
+
+// The values json.parse decodes, and the text json.stringify encodes, count
+// towards the program's byte limit.
+func TestJSONMemoryLimit(t *testing.T) {
+	tests := []string{
+		`json.parse("[` + strings.Repeat("1,", 20000) + `1]")`,
+		`json.parse("[` + strings.Repeat(`\"a\",`, 10000) + `\"a\"]")`,
+		`json.parse("{` + strings.Repeat(`\"a\": {`, 999) +
+			strings.Repeat("}", 999) + `}")`,
+		// Sharing makes a for a small array which encodes to a lot of text.
+		`let a = [1]; for (i in range(40)) { a = [a, a]; } json.stringify(a)`,
+	}
+
+	for _, input := range tests {
+		evaluated := testEvalWithOptions(context.Background(),
+			`let json = import "json"; `+input, Options{MaxBytes: 100000})
+		testErrorObject(t, evaluated,
+			"memory limit exceeded: more than 100000 bytes")
+	}
+}
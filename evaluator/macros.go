@@ -0,0 +1,474 @@
+package evaluator
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/jamesroutley/monkey/ast"
+	"github.com/jamesroutley/monkey/object"
+	"github.com/jamesroutley/monkey/token"
+)
+
+// maxExpansionDepth is the maximum number of macro expansions which may be
+// nested, e.g. by a macro which returns a call to itself.
+const maxExpansionDepth = 100
+
+// ExpandMacros expands the macros in program, with no limits on the
+// resources the macros may use. See Evaluator.ExpandMacros.
+func ExpandMacros(
+	program *ast.Program,
+	env *object.Environment,
+) (*ast.Program, *object.Error) {
+	return New(context.Background(), Options{}).ExpandMacros(program, env)
+}
+
+// ExpandMacros returns a copy of program which is ready to be evaluated.
+// The let statements at the top level of program which bind macro literals
+// are removed, and the macros bound in env instead. Then each call to a
+// macro is replaced by the AST it returns, when called with its arguments
+// quoted rather than evaluated.
+//
+// env should be kept apart from the environment program is evaluated in, as
+// macros are called before the program runs. It can be reused, e.g. by the
+// REPL, so that a program can call the macros defined by earlier ones.
+//
+// Expansion is hygienic: the bindings made by the code a macro returns, by
+// let statements, function parameters, for loops and match patterns, are
+// renamed, so they can't capture or shadow the bindings of the code which
+// calls the macro.
+// The new names can't be written in Monkey source, so can't clash either.
+func (e *Evaluator) ExpandMacros(
+	program *ast.Program,
+	env *object.Environment,
+) (*ast.Program, *object.Error) {
+	program = defineMacros(program, env)
+	expanded, err := e.expandMacroCalls(program, env)
+	if err != nil {
+		return nil, err
+	}
+	return expanded.(*ast.Program), nil
+}
+
+// defineMacros returns a copy of program without the let statements at its
+// top level which bind macro literals, binding the macros in env instead.
+func defineMacros(program *ast.Program, env *object.Environment) *ast.Program {
+	defined := &ast.Program{}
+	for _, stmt := range program.Statements {
+		if let, ok := stmt.(*ast.LetStatement); ok {
+			if lit, ok := let.Value.(*ast.MacroLiteral); ok {
+				env.Set(let.Name.Value, &object.Macro{
+					Parameters: lit.Parameters,
+					Body:       lit.Body,
+					Env:        env,
+				})
+				continue
+			}
+		}
+		defined.Statements = append(defined.Statements, stmt)
+	}
+	return defined
+}
+
+// expandMacroCalls returns a copy of node in which each call to a macro
+// bound in env has been expanded.
+func (e *Evaluator) expandMacroCalls(
+	node ast.Node,
+	env *object.Environment,
+) (ast.Node, *object.Error) {
+	var err *object.Error
+	expanded := ast.Modify(node, func(node ast.Node) ast.Node {
+		if err != nil {
+			return node
+		}
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return node
+		}
+		ident, ok := call.Function.(*ast.Identifier)
+		if !ok {
+			return node
+		}
+		macro, ok := env.Get(ident.Value)
+		if !ok {
+			return node
+		}
+		if macro, ok := macro.(*object.Macro); ok {
+			var result ast.Node
+			result, err = e.expandMacroCall(call, ident.Value, macro, env)
+			if err != nil {
+				if !err.Pos.IsValid() {
+					err.Pos = call.Pos()
+				}
+				return node
+			}
+			return result
+		}
+		return node
+	})
+	return expanded, err
+}
+
+// expandMacroCall calls macro, with call's arguments quoted, and returns the
+// AST it returns, with any macro calls in it expanded in turn.
+func (e *Evaluator) expandMacroCall(
+	call *ast.CallExpression,
+	name string,
+	macro *object.Macro,
+	env *object.Environment,
+) (ast.Node, *object.Error) {
+	if e.expansions >= maxExpansionDepth {
+		return nil, newError("macro expansion too deep: more than %d "+
+			"nested expansions", maxExpansionDepth)
+	}
+	for _, arg := range call.Arguments {
+		switch arg.(type) {
+		case *ast.NamedArgument, *ast.SpreadExpression:
+			return nil, newError("macro %s can't be passed named or spread "+
+				"arguments", name)
+		}
+	}
+	if len(call.Arguments) != len(macro.Parameters) {
+		return nil, newError("wrong number of arguments to macro %s: "+
+			"want %d, got %d", name, len(macro.Parameters),
+			len(call.Arguments))
+	}
+	macroEnv, err := e.newEnclosedEnvironment(macro.Env)
+	if err != nil {
+		return nil, err
+	}
+	for i, param := range macro.Parameters {
+		macroEnv.Set(param.Value, &object.Quote{Node: call.Arguments[i]})
+	}
+
+	e.expansions++
+	defer func() { e.expansions-- }()
+
+	evaluated := unwrapReturnValue(e.Eval(macro.Body, macroEnv))
+	if evaluated == nil {
+		// The macro's body is empty, or ends with a statement.
+		evaluated = NULL
+	}
+	switch evaluated := evaluated.(type) {
+	case *object.Quote:
+		return e.expandMacroCalls(evaluated.Node, env)
+	case *object.Error:
+		return nil, newError("macro %s: %s: %s", name, evaluated.Pos,
+			evaluated.Message)
+	default:
+		return nil, newError("macro %s must return a quoted AST, got %s",
+			name, object.TypeName(evaluated))
+	}
+}
+
+// evalQuote implements quote(exp), which returns exp unevaluated, except for
+// calls to unquote within it.
+func (e *Evaluator) evalQuote(
+	node *ast.CallExpression,
+	env *object.Environment,
+) object.Object {
+	if len(node.Arguments) != 1 {
+		return newError("wrong number of arguments to `quote`: want 1, got %d",
+			len(node.Arguments))
+	}
+	quoted, err := e.evalUnquoteCalls(node.Arguments[0], env)
+	if err != nil {
+		return err
+	}
+	return e.alloc(&object.Quote{Node: quoted})
+}
+
+// evalUnquoteCalls returns a copy of quoted in which each call to unquote
+// has been replaced by the AST for the value of its argument. While macros
+// are being expanded, the bindings made by quoted are renamed too.
+func (e *Evaluator) evalUnquoteCalls(
+	quoted ast.Node,
+	env *object.Environment,
+) (ast.Node, *object.Error) {
+	var renames map[*ast.Identifier]string
+	if e.expansions > 0 {
+		renames = e.hygienicNames(quoted)
+	}
+
+	var err *object.Error
+	modified := ast.Modify(quoted, func(node ast.Node) ast.Node {
+		if err != nil {
+			return node
+		}
+		switch node := node.(type) {
+		case *ast.Identifier:
+			if name, ok := renames[node]; ok {
+				renamed := *node
+				renamed.Value = name
+				renamed.Token.Literal = name
+				return &renamed
+			}
+		case *ast.CallExpression:
+			if !isUnquoteCall(node) {
+				return node
+			}
+			var unquoted ast.Node
+			unquoted, err = e.evalUnquoteCall(node, env)
+			if err != nil {
+				return node
+			}
+			return unquoted
+		}
+		return node
+	})
+	return modified, err
+}
+
+// evalUnquoteCall evaluates unquote(exp), returning the AST for the value of
+// exp.
+func (e *Evaluator) evalUnquoteCall(
+	call *ast.CallExpression,
+	env *object.Environment,
+) (ast.Node, *object.Error) {
+	if len(call.Arguments) != 1 {
+		err := newError("wrong number of arguments to `unquote`: want 1, "+
+			"got %d", len(call.Arguments))
+		err.Pos = call.Pos()
+		return nil, err
+	}
+	evaluated := e.Eval(call.Arguments[0], env)
+	if err, ok := evaluated.(*object.Error); ok {
+		return nil, err
+	}
+
+	tok := token.Token{Pos: call.Pos()}
+	switch evaluated := evaluated.(type) {
+	case *object.Quote:
+		return evaluated.Node, nil
+	case *object.Integer:
+		tok.Type = token.INT
+		tok.Literal = strconv.FormatInt(evaluated.Value, 10)
+		return &ast.IntegerLiteral{Token: tok, Value: evaluated.Value}, nil
+	case *object.Boolean:
+		tok.Type = token.FALSE
+		if evaluated.Value {
+			tok.Type = token.TRUE
+		}
+		tok.Literal = strconv.FormatBool(evaluated.Value)
+		return &ast.Boolean{Token: tok, Value: evaluated.Value}, nil
+	case *object.String:
+		tok.Type = token.STRING
+		tok.Literal = evaluated.Value
+		return &ast.StringLiteral{Token: tok, Value: evaluated.Value}, nil
+	default:
+		err := newError("cannot unquote %s: want INTEGER, BOOLEAN, STRING "+
+			"or QUOTE", object.TypeName(evaluated))
+		err.Pos = call.Pos()
+		return nil, err
+	}
+}
+
+// hygienicNames returns new names for the identifiers in quoted, outside of
+// calls to unquote, which refer to bindings made in quoted. Each binding is
+// given a name which is unique within e's run, and which the lexer wouldn't
+// read as a single identifier, so it can't clash with any other name.
+//
+// An identifier refers to a binding if it's in the binding's scope: after
+// it in the environment it's made in, or anywhere in a function defined in
+// that environment, as the function's body isn't evaluated until it's
+// called. So the x in quote(let x = x + 1) is only renamed after the =.
+func (e *Evaluator) hygienicNames(quoted ast.Node) map[*ast.Identifier]string {
+	type binding struct {
+		name  string
+		order int
+	}
+	// scope holds the bindings made in one environment.
+	type scope struct {
+		outer    *scope
+		function bool
+		bindings map[string]binding
+	}
+	type reference struct {
+		ident *ast.Identifier
+		scope *scope
+		order int
+	}
+
+	renames := map[*ast.Identifier]string{}
+	var refs []reference
+	order := 0
+	current := &scope{bindings: map[string]binding{}}
+	push := func(function bool) {
+		current = &scope{
+			outer:    current,
+			function: function,
+			bindings: map[string]binding{},
+		}
+	}
+	pop := func() {
+		current = current.outer
+	}
+	bind := func(ident *ast.Identifier) {
+		b, ok := current.bindings[ident.Value]
+		if !ok {
+			e.renamed++
+			b = binding{
+				name:  fmt.Sprintf("%s_%d", ident.Value, e.renamed),
+				order: order,
+			}
+			current.bindings[ident.Value] = b
+		}
+		renames[ident] = b.name
+		order++
+	}
+
+	var visit func(ast.Node) bool
+	visit = func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.CallExpression:
+			// Unquoted code belongs to the macro, not to quoted.
+			return !isUnquoteCall(node)
+		case *ast.MemberExpression:
+			// Member names aren't bindings.
+			ast.Inspect(node.Object, visit)
+			return false
+		case *ast.LetStatement:
+			// The value is evaluated before the names are bound.
+			ast.Inspect(node.Value, visit)
+			if node.Name != nil {
+				bind(node.Name)
+			}
+			for _, name := range patternNames(node.Pattern) {
+				bind(name)
+			}
+			return false
+		case *ast.FunctionLiteral:
+			push(true)
+			for _, param := range node.Parameters {
+				ast.Inspect(param.Default, visit)
+				bind(param.Name)
+			}
+			ast.Inspect(node.Body, visit)
+			pop()
+			return false
+		case *ast.NamedArgument:
+			// Argument names belong to the function being called.
+			ast.Inspect(node.Value, visit)
+			return false
+		case *ast.ForStatement:
+			ast.Inspect(node.Iterable, visit)
+			push(false)
+			bind(node.Variable)
+			ast.Inspect(node.Body, visit)
+			pop()
+			return false
+		case *ast.TryExpression:
+			ast.Inspect(node.Body, visit)
+			push(false)
+			if node.CatchName != nil {
+				bind(node.CatchName)
+			}
+			ast.Inspect(node.Catch, visit)
+			pop()
+			ast.Inspect(node.Finally, visit)
+			return false
+		case *ast.StructStatement:
+			// Field names aren't bindings.
+			bind(node.Name)
+			return false
+		case *ast.EnumStatement:
+			// Variant and field names aren't bindings.
+			bind(node.Name)
+			return false
+		case *ast.ImplStatement:
+			// Method names aren't bindings.
+			ast.Inspect(node.Type, visit)
+			for _, m := range node.Methods {
+				ast.Inspect(m.Function, visit)
+			}
+			return false
+		case *ast.WithExpression:
+			ast.Inspect(node.Struct, visit)
+			for _, field := range node.Fields {
+				ast.Inspect(field.Value, visit)
+			}
+			return false
+		case *ast.MatchExpression:
+			ast.Inspect(node.Value, visit)
+			for _, arm := range node.Arms {
+				push(false)
+				for _, pattern := range arm.Patterns {
+					for _, ident := range patternNames(pattern) {
+						if ident.Value != "_" {
+							bind(ident)
+						}
+					}
+					if _, ok := pattern.(*ast.Identifier); !ok {
+						ast.Inspect(pattern, visit)
+					}
+				}
+				ast.Inspect(arm.Guard, visit)
+				ast.Inspect(arm.Body, visit)
+				pop()
+			}
+			return false
+		case *ast.VariantPattern:
+			// Variant names aren't bindings, and the names in its fields
+			// have already been bound.
+			ast.Inspect(node.Enum, visit)
+			for _, field := range node.Fields {
+				if _, ok := field.(*ast.VariantPattern); ok {
+					ast.Inspect(field, visit)
+				}
+			}
+			return false
+		case *ast.Identifier:
+			refs = append(refs, reference{ident: node, scope: current,
+				order: order})
+			order++
+		}
+		return true
+	}
+	ast.Inspect(quoted, visit)
+
+	for _, ref := range refs {
+		deferred := false
+		for s := ref.scope; s != nil; s = s.outer {
+			b, ok := s.bindings[ref.ident.Value]
+			if ok && (deferred || b.order < ref.order) {
+				renames[ref.ident] = b.name
+				break
+			}
+			if s.function {
+				deferred = true
+			}
+		}
+	}
+	return renames
+}
+
+// patternNames returns the names bound by pattern, the target of a let
+// statement or a match pattern.
+func patternNames(pattern ast.Expression) []*ast.Identifier {
+	var names []*ast.Identifier
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		names = append(names, pattern)
+	case *ast.ArrayPattern:
+		for _, element := range pattern.Elements {
+			names = append(names, patternNames(element)...)
+		}
+		if pattern.Rest != nil {
+			names = append(names, pattern.Rest)
+		}
+	case *ast.HashPattern:
+		for _, pair := range pattern.Pairs {
+			names = append(names, patternNames(pair.Value)...)
+		}
+	case *ast.VariantPattern:
+		for _, field := range pattern.Fields {
+			names = append(names, patternNames(field)...)
+		}
+	}
+	return names
+}
+
+func isUnquoteCall(call *ast.CallExpression) bool {
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == "unquote"
+}
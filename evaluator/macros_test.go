@@ -0,0 +1,377 @@
+package evaluator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jamesroutley/monkey/ast"
+	"github.com/jamesroutley/monkey/lexer"
+	"github.com/jamesroutley/monkey/object"
+	"github.com/jamesroutley/monkey/parser"
+	"github.com/jamesroutley/monkey/token"
+)
+
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(5)`, `5`},
+		{`quote(5 + 8)`, `(5 + 8)`},
+		{`quote(foobar + barfoo)`, `(foobar + barfoo)`},
+		{`quote(fn(x) { let y = x; y })`, `fn(x)let y = x;y`},
+		{`quote(unquote(4))`, `4`},
+		{`quote(unquote(4 + 4))`, `8`},
+		{`quote(8 + unquote(4 + 4))`, `(8 + 8)`},
+		{`quote(unquote(-4) + 4)`, `(-4 + 4)`},
+		{`quote(unquote(true))`, `true`},
+		{`quote(unquote(1 == 2))`, `false`},
+		{`quote(unquote("a\n" + "b"))`, `"a\nb"`},
+		{`let q = quote(4 + 4); quote(unquote(4 + 4) + unquote(q))`,
+			`(8 + (4 + 4))`},
+		{`quote(unquote(quote(quote(x))))`, `quote(x)`},
+		// Quoting doesn't change the AST it quotes.
+		{`let f = fn(x) { quote(unquote(x) + 1) }; f(1); f(2)`, `(2 + 1)`},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		quote, ok := evaluated.(*object.Quote)
+		if !ok {
+			t.Errorf("%s - expected *object.Quote. got %T (%+v)", tt.input,
+				evaluated, evaluated)
+			continue
+		}
+		if quote.Node.String() != tt.expected {
+			t.Errorf("%s - expected %q. got %q", tt.input, tt.expected,
+				quote.Node.String())
+		}
+		if quote.Inspect() != "QUOTE("+tt.expected+")" {
+			t.Errorf("wrong Inspect. got %q", quote.Inspect())
+		}
+	}
+}
+
+func TestQuoteUnquoteErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote()`, "wrong number of arguments to `quote`: want 1, got 0"},
+		{`quote(1, 2)`, "wrong number of arguments to `quote`: want 1, got 2"},
+		{`quote(unquote())`, "wrong number of arguments to `unquote`: want 1, got 0"},
+		{`quote(unquote(fn() {}))`,
+			"cannot unquote FUNCTION: want INTEGER, BOOLEAN, STRING or QUOTE"},
+		{`quote(unquote(x))`, "identifier not found: x"},
+		{`unquote(1)`, "identifier not found: unquote"},
+		{`macro(x) { x }`, "macros must be bound by a let statement at the " +
+			"top level of a program"},
+	}
+	for _, tt := range tests {
+		testErrorObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+	let number = 1;
+	let function = fn(x, y) { x + y };
+	let mymacro = macro(x, y) { x + y; };
+	`
+	env := object.NewEnvironment()
+	program, err := ExpandMacros(parseMacroProgram(t, input), env)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Message)
+	}
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("wrong number of statements. got %d",
+			len(program.Statements))
+	}
+	for _, name := range []string{"number", "function"} {
+		if _, ok := env.Get(name); ok {
+			t.Errorf("%s should not be defined", name)
+		}
+	}
+
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment")
+	}
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("object is not Macro. got %T (%+v)", obj, obj)
+	}
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("wrong number of macro parameters. got %d",
+			len(macro.Parameters))
+	}
+	if macro.Body.String() != "(x + y)" {
+		t.Errorf("body is not %q. got %q", "(x + y)", macro.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`let infixExpression = macro() { quote(1 + 2); };
+			infixExpression();`,
+			`(1 + 2)`,
+		},
+		{
+			`let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); };
+			reverse(2 + 2, 10 - 5);`,
+			`((10 - 5) - (2 + 2))`,
+		},
+		{
+			`let unless = macro(condition, consequence, alternative) {
+				quote(if (!(unquote(condition))) {
+					unquote(consequence);
+				} else {
+					unquote(alternative);
+				});
+			};
+			unless(10 > 5, puts("not greater"), puts("greater"));`,
+			`if(!(10 > 5)) puts("not greater")else puts("greater")`,
+		},
+		{
+			// Macros can be used before they're defined, and expand to
+			// calls to other macros.
+			`twice(x);
+			let twice = macro(e) { quote(double(unquote(e)) + 0) };
+			let double = macro(e) { quote(unquote(e) * 2) };`,
+			`((x * 2) + 0)`,
+		},
+		{
+			// Macros are expanded wherever they're called.
+			`let one = macro() { quote(1) };
+			let f = fn() { [one(), {one(): one()}] };`,
+			`let f = fn()[1, {1: 1}];`,
+		},
+		{
+			// Bindings made by the expanded code are renamed.
+			`let m = macro(e) { quote(fn(y) { unquote(e) + y }(1)) };
+			m(y);`,
+			`fn(y_1)(y + y_1)(1)`,
+		},
+	}
+
+	for _, tt := range tests {
+		program, err := ExpandMacros(parseMacroProgram(t, tt.input),
+			object.NewEnvironment())
+		if err != nil {
+			t.Errorf("%s - unexpected error: %s", tt.input, err.Message)
+			continue
+		}
+		if program.String() != tt.expected {
+			t.Errorf("%s - expected %q. got %q", tt.input, tt.expected,
+				program.String())
+		}
+	}
+}
+
+func TestMacroHygiene(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{
+			// The macro's y doesn't capture the caller's.
+			`let m = macro(e) { quote(fn(y) { unquote(e) }(1)) };
+			let y = 10;
+			m(y);`,
+			10,
+		},
+		{
+			`let m = macro(e) { quote(if (true) { let y = 1; unquote(e) + y }) };
+			let y = 10;
+			m(y) + y;`,
+			21,
+		},
+		{
+			`let sum = macro(e) {
+				quote(fn() { let total = 0; for (i in unquote(e)) { total += i; } total }())
+			};
+			let total = 100;
+			let i = 5;
+			sum(range(i)) + total;`,
+			110,
+		},
+		{
+			// A binding's own value is evaluated before it's made, so the x
+			// after the = is the caller's.
+			`let inc = macro() { quote(fn() { let x = x + 1; x * 10 }()) };
+			let x = 4;
+			inc() + x;`,
+			54,
+		},
+		{
+			// A function can refer to the binding it's assigned to, as its
+			// body is evaluated later.
+			`let m = macro() {
+				quote(fn() { let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) + 1 } }; f(3) }())
+			};
+			let f = 100;
+			m() + f;`,
+			103,
+		},
+		{
+			// Each expansion gets its own names, so nested expansions don't
+			// clash either.
+			`let twice = macro(e) { quote(fn(x) { x + x }(unquote(e))) };
+			let x = 3;
+			twice(twice(x));`,
+			12,
+		},
+		{
+			`let m = macro(e) { quote(match (1) { n => unquote(e) + n }) };
+			let n = 10;
+			m(n);`,
+			11,
+		},
+		{
+			`let m = macro(e) {
+				quote(fn() { let [a, {b: c}] = [1, {"b": 2}]; unquote(e) + a + c }())
+			};
+			let a = 10;
+			let c = 20;
+			m(a + c);`,
+			33,
+		},
+		{
+			`let m = macro(x) { quote(try { throw 1 } catch (e) { unquote(x) + e.value }) };
+			let e = 10;
+			m(e);`,
+			11,
+		},
+		{
+			// Field names aren't bindings, so aren't renamed.
+			`let m = macro(e) { quote(fn() { struct P { x }; (P(1) with { x: unquote(e) }).x }()) };
+			let x = 5;
+			let P = 1;
+			m(x + P);`,
+			6,
+		},
+		{
+			// Variant names aren't bindings, so aren't renamed, but names
+			// bound by variant patterns are.
+			`let m = macro(e) { quote(fn() { enum E { A(x) }; match (E.A(1)) { E.A(r) => r + unquote(e) } }()) };
+			let r = 5;
+			let A = 1;
+			m(r + A);`,
+			7,
+		},
+		{
+			// Method names aren't bindings, so aren't renamed.
+			`let m = macro(e) { quote(fn() { struct P { x }; impl P { fn y(self) { unquote(e) } }; P(1).y() }()) };
+			let y = 5;
+			let P = 1;
+			m(y + P);`,
+			6,
+		},
+		{
+			// Members aren't bindings, so aren't renamed.
+			`let m = macro() { quote(fn(abs) { abs((import "math").abs(-2)) }(fn(n) { n * 10 })) };
+			m();`,
+			20,
+		},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEvalMacros(t, tt.input), tt.expected)
+	}
+}
+
+func TestMacroErrors(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    string
+		expectedPos token.Position
+	}{
+		{
+			"let m = macro() { 1 };\nlet x = 2;\n m();",
+			"macro m must return a quoted AST, got INTEGER",
+			token.Position{Line: 3, Column: 3},
+		},
+		{
+			"let m = macro() { let x = 1; };\nm()",
+			"macro m must return a quoted AST, got NULL",
+			token.Position{Line: 2, Column: 2},
+		},
+		{
+			"let m = macro(a) { quote(unquote(a)) };\nm(1, 2)",
+			"wrong number of arguments to macro m: want 1, got 2",
+			token.Position{Line: 2, Column: 2},
+		},
+		{
+			"let m = macro(a) {\n  quote(unquote(a + 1))\n};\nm(1)",
+			"macro m: 2:19: type mismatch: QUOTE + INTEGER",
+			token.Position{Line: 4, Column: 2},
+		},
+		{
+			"let m = macro(a) { a };\nm(a: 1)",
+			"macro m can't be passed named or spread arguments",
+			token.Position{Line: 2, Column: 2},
+		},
+		{
+			"let m = macro() { quote(m()) };\nm()",
+			"macro expansion too deep: more than 100 nested expansions",
+			token.Position{Line: 1, Column: 26},
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := ExpandMacros(parseMacroProgram(t, tt.input),
+			object.NewEnvironment())
+		if err == nil {
+			t.Errorf("%q - expected an error", tt.input)
+			continue
+		}
+		if err.Message != tt.expected {
+			t.Errorf("%q - wrong message. expected %q. got %q", tt.input,
+				tt.expected, err.Message)
+		}
+		if err.Pos != tt.expectedPos {
+			t.Errorf("%q - wrong position. expected %s. got %s", tt.input,
+				tt.expectedPos, err.Pos)
+		}
+	}
+}
+
+func TestMacrosInModules(t *testing.T) {
+	root := writeModules(t, map[string]string{
+		"lib.mk": `
+			let unless = macro(c, a, b) { quote(if (unquote(c)) { unquote(b) } else { unquote(a) }) };
+			let check = fn(n) { unless(n > 0, "not positive", "positive") };`,
+	})
+
+	evaluated := testEvalWithOptions(context.Background(), `(import "lib").check(1)`,
+		Options{Modules: NewModuleLoader(root, nil)})
+	if evaluated.Inspect() != "positive" {
+		t.Errorf("expected %q. got %q", "positive", evaluated.Inspect())
+	}
+}
+
+func parseMacroProgram(t *testing.T, input string) *ast.Program {
+	t.Helper()
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
+
+// testEvalMacros expands the macros in input, then evaluates it.
+func testEvalMacros(t *testing.T, input string) object.Object {
+	t.Helper()
+	program, err := ExpandMacros(parseMacroProgram(t, input),
+		object.NewEnvironment())
+	if err != nil {
+		return err
+	}
+	return Eval(program, object.NewEnvironment())
+}
@@ -0,0 +1,256 @@
+package evaluator
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/jamesroutley/monkey/object"
+)
+
+// mathModule returns the members of the native math module.
+//
+// Monkey only has integers, so functions which usually take real numbers
+// work on integers instead: sqrt is the integer square root, and floor, ceil
+// and round divide two integers, rounding the quotient. pi and e are
+// truncated to their integer parts.
+//
+// Each import gets its own random number generator, seeded from the clock,
+// which rand_seed reseeds to make rand_int reproducible.
+func (e *Evaluator) mathModule() map[string]object.Object {
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+
+	return map[string]object.Object{
+		"pi": &object.Integer{Value: 3}, // math.Pi, truncated.
+		"e":  &object.Integer{Value: 2}, // math.E, truncated.
+
+		"abs":   e.mathBuiltin("abs", 1, 1, mathAbs),
+		"min":   e.mathBuiltin("min", 1, -1, mathMin),
+		"max":   e.mathBuiltin("max", 1, -1, mathMax),
+		"pow":   e.mathBuiltin("pow", 2, 2, mathPow),
+		"sqrt":  e.mathBuiltin("sqrt", 1, 1, mathSqrt),
+		"floor": e.mathBuiltin("floor", 2, 2, mathFloor),
+		"ceil":  e.mathBuiltin("ceil", 2, 2, mathCeil),
+		"round": e.mathBuiltin("round", 2, 2, mathRound),
+		"clamp": e.mathBuiltin("clamp", 3, 3, mathClamp),
+		"gcd":   e.mathBuiltin("gcd", 2, 2, mathGCD),
+		"lcm":   e.mathBuiltin("lcm", 2, 2, mathLCM),
+
+		"rand_int": e.mathBuiltin("rand_int", 1, 2,
+			func(args []int64) object.Object {
+				lo, hi := int64(0), args[0]
+				if len(args) == 2 {
+					lo, hi = args[0], args[1]
+				}
+				if lo >= hi {
+					return newError("`math.rand_int` range is empty: "+
+						"[%d, %d)", lo, hi)
+				}
+				return &object.Integer{Value: randRange(rng, lo, hi)}
+			}),
+		"rand_seed": e.mathBuiltin("rand_seed", 1, 1,
+			func(args []int64) object.Object {
+				rng.Seed(args[0])
+				return NULL
+			}),
+	}
+}
+
+// randRange returns a random integer in [lo, hi), which mustn't be empty.
+func randRange(rng *rand.Rand, lo, hi int64) int64 {
+	// hi - lo can overflow an int64, but not a uint64.
+	span := uint64(hi) - uint64(lo)
+	if span <= math.MaxInt64 {
+		return lo + rng.Int63n(int64(span))
+	}
+	// span is more than half the range of a uint64, so this takes fewer than
+	// two tries on average.
+	for {
+		if n := rng.Uint64(); n < span {
+			return int64(uint64(lo) + n)
+		}
+	}
+}
+
+// mathBuiltin returns the builtin math.name, which takes between min and max
+// integer arguments (see integerArgs) and passes their values to fn. The
+// integers fn returns are accounted for by e.
+func (e *Evaluator) mathBuiltin(
+	name string,
+	min, max int,
+	fn func(args []int64) object.Object,
+) *object.Builtin {
+	name = "math." + name
+	return &object.Builtin{
+		Name: name,
+		Fn: func(args ...object.Object) object.Object {
+			values, err := integerArgs(name, args, min, max)
+			if err != nil {
+				return err
+			}
+			result := fn(values)
+			if _, ok := result.(*object.Integer); ok {
+				return e.alloc(result)
+			}
+			return result
+		},
+	}
+}
+
+func mathAbs(args []int64) object.Object {
+	if args[0] == math.MinInt64 {
+		return newError("integer overflow: math.abs(%d)", args[0])
+	}
+	if args[0] < 0 {
+		return &object.Integer{Value: -args[0]}
+	}
+	return &object.Integer{Value: args[0]}
+}
+
+func mathMin(args []int64) object.Object {
+	min := args[0]
+	for _, arg := range args[1:] {
+		if arg < min {
+			min = arg
+		}
+	}
+	return &object.Integer{Value: min}
+}
+
+func mathMax(args []int64) object.Object {
+	max := args[0]
+	for _, arg := range args[1:] {
+		if arg > max {
+			max = arg
+		}
+	}
+	return &object.Integer{Value: max}
+}
+
+func mathPow(args []int64) object.Object {
+	if args[1] < 0 {
+		return newError("negative exponent: math.pow(%d, %d)", args[0],
+			args[1])
+	}
+	return &object.Integer{Value: integerPow(args[0], args[1])}
+}
+
+// mathSqrt returns the largest integer whose square is at most its argument.
+func mathSqrt(args []int64) object.Object {
+	n := args[0]
+	if n < 0 {
+		return newError("square root of negative number: math.sqrt(%d)", n)
+	}
+	// The float64 estimate can be off by one for large n.
+	root := int64(math.Sqrt(float64(n)))
+	for root*root > n {
+		root--
+	}
+	for (root+1)*(root+1) > 0 && (root+1)*(root+1) <= n {
+		root++
+	}
+	return &object.Integer{Value: root}
+}
+
+// mathFloor returns a / b, rounded down.
+func mathFloor(args []int64) object.Object {
+	a, b := args[0], args[1]
+	if b == 0 {
+		return newError("division by zero: math.floor(%d, %d)", a, b)
+	}
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return &object.Integer{Value: q}
+}
+
+// mathCeil returns a / b, rounded up.
+func mathCeil(args []int64) object.Object {
+	a, b := args[0], args[1]
+	if b == 0 {
+		return newError("division by zero: math.ceil(%d, %d)", a, b)
+	}
+	q := a / b
+	if a%b != 0 && (a < 0) == (b < 0) {
+		q++
+	}
+	return &object.Integer{Value: q}
+}
+
+// mathRound returns a / b, rounded to the nearest integer, with halves
+// rounded away from zero.
+func mathRound(args []int64) object.Object {
+	a, b := args[0], args[1]
+	if b == 0 {
+		return newError("division by zero: math.round(%d, %d)", a, b)
+	}
+	q, r := a/b, a%b
+	// Compare |r| with |b| - |r| rather than 2|r| with |b|, which could
+	// overflow.
+	absR, absB := abs(r), abs(b)
+	if absR >= absB-absR && r != 0 {
+		if (a < 0) == (b < 0) {
+			q++
+		} else {
+			q--
+		}
+	}
+	return &object.Integer{Value: q}
+}
+
+func mathClamp(args []int64) object.Object {
+	x, lo, hi := args[0], args[1], args[2]
+	if lo > hi {
+		return newError("`math.clamp` bounds are reversed: %d > %d", lo, hi)
+	}
+	if x < lo {
+		x = lo
+	} else if x > hi {
+		x = hi
+	}
+	return &object.Integer{Value: x}
+}
+
+// mathGCD returns the greatest common divisor of its arguments, which is
+// never negative.
+func mathGCD(args []int64) object.Object {
+	g := gcd(args[0], args[1])
+	if g < 0 {
+		return newError("integer overflow: math.gcd(%d, %d)", args[0], args[1])
+	}
+	return &object.Integer{Value: g}
+}
+
+// mathLCM returns the least common multiple of its arguments, which is
+// never negative.
+func mathLCM(args []int64) object.Object {
+	a, b := args[0], args[1]
+	if a == 0 || b == 0 {
+		return &object.Integer{Value: 0}
+	}
+	// The result is a multiple of both |a| and |b|, so it's too big if
+	// either of them is.
+	if a == math.MinInt64 || b == math.MinInt64 {
+		return newError("integer overflow: math.lcm(%d, %d)", a, b)
+	}
+	q, absB := abs(a)/gcd(a, b), abs(b)
+	if q > math.MaxInt64/absB {
+		return newError("integer overflow: math.lcm(%d, %d)", a, b)
+	}
+	return &object.Integer{Value: q * absB}
+}
+
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return abs(a)
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
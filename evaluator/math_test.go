@@ -0,0 +1,150 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/jamesroutley/monkey/object"
+)
+
+func TestMathModule(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"math.pi", 3},
+		{"math.e", 2},
+
+		{"math.abs(-5)", 5},
+		{"math.abs(5)", 5},
+		{"math.abs(0)", 0},
+		{"math.abs(-9223372036854775807)", 9223372036854775807},
+		{"math.min(3)", 3},
+		{"math.min(3, -1, 2)", -1},
+		{"math.max(3, -1, 2)", 3},
+		{"math.pow(2, 10)", 1024},
+		{"math.pow(-3, 3)", -27},
+		{"math.pow(7, 0)", 1},
+		{"math.sqrt(0)", 0},
+		{"math.sqrt(1)", 1},
+		{"math.sqrt(15)", 3},
+		{"math.sqrt(16)", 4},
+		{"math.sqrt(9223372036854775807)", 3037000499},
+		{"math.floor(7, 2)", 3},
+		{"math.floor(-7, 2)", -4},
+		{"math.floor(7, -2)", -4},
+		{"math.floor(-7, -2)", 3},
+		{"math.floor(6, 2)", 3},
+		{"math.ceil(7, 2)", 4},
+		{"math.ceil(-7, 2)", -3},
+		{"math.ceil(7, -2)", -3},
+		{"math.ceil(-7, -2)", 4},
+		{"math.ceil(6, 2)", 3},
+		{"math.round(7, 2)", 4},
+		{"math.round(-7, 2)", -4},
+		{"math.round(7, 3)", 2},
+		{"math.round(8, 3)", 3},
+		{"math.round(-8, 3)", -3},
+		{"math.round(8, -3)", -3},
+		{"math.round(6, 3)", 2},
+		{"math.clamp(5, 0, 10)", 5},
+		{"math.clamp(-5, 0, 10)", 0},
+		{"math.clamp(15, 0, 10)", 10},
+		{"math.gcd(12, 18)", 6},
+		{"math.gcd(-12, 18)", 6},
+		{"math.gcd(0, 5)", 5},
+		{"math.gcd(0, 0)", 0},
+		{"math.lcm(4, 6)", 12},
+		{"math.lcm(-4, 6)", 12},
+		{"math.lcm(0, 6)", 0},
+		{"math.gcd(-9223372036854775807 - 1, 6)", 2},
+		{"math.lcm(4611686018427387903, 2)", 9223372036854775806},
+		{"math.lcm(9223372036854775807, -9223372036854775807)",
+			9223372036854775807},
+
+		{"math.abs(true)", "argument to `math.abs` must be INTEGER, got BOOLEAN"},
+		{"math.abs()", "wrong number of arguments to `math.abs`: want 1, got 0"},
+		{"math.abs(-9223372036854775807 - 1)",
+			"integer overflow: math.abs(-9223372036854775808)"},
+		{"math.gcd(-9223372036854775807 - 1, 0)",
+			"integer overflow: math.gcd(-9223372036854775808, 0)"},
+		{"math.lcm(9223372036854775807, 2)",
+			"integer overflow: math.lcm(9223372036854775807, 2)"},
+		{"math.lcm(-9223372036854775807 - 1, 1)",
+			"integer overflow: math.lcm(-9223372036854775808, 1)"},
+		{"math.min()",
+			"wrong number of arguments to `math.min`: want at least 1, got 0"},
+		{`math.max(1, "2")`, "argument to `math.max` must be INTEGER, got STRING"},
+		{"math.pow(2)", "wrong number of arguments to `math.pow`: want 2, got 1"},
+		{"math.pow(2, -1)", "negative exponent: math.pow(2, -1)"},
+		{"math.sqrt(-4)", "square root of negative number: math.sqrt(-4)"},
+		{"math.floor(1, 0)", "division by zero: math.floor(1, 0)"},
+		{"math.ceil(1, 0)", "division by zero: math.ceil(1, 0)"},
+		{"math.round(1, 0)", "division by zero: math.round(1, 0)"},
+		{"math.clamp(1, 10, 0)", "`math.clamp` bounds are reversed: 10 > 0"},
+		{"math.rand_int(0)", "`math.rand_int` range is empty: [0, 0)"},
+		{"math.rand_int(5, 5)", "`math.rand_int` range is empty: [5, 5)"},
+		{"math.rand_int(1, 2, 3)",
+			"wrong number of arguments to `math.rand_int`: want 1 to 2, got 3"},
+		{"math.tau", "module math has no member tau"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(`let math = import "math"; ` + tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			testErrorObject(t, evaluated, expected)
+		}
+	}
+}
+
+func TestMathRandom(t *testing.T) {
+	input := `
+	let math = import "math";
+	let draw = fn() {
+		let total = 0;
+		for (i in range(20)) {
+			let n = math.rand_int(10, 20);
+			if (n < 10 || n >= 20) { return -1; }
+			total = total * 7 + n;
+		}
+		total;
+	};
+	math.rand_seed(42);
+	let first = draw();
+	math.rand_seed(42);
+	let second = draw();
+	[first, first == second, math.rand_int(1)];
+	`
+
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got %T (%+v)", evaluated, evaluated)
+	}
+	first := result.Elements[0].(*object.Integer)
+	if first.Value == -1 {
+		t.Errorf("rand_int returned a number out of range")
+	}
+	// The same seed gives the same sequence.
+	testBooleanObject(t, result.Elements[1], true)
+	testIntegerObject(t, result.Elements[2], 0)
+}
+
+func TestMathRandomWideRange(t *testing.T) {
+	// hi - lo overflows an int64.
+	input := `
+	let math = import "math";
+	let n = math.rand_int(-9223372036854775807, 9223372036854775807);
+	let m = math.rand_int(-2, 9223372036854775807);
+	[n < 9223372036854775807, m >= -2]
+	`
+	evaluated := testEval(input)
+	result, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got %T (%+v)", evaluated, evaluated)
+	}
+	testBooleanObject(t, result.Elements[0], true)
+	testBooleanObject(t, result.Elements[1], true)
+}
@@ -0,0 +1,219 @@
+package evaluator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jamesroutley/monkey/ast"
+	"github.com/jamesroutley/monkey/lexer"
+	"github.com/jamesroutley/monkey/object"
+	"github.com/jamesroutley/monkey/parser"
+)
+
+// ModuleExtension is the file extension of Monkey source files. It's added
+// to import paths which don't have an extension.
+const ModuleExtension = ".mk"
+
+// nativeModules are the modules implemented in Go, keyed by import path.
+// Each function returns the members of a new instance of its module, for an
+// Evaluator, which its builtins account for the values they allocate with,
+// and which gives modules that interact with the host access to its Options.
+// Native modules take precedence over files, and can be imported without a
+// ModuleLoader.
+var nativeModules = map[string]func(e *Evaluator) map[string]object.Object{
+	"json":    (*Evaluator).jsonModule,
+	"math":    (*Evaluator).mathModule,
+	"os":      (*Evaluator).osModule,
+	"regex":   (*Evaluator).regexModule,
+	"strings": (*Evaluator).stringsModule,
+	"time":    (*Evaluator).timeModule,
+}
+
+// ModuleLoader finds, evaluates and caches the modules imported by Monkey
+// programs. A module is evaluated once, the first time it's imported, and
+// later imports share its object.Module. A ModuleLoader can be shared by
+// several Evaluators, e.g. one per line entered at the REPL.
+type ModuleLoader struct {
+	// Dir is the directory imports in the main program are resolved
+	// relative to. Defaults to the working directory.
+	Dir string
+	// Path is the list of directories searched for modules which aren't
+	// found relative to the importing file.
+	Path []string
+
+	modules map[string]*object.Module // Keyed by filename.
+	loading []string                  // Filenames of modules being evaluated.
+
+	// dirs holds the directory of the module each import was parsed from.
+	dirs map[*ast.ImportExpression]string
+}
+
+// NewModuleLoader initialises and returns a ModuleLoader.
+func NewModuleLoader(dir string, path []string) *ModuleLoader {
+	return &ModuleLoader{
+		Dir:     dir,
+		Path:    path,
+		modules: make(map[string]*object.Module),
+		dirs:    make(map[*ast.ImportExpression]string),
+	}
+}
+
+// ModulePathFromEnv returns the module search path set in the MONKEYPATH
+// environment variable, a list of directories separated like PATH.
+func ModulePathFromEnv() []string {
+	return filepath.SplitList(os.Getenv("MONKEYPATH"))
+}
+
+// resolve returns the absolute filename of the module imported by node.
+// Paths starting with ./ or ../ are relative to the importing module. Other
+// relative paths are looked for next to the importing module, then in each
+// directory of the search path.
+func (ml *ModuleLoader) resolve(node *ast.ImportExpression) (string, error) {
+	path := filepath.FromSlash(node.Path)
+	if filepath.Ext(path) == "" {
+		path += ModuleExtension
+	}
+	dir, ok := ml.dirs[node]
+	if !ok {
+		dir = ml.Dir
+	}
+
+	var candidates []string
+	switch {
+	case filepath.IsAbs(path):
+		candidates = []string{path}
+	case strings.HasPrefix(node.Path, "./"), strings.HasPrefix(node.Path, "../"):
+		candidates = []string{filepath.Join(dir, path)}
+	default:
+		candidates = []string{filepath.Join(dir, path)}
+		for _, searchDir := range ml.Path {
+			candidates = append(candidates, filepath.Join(searchDir, path))
+		}
+	}
+
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return filepath.Abs(candidate)
+		}
+	}
+	return "", fmt.Errorf("cannot find module %q", node.Path)
+}
+
+// displayName returns filename relative to the loader's directory, if
+// possible, for use in error messages.
+func (ml *ModuleLoader) displayName(filename string) string {
+	dir, err := filepath.Abs(ml.Dir)
+	if err != nil {
+		return filename
+	}
+	rel, err := filepath.Rel(dir, filename)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return filename
+	}
+	return rel
+}
+
+// cycle returns the names of the modules in the import cycle created by
+// importing filename, or nil if there isn't one.
+func (ml *ModuleLoader) cycle(filename string) []string {
+	for i, loading := range ml.loading {
+		if loading != filename {
+			continue
+		}
+		var names []string
+		for _, f := range append(ml.loading[i:], filename) {
+			names = append(names, ml.displayName(f))
+		}
+		return names
+	}
+	return nil
+}
+
+func (e *Evaluator) evalImportExpression(node *ast.ImportExpression) object.Object {
+	if node.Path == "os" && e.opts.System == nil {
+		return newError("cannot import %q: access to the system is not "+
+			"enabled", node.Path)
+	}
+	if module, ok := e.importNativeModule(node.Path); ok {
+		return module
+	}
+
+	loader := e.opts.Modules
+	if loader == nil {
+		return newError("cannot import %q: imports are not enabled",
+			node.Path)
+	}
+	filename, err := loader.resolve(node)
+	if err != nil {
+		return newError("%s", err)
+	}
+	if module, ok := loader.modules[filename]; ok {
+		return module
+	}
+	if cycle := loader.cycle(filename); cycle != nil {
+		return newError("import cycle: %s", strings.Join(cycle, " -> "))
+	}
+
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return newError("cannot import %q: %s", node.Path, err)
+	}
+	name := loader.displayName(filename)
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if errs := p.ParseErrors(); len(errs) != 0 {
+		return newError("%s:%s: %s", name, errs[0].Pos, errs[0].Message)
+	}
+	// Each module defines its own macros.
+	program, errObj := e.ExpandMacros(program, object.NewEnvironment())
+	if errObj != nil {
+		return newError("%s:%s: %s", name, errObj.Pos, errObj.Message)
+	}
+	dir := filepath.Dir(filename)
+	ast.Inspect(program, func(n ast.Node) bool {
+		if imp, ok := n.(*ast.ImportExpression); ok {
+			loader.dirs[imp] = dir
+		}
+		return true
+	})
+
+	env, errObj := e.newEnclosedEnvironment(nil)
+	if errObj != nil {
+		return errObj
+	}
+	loader.loading = append(loader.loading, filename)
+	result := e.Eval(program, env)
+	loader.loading = loader.loading[:len(loader.loading)-1]
+	if err, ok := result.(*object.Error); ok {
+		wrapped := newError("%s:%s: %s", name, err.Pos, err.Message)
+		wrapped.Value, wrapped.Stack, wrapped.Fatal = err.Value, err.Stack,
+			err.Fatal
+		return wrapped
+	}
+
+	module := &object.Module{Name: node.Path, Env: env}
+	loader.modules[filename] = module
+	return module
+}
+
+// importNativeModule returns the native module called name, if there is
+// one. It's instantiated the first time it's imported by e's program.
+func (e *Evaluator) importNativeModule(name string) (*object.Module, bool) {
+	if module, ok := e.natives[name]; ok {
+		return module, true
+	}
+	newMembers, ok := nativeModules[name]
+	if !ok {
+		return nil, false
+	}
+	env := object.NewEnvironment()
+	for name, member := range newMembers(e) {
+		env.Set(name, member)
+	}
+	module := &object.Module{Name: name, Env: env}
+	e.natives[name] = module
+	return module, true
+}
@@ -0,0 +1,148 @@
+package evaluator
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestImports(t *testing.T) {
+	root := writeModules(t, map[string]string{
+		"lib/arith.mk": `
+			let square = fn(x) { x * x };
+			let _helper = 1;
+			let calls = 0;
+			let count = fn() { calls += 1; calls };`,
+		"lib/shapes.mk": `
+			let arith = import "./arith";
+			let area = fn(side) { arith.square(side) };`,
+		"lib/sub/up.mk":  `let parent = import "../arith.mk"; let two = parent.square(2);`,
+		"vendor/ext.mk":  `let name = "ext";`,
+		"main/local.mk":  `let name = "local";`,
+		"main/nested.mk": `let ext = import "ext"; let name = ext.name;`,
+	})
+	loader := NewModuleLoader(filepath.Join(root, "main"),
+		[]string{filepath.Join(root, "lib"), filepath.Join(root, "vendor")})
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`let m = import "arith"; m.square(4)`, 16},
+		{`let s = import "shapes"; s.area(3)`, 9},
+		{`let u = import "sub/up"; u.two`, 4},
+		{`let e = import "ext.mk"; e.name`, "ext"},
+		// Modules next to the importing file take precedence.
+		{`let l = import "local"; l.name`, "local"},
+		{`let n = import "nested"; n.name`, "ext"},
+		{`(import "arith").square(5)`, 25},
+		{`import "arith"`, "module(arith)"},
+		// Modules are evaluated once, and shared by every import.
+		{`let a = import "arith"; let b = import "./../lib/arith"; a.count(); b.count()`, 2},
+		{`let m = import "arith"; m.count()`, 3},
+		{`let m = import "shapes"; m.arith.count()`, 4},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithOptions(context.Background(), tt.input,
+			Options{Modules: loader})
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("%q - expected %q. got %q", tt.input, expected,
+					evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestImportErrors(t *testing.T) {
+	root := writeModules(t, map[string]string{
+		"a.mk":       `let b = import "b";`,
+		"b.mk":       `let c = import "c";`,
+		"c.mk":       `let a = import "a";`,
+		"self.mk":    `let self = import "self";`,
+		"private.mk": `let _x = 1;`,
+		"syntax.mk":  "let x = 1;\nlet = 2;",
+		"runtime.mk": "let x = 1;\nx + true;",
+	})
+
+	tests := []struct {
+		input           string
+		opts            Options
+		expectedMessage string
+	}{
+		{
+			`import "a"`,
+			Options{},
+			`cannot import "a": imports are not enabled`,
+		},
+		{
+			`import "missing"`,
+			Options{Modules: NewModuleLoader(root, nil)},
+			`cannot find module "missing"`,
+		},
+		{
+			`import "self"`,
+			Options{Modules: NewModuleLoader(root, nil)},
+			"self.mk:1:12: import cycle: self.mk -> self.mk",
+		},
+		{
+			`import "a"`,
+			Options{Modules: NewModuleLoader(root, nil)},
+			"a.mk:1:9: b.mk:1:9: c.mk:1:9: " +
+				"import cycle: a.mk -> b.mk -> c.mk -> a.mk",
+		},
+		{
+			`(import "private")._x`,
+			Options{Modules: NewModuleLoader(root, nil)},
+			"cannot access private member _x of module private",
+		},
+		{
+			`(import "private").y`,
+			Options{Modules: NewModuleLoader(root, nil)},
+			"module private has no member y",
+		},
+		{
+			`import "syntax"`,
+			Options{Modules: NewModuleLoader(root, nil)},
+			"syntax.mk:2:5: expected next token to be IDENT, got = instead",
+		},
+		{
+			`import "runtime"`,
+			Options{Modules: NewModuleLoader(root, nil)},
+			"runtime.mk:2:3: type mismatch: INTEGER + BOOLEAN",
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithOptions(context.Background(), tt.input,
+			tt.opts)
+		testErrorObject(t, evaluated, tt.expectedMessage)
+	}
+}
+
+// writeModules writes files, keyed by slash separated path, to a temporary
+// directory, and returns the directory.
+func writeModules(t *testing.T, files map[string]string) string {
+	root, err := ioutil.TempDir("", "monkey-modules")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(root) })
+
+	for name, src := range files {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
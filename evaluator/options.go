@@ -0,0 +1,225 @@
+package evaluator
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"time"
+	"unsafe"
+
+	"github.com/jamesroutley/monkey/object"
+)
+
+// Options bounds the resources a Monkey program may use, and configures
+// how it interacts with its host. The zero value imposes no limits, which is
+// what Eval uses. Programs from untrusted sources should be run with limits
+// set, via New.
+type Options struct {
+	// MaxSteps is the maximum number of AST nodes which may be evaluated.
+	MaxSteps int64
+	// MaxCallDepth is the maximum number of nested function calls. Tail
+	// calls don't nest, so don't count towards it.
+	MaxCallDepth int
+	// MaxObjects is the maximum number of objects, including environments,
+	// which may be allocated over the whole run.
+	MaxObjects int64
+	// MaxBytes is the maximum number of bytes which may be allocated for
+	// objects over the whole run. Sizes are approximate.
+	MaxBytes int64
+	// DisableSideEffects stops builtins which affect the world outside the
+	// program, like puts, from being called.
+	DisableSideEffects bool
+
+	// Stdout is where puts writes to. Defaults to os.Stdout.
+	Stdout io.Writer
+	// Modules loads the modules imported by the program. If it's nil,
+	// import expressions fail.
+	Modules *ModuleLoader
+	// System gives the program access to the host's filesystem, environment
+	// and process, through the os module. If it's nil, the os module can't
+	// be imported, so embedded programs can't reach outside their sandbox
+	// unless the host allows it.
+	System *System
+	// Clock is used by the time module to tell the time and to sleep.
+	// Defaults to the system clock.
+	Clock Clock
+}
+
+// Clock tells the time, and waits for time to pass. Tests can provide their
+// own, so programs using the time module behave deterministically.
+type Clock interface {
+	// Now returns the current time. Times returned by successive calls
+	// should include a monotonic clock reading, if available, so elapsed
+	// times can be measured by subtracting them.
+	Now() time.Time
+	// Sleep waits until d has passed, or ctx is done, in which case it
+	// returns ctx's error.
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// System is the part of the host a program may access through the os
+// module. A System can be shared by several Evaluators, e.g. one per line
+// entered at the REPL.
+type System struct {
+	// Args are the program's command line arguments.
+	Args []string
+	// Stdin is read by os.read_line. Defaults to os.Stdin.
+	Stdin io.Reader
+	// Exit is called by os.exit with the exit status. Defaults to os.Exit.
+	// If it returns, the program stops with an error.
+	Exit func(code int)
+
+	stdin *bufio.Reader // Buffers Stdin between calls to os.read_line.
+}
+
+// Evaluator evaluates Monkey programs within the limits set by its Options.
+// The resources used are counted across every call to Eval, so an Evaluator
+// should be used for a single run of a program.
+type Evaluator struct {
+	ctx  context.Context
+	opts Options
+
+	steps   int64
+	depth   int
+	objects int64
+	bytes   int64
+
+	builtins map[string]*object.Builtin
+	natives  map[string]*object.Module // Native modules imported so far.
+
+	expansions int // Depth of nested macro expansions in progress.
+	renamed    int // Number of bindings renamed by macro hygiene.
+}
+
+// New initialises and returns an Evaluator. Evaluation stops with an error
+// once ctx is cancelled or its deadline passes.
+func New(ctx context.Context, opts Options) *Evaluator {
+	e := &Evaluator{
+		ctx:     ctx,
+		opts:    opts,
+		natives: make(map[string]*object.Module),
+	}
+	e.builtins = e.newBuiltins()
+	return e
+}
+
+// step counts the evaluation of one AST node. Returns an error if the step
+// limit has been reached or the context is done.
+func (e *Evaluator) step() *object.Error {
+	e.steps++
+	if e.opts.MaxSteps > 0 && e.steps > e.opts.MaxSteps {
+		return newFatalError("step limit exceeded: more than %d steps",
+			e.opts.MaxSteps)
+	}
+	select {
+	case <-e.ctx.Done():
+		return newFatalError("execution cancelled: %s", e.ctx.Err())
+	default:
+		return nil
+	}
+}
+
+// enterCall counts a nested function call. Each successful call must be
+// matched by a call to exitCall.
+func (e *Evaluator) enterCall() *object.Error {
+	if e.opts.MaxCallDepth > 0 && e.depth >= e.opts.MaxCallDepth {
+		return newFatalError("call depth limit exceeded: more than %d nested "+
+			"calls", e.opts.MaxCallDepth)
+	}
+	e.depth++
+	return nil
+}
+
+func (e *Evaluator) exitCall() {
+	e.depth--
+}
+
+// alloc accounts for the newly allocated obj, returning it, or an error if
+// it takes the program over its object or byte limits.
+func (e *Evaluator) alloc(obj object.Object) object.Object {
+	if err := e.account(objectSize(obj)); err != nil {
+		return err
+	}
+	return obj
+}
+
+// newEnclosedEnvironment accounts for and returns a new Environment nested
+// inside outer.
+func (e *Evaluator) newEnclosedEnvironment(
+	outer *object.Environment,
+) (*object.Environment, *object.Error) {
+	if err := e.account(environmentSize); err != nil {
+		return nil, err
+	}
+	return object.NewEnclosedEnvironment(outer), nil
+}
+
+func (e *Evaluator) account(size int64) *object.Error {
+	e.objects++
+	e.bytes += size
+	if e.opts.MaxObjects > 0 && e.objects > e.opts.MaxObjects {
+		return newFatalError("object limit exceeded: more than %d objects",
+			e.opts.MaxObjects)
+	}
+	return e.reserve(0)
+}
+
+// reserve returns an error if allocating size more bytes would take the
+// program over its byte limit. Builtins call it before building a value
+// whose size depends on their arguments, such as a long string, so that they
+// don't allocate more memory than the program is allowed before finding out
+// that it's too much. The value must still be passed to alloc once it's
+// built.
+func (e *Evaluator) reserve(size int64) *object.Error {
+	if e.opts.MaxBytes > 0 && e.bytes+size > e.opts.MaxBytes {
+		return newFatalError("memory limit exceeded: more than %d bytes",
+			e.opts.MaxBytes)
+	}
+	return nil
+}
+
+// environmentSize approximates the size of an Environment: the struct and
+// an empty map.
+const environmentSize = int64(unsafe.Sizeof(object.Environment{})) + 48
+
+// hashEntrySize approximates the size of one entry in a Hash: its key, and
+// a pair in its map.
+const hashEntrySize = int64(unsafe.Sizeof(object.HashKey{})) +
+	2*int64(unsafe.Sizeof(object.HashPair{}))
+
+// objectSize approximates the number of bytes allocated for obj.
+func objectSize(obj object.Object) int64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return int64(unsafe.Sizeof(*obj))
+	case *object.Function:
+		return int64(unsafe.Sizeof(*obj))
+	case *object.Range:
+		return int64(unsafe.Sizeof(*obj))
+	case *object.Result:
+		return int64(unsafe.Sizeof(*obj))
+	case *object.String:
+		return int64(unsafe.Sizeof(*obj)) + int64(len(obj.Value))
+	case *object.Array:
+		return int64(unsafe.Sizeof(*obj)) +
+			int64(len(obj.Elements))*int64(unsafe.Sizeof(obj))
+	case *object.Tuple:
+		return int64(unsafe.Sizeof(*obj)) +
+			int64(len(obj.Elements))*int64(unsafe.Sizeof(obj))
+	case *object.Struct:
+		return int64(unsafe.Sizeof(*obj)) +
+			int64(len(obj.Values))*int64(unsafe.Sizeof(obj))
+	case *object.EnumValue:
+		return int64(unsafe.Sizeof(*obj)) +
+			int64(len(obj.Values))*int64(unsafe.Sizeof(obj))
+	case *object.Hash:
+		return int64(unsafe.Sizeof(*obj)) +
+			int64(len(obj.Keys))*hashEntrySize
+	case *object.TailCall:
+		return int64(unsafe.Sizeof(*obj)) +
+			int64(len(obj.Arguments))*int64(unsafe.Sizeof(obj)) +
+			int64(len(obj.Named))*int64(unsafe.Sizeof(object.NamedArgument{}))
+	default:
+		return int64(unsafe.Sizeof(obj))
+	}
+}
@@ -0,0 +1,184 @@
+package evaluator
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jamesroutley/monkey/lexer"
+	"github.com/jamesroutley/monkey/object"
+	"github.com/jamesroutley/monkey/parser"
+)
+
+func TestResourceLimits(t *testing.T) {
+	tests := []struct {
+		input           string
+		opts            Options
+		expectedMessage string
+	}{
+		{
+			"while (true) { }",
+			Options{MaxSteps: 1000},
+			"step limit exceeded: more than 1000 steps",
+		},
+		{
+			"let f = fn(n) { 1 + f(n) }; f(1);",
+			Options{MaxCallDepth: 100},
+			"call depth limit exceeded: more than 100 nested calls",
+		},
+		{
+			"let i = 0; while (true) { i += 1; }",
+			Options{MaxObjects: 100},
+			"object limit exceeded: more than 100 objects",
+		},
+		{
+			"for (i in range(1000000)) { }",
+			Options{MaxBytes: 1024},
+			"memory limit exceeded: more than 1024 bytes",
+		},
+		{
+			"let f = fn(n) { f(n) }; f(1);",
+			Options{MaxObjects: 1000},
+			"object limit exceeded: more than 1000 objects",
+		},
+		{
+			"let a = [1]; while (true) { len(a); }",
+			Options{MaxObjects: 100},
+			"object limit exceeded: more than 100 objects",
+		},
+		{
+			"let x = 1; while (true) { range(x); }",
+			Options{MaxObjects: 100},
+			"object limit exceeded: more than 100 objects",
+		},
+		{
+			"let x = 1; while (true) { ok(x); }",
+			Options{MaxObjects: 100},
+			"object limit exceeded: more than 100 objects",
+		},
+		{
+			"let x = 1; while (true) { err(x); }",
+			Options{MaxObjects: 100},
+			"object limit exceeded: more than 100 objects",
+		},
+		{
+			"let r = err(1); let f = fn(e) { e }; while (true) { map_err(r, f); }",
+			Options{MaxObjects: 100},
+			"object limit exceeded: more than 100 objects",
+		},
+		{
+			// Limits can't be caught.
+			"try { while (true) { } } catch (e) { 1 } finally { 2 }",
+			Options{MaxSteps: 1000},
+			"step limit exceeded: more than 1000 steps",
+		},
+		{
+			"let f = fn(n) { try { 1 + f(n) } catch (e) { 0 } }; f(1);",
+			Options{MaxCallDepth: 100},
+			"call depth limit exceeded: more than 100 nested calls",
+		},
+		{
+			"puts(1);",
+			Options{DisableSideEffects: true},
+			"side effects disabled: cannot call `puts`",
+		},
+		{
+			// Builtins are gated when called, however they're referred to.
+			"let p = puts; let f = fn(g) { g(1) }; f(p);",
+			Options{DisableSideEffects: true},
+			"side effects disabled: cannot call `puts`",
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithOptions(context.Background(), tt.input,
+			tt.opts)
+		testErrorObject(t, evaluated, tt.expectedMessage)
+	}
+}
+
+func TestResourceLimitsNotReached(t *testing.T) {
+	opts := Options{
+		MaxSteps:           100000,
+		MaxCallDepth:       10,
+		MaxObjects:         10000,
+		MaxBytes:           1000000,
+		DisableSideEffects: true,
+	}
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"let total = 0; for (i in range(100)) { total += i; } total;", 4950},
+		{
+			// Tail calls don't count towards the call depth.
+			`let countdown = fn(n) {
+				if (n == 0) { 0 } else { countdown(n - 1) }
+			};
+			countdown(1000);`,
+			0,
+		},
+		{
+			`let fact = fn(n) { if (n == 0) { 1 } else { n * fact(n - 1) } };
+			fact(9);`,
+			362880,
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithOptions(context.Background(), tt.input, opts)
+		testIntegerObject(t, evaluated, tt.expected)
+	}
+}
+
+func TestContextCancellation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(),
+		10*time.Millisecond)
+	defer cancel()
+	evaluated := testEvalWithOptions(ctx, "while (true) { }", Options{})
+	testErrorObject(t, evaluated,
+		"execution cancelled: context deadline exceeded")
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	evaluated = testEvalWithOptions(ctx, "1 + 1", Options{})
+	testErrorObject(t, evaluated, "execution cancelled: context canceled")
+}
+
+func TestPutsBuiltin(t *testing.T) {
+	var out bytes.Buffer
+	evaluated := testEvalWithOptions(context.Background(),
+		"puts(1, true); puts(2 * 3);", Options{Stdout: &out})
+	testNullObject(t, evaluated)
+	if out.String() != "1 true\n6\n" {
+		t.Errorf("wrong output. got %q", out.String())
+	}
+}
+
+func testEvalWithOptions(
+	ctx context.Context,
+	input string,
+	opts Options,
+) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+
+	return New(ctx, opts).Eval(program, env)
+}
+
+func testErrorObject(t *testing.T, obj object.Object, expected string) bool {
+	errObj, ok := obj.(*object.Error)
+	if !ok {
+		t.Errorf("object is not an Error. got %T (%+v)", obj, obj)
+		return false
+	}
+	if errObj.Message != expected {
+		t.Errorf("wrong error message. expected %q, got %q", expected,
+			errObj.Message)
+		return false
+	}
+	return true
+}
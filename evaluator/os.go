@@ -0,0 +1,200 @@
+package evaluator
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/jamesroutley/monkey/object"
+)
+
+// osModule returns the members of the native os module, which accesses the
+// host through e's System. It must only be called if the host has provided
+// one.
+//
+// Functions which write to the filesystem or end the process have side
+// effects, so can be disabled with Options.DisableSideEffects.
+func (e *Evaluator) osModule() map[string]object.Object {
+	sys := e.opts.System
+
+	args := make([]object.Object, len(sys.Args))
+	for i, arg := range sys.Args {
+		args[i] = &object.String{Value: arg}
+	}
+
+	return map[string]object.Object{
+		"args": &object.Array{Elements: args},
+
+		"read_file":  osBuiltin("read_file", false, e.osReadFile),
+		"write_file": osBuiltin("write_file", true, osWriteFile),
+		"list_dir":   osBuiltin("list_dir", false, e.osListDir),
+		"exists":     osBuiltin("exists", false, osExists),
+		"getenv":     osBuiltin("getenv", false, e.osGetenv),
+		"read_line": osBuiltin("read_line", false,
+			func(name string, args []object.Object) object.Object {
+				line := sys.readLine(name, args)
+				if _, ok := line.(*object.String); ok {
+					return e.alloc(line)
+				}
+				return line
+			}),
+		"exit": osBuiltin("exit", true,
+			func(name string, args []object.Object) object.Object {
+				return sys.exit(name, args)
+			}),
+	}
+}
+
+// osBuiltin returns the builtin os.name. fn is passed the builtin's full
+// name, for error messages, along with its arguments.
+func osBuiltin(
+	name string,
+	sideEffects bool,
+	fn func(name string, args []object.Object) object.Object,
+) *object.Builtin {
+	name = "os." + name
+	return &object.Builtin{
+		Name: name,
+		Fn: func(args ...object.Object) object.Object {
+			return fn(name, args)
+		},
+		SideEffects: sideEffects,
+	}
+}
+
+// osReadFile implements read_file(path), which returns the contents of the
+// file at path.
+func (e *Evaluator) osReadFile(
+	name string,
+	args []object.Object,
+) object.Object {
+	if err := checkArgs(name, args, 1, object.STRING_OBJ); err != nil {
+		return err
+	}
+	path := stringValue(args[0])
+	// Check the file will fit before reading it. If it can't be statted,
+	// reading it will fail too, with a better error.
+	if info, err := os.Stat(path); err == nil {
+		if err := e.reserve(info.Size()); err != nil {
+			return err
+		}
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return newError("`%s` failed: %s", name, err)
+	}
+	return e.alloc(&object.String{Value: string(data)})
+}
+
+// osWriteFile implements write_file(path, contents), which creates or
+// replaces the file at path.
+func osWriteFile(name string, args []object.Object) object.Object {
+	err := checkArgs(name, args, 2, object.STRING_OBJ, object.STRING_OBJ)
+	if err != nil {
+		return err
+	}
+	data := []byte(stringValue(args[1]))
+	if err := ioutil.WriteFile(stringValue(args[0]), data, 0644); err != nil {
+		return newError("`%s` failed: %s", name, err)
+	}
+	return NULL
+}
+
+// osListDir implements list_dir(path), which returns the names of the
+// entries in the directory at path, in sorted order.
+func (e *Evaluator) osListDir(
+	name string,
+	args []object.Object,
+) object.Object {
+	if err := checkArgs(name, args, 1, object.STRING_OBJ); err != nil {
+		return err
+	}
+	infos, err := ioutil.ReadDir(stringValue(args[0]))
+	if err != nil {
+		return newError("`%s` failed: %s", name, err)
+	}
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = info.Name()
+	}
+	return e.stringArray(names)
+}
+
+// osExists implements exists(path), which reports whether there's a file or
+// directory at path.
+func osExists(name string, args []object.Object) object.Object {
+	if err := checkArgs(name, args, 1, object.STRING_OBJ); err != nil {
+		return err
+	}
+	_, err := os.Stat(stringValue(args[0]))
+	if os.IsNotExist(err) {
+		return FALSE
+	}
+	if err != nil {
+		return newError("`%s` failed: %s", name, err)
+	}
+	return TRUE
+}
+
+// osGetenv implements getenv(key), which returns the value of the
+// environment variable key, or null if it isn't set.
+func (e *Evaluator) osGetenv(
+	name string,
+	args []object.Object,
+) object.Object {
+	if err := checkArgs(name, args, 1, object.STRING_OBJ); err != nil {
+		return err
+	}
+	value, ok := os.LookupEnv(stringValue(args[0]))
+	if !ok {
+		return NULL
+	}
+	return e.alloc(&object.String{Value: value})
+}
+
+// readLine implements read_line(), which returns the next line of standard
+// input, without its line ending, or null at the end of the input.
+func (sys *System) readLine(name string, args []object.Object) object.Object {
+	if err := checkArgCount(name, args, 0, 0); err != nil {
+		return err
+	}
+	if sys.stdin == nil {
+		stdin := sys.Stdin
+		if stdin == nil {
+			stdin = os.Stdin
+		}
+		sys.stdin = bufio.NewReader(stdin)
+	}
+
+	line, err := sys.stdin.ReadString('\n')
+	if err == io.EOF && line == "" {
+		return NULL
+	}
+	if err != nil && err != io.EOF {
+		return newError("`%s` failed: %s", name, err)
+	}
+	line = strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\r")
+	return &object.String{Value: line}
+}
+
+// exit implements exit() and exit(status), which end the process with the
+// given status, defaulting to 0.
+func (sys *System) exit(name string, args []object.Object) object.Object {
+	values, err := integerArgs(name, args, 0, 1)
+	if err != nil {
+		return err
+	}
+	status := 0
+	if len(values) == 1 {
+		status = int(values[0])
+	}
+	exit := sys.Exit
+	if exit == nil {
+		exit = os.Exit
+	}
+	exit(status)
+	return newFatalError("program exited with status %d", status)
+}
@@ -0,0 +1,190 @@
+package evaluator
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestOSModule(t *testing.T) {
+	root := writeModules(t, map[string]string{
+		"a.txt":     "hello\nworld",
+		"b/c.txt":   "",
+		"b/d/e.txt": "",
+	})
+	os.Setenv("MONKEY_TEST_VAR", "banana")
+	defer os.Unsetenv("MONKEY_TEST_VAR")
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`os.read_file(dir + "/a.txt")`, "hello\nworld"},
+		{`os.write_file(dir + "/new.txt", "hi"); os.read_file(dir + "/new.txt")`, "hi"},
+		{`os.write_file(dir + "/a.txt", "replaced"); os.read_file(dir + "/a.txt")`, "replaced"},
+		{`os.list_dir(dir + "/b")`, `[c.txt, d]`},
+		{`os.exists(dir + "/b/d/e.txt")`, true},
+		{`os.exists(dir + "/b/d")`, true},
+		{`os.exists(dir + "/missing")`, false},
+		{`os.getenv("MONKEY_TEST_VAR")`, "banana"},
+		{`os.getenv("MONKEY_TEST_UNSET_VAR")`, nil},
+		{`os.args`, "[monkey, -v]"},
+
+		{`os.read_file(dir + "/missing")`,
+			"ERROR: `os.read_file` failed: open " +
+				filepath.Join(root, "missing") + ": no such file or directory"},
+		{`os.write_file(dir + "/missing/x", "")`,
+			"ERROR: `os.write_file` failed: open " +
+				filepath.Join(root, "missing", "x") + ": no such file or directory"},
+		{`os.list_dir(dir + "/missing")`,
+			"ERROR: `os.list_dir` failed: open " +
+				filepath.Join(root, "missing") + ": no such file or directory"},
+		{`os.write_file(dir)`,
+			"ERROR: wrong number of arguments to `os.write_file`: want 2, got 1"},
+		{`os.getenv(1)`,
+			"ERROR: argument to `os.getenv` must be STRING, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		opts := Options{System: &System{Args: []string{"monkey", "-v"}}}
+		input := "let os = import \"os\"; let dir = " + strconv.Quote(root) +
+			"; " + tt.input
+		evaluated := testEvalWithOptions(context.Background(), input, opts)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("%s - expected %q. got %q", tt.input, expected,
+					evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestOSReadLine(t *testing.T) {
+	sys := &System{Stdin: strings.NewReader("first\r\nsecond\n\nlast")}
+	// read_line returns null, which is falsy, at the end of the input.
+	input := `
+	let os = import "os";
+	let lines = "";
+	let line = os.read_line();
+	while (line) {
+		lines += line + "|";
+		line = os.read_line();
+	}
+	lines;
+	`
+
+	evaluated := testEvalWithOptions(context.Background(), input,
+		Options{System: sys})
+	expected := "first|second||last|"
+	if evaluated.Inspect() != expected {
+		t.Errorf("expected %q. got %q", expected, evaluated.Inspect())
+	}
+
+	// Input buffered by one Evaluator is seen by the next.
+	sys = &System{Stdin: strings.NewReader("a\nb\n")}
+	for _, expected := range []string{"a", "b"} {
+		evaluated := testEvalWithOptions(context.Background(),
+			`(import "os").read_line()`, Options{System: sys})
+		if evaluated.Inspect() != expected {
+			t.Errorf("expected %q. got %q", expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestOSExit(t *testing.T) {
+	var status []int
+	sys := &System{Exit: func(code int) { status = append(status, code) }}
+
+	evaluated := testEvalWithOptions(context.Background(),
+		`let os = import "os"; os.exit(3); 1`, Options{System: sys})
+	testErrorObject(t, evaluated, "program exited with status 3")
+
+	evaluated = testEvalWithOptions(context.Background(),
+		`(import "os").exit()`, Options{System: sys})
+	testErrorObject(t, evaluated, "program exited with status 0")
+
+	if len(status) != 2 || status[0] != 3 || status[1] != 0 {
+		t.Errorf("wrong exit statuses. expected [3 0], got %v", status)
+	}
+}
+
+func TestOSAccess(t *testing.T) {
+	root := writeModules(t, map[string]string{"a.txt": "secret"})
+	filename := strconv.Quote(filepath.Join(root, "a.txt"))
+
+	tests := []struct {
+		input           string
+		opts            Options
+		expectedMessage string
+	}{
+		{
+			`import "os"`,
+			Options{},
+			`cannot import "os": access to the system is not enabled`,
+		},
+		{
+			// A file called os.mk can't stand in for the module.
+			`import "os"`,
+			Options{Modules: NewModuleLoader(
+				writeModules(t, map[string]string{"os.mk": "let x = 1;"}),
+				nil)},
+			`cannot import "os": access to the system is not enabled`,
+		},
+		{
+			`(import "os").write_file(` + filename + `, "")`,
+			Options{System: &System{}, DisableSideEffects: true},
+			"side effects disabled: cannot call `os.write_file`",
+		},
+		{
+			`(import "os").exit(1)`,
+			Options{System: &System{}, DisableSideEffects: true},
+			"side effects disabled: cannot call `os.exit`",
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithOptions(context.Background(), tt.input,
+			tt.opts)
+		testErrorObject(t, evaluated, tt.expectedMessage)
+	}
+
+	// Reading is allowed without side effects.
+	evaluated := testEvalWithOptions(context.Background(),
+		`(import "os").read_file(`+filename+`)`,
+		Options{System: &System{}, DisableSideEffects: true})
+	if evaluated.Inspect() != "secret" {
+		t.Errorf("expected %q. got %q", "secret", evaluated.Inspect())
+	}
+	data, err := ioutil.ReadFile(filepath.Join(root, "a.txt"))
+	if err != nil || string(data) != "secret" {
+		t.Errorf("file was modified: %q, %v", data, err)
+	}
+}
+
+func TestOSMemoryLimit(t *testing.T) {
+	root := writeModules(t, map[string]string{
+		"big.txt":   strings.Repeat("a", 1<<20),
+		"small.txt": "a",
+	})
+	input := "let os = import \"os\"; let dir = " + strconv.Quote(root) + "; "
+	opts := Options{System: &System{}, MaxBytes: 100000}
+
+	// The file's size is checked before it's read.
+	evaluated := testEvalWithOptions(context.Background(),
+		input+`os.read_file(dir + "/big.txt")`, opts)
+	testErrorObject(t, evaluated, "memory limit exceeded: more than 100000 bytes")
+
+	evaluated = testEvalWithOptions(context.Background(),
+		input+`let s = ""; for (i in range(100000)) { s = os.read_file(dir + "/small.txt"); }`,
+		opts)
+	testErrorObject(t, evaluated, "memory limit exceeded: more than 100000 bytes")
+}
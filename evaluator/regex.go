@@ -0,0 +1,199 @@
+package evaluator
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jamesroutley/monkey/object"
+)
+
+// regexModule returns the members of the native regex module, which wraps
+// Go's regexp package, so patterns use its RE2 syntax.
+//
+// compile returns a compiled pattern. The other functions take either a
+// compiled pattern or a pattern to compile, as their first argument.
+func (e *Evaluator) regexModule() map[string]object.Object {
+	return map[string]object.Object{
+		"compile": &object.Builtin{
+			Name: "regex.compile",
+			Fn: func(args ...object.Object) object.Object {
+				err := checkArgs("regex.compile", args, 1, object.STRING_OBJ)
+				if err != nil {
+					return err
+				}
+				re, err := compileRegex("regex.compile", stringValue(args[0]))
+				if err != nil {
+					return err
+				}
+				return e.alloc(&object.Regex{Regexp: re})
+			},
+		},
+		"match": regexBuiltin("match", 2,
+			[]object.ObjectType{object.STRING_OBJ}, regexMatch),
+		"find": regexBuiltin("find", 2,
+			[]object.ObjectType{object.STRING_OBJ}, e.regexFind),
+		"find_all": regexBuiltin("find_all", 2,
+			[]object.ObjectType{object.STRING_OBJ, object.INTEGER_OBJ},
+			e.regexFindAll),
+		"replace": regexBuiltin("replace", 3,
+			[]object.ObjectType{object.STRING_OBJ, object.STRING_OBJ},
+			e.regexReplace),
+		"split": regexBuiltin("split", 2,
+			[]object.ObjectType{object.STRING_OBJ, object.INTEGER_OBJ},
+			e.regexSplit),
+	}
+}
+
+// regexBuiltin returns the builtin regex.name, which takes a regex or
+// pattern, followed by arguments of the given types, of which only the first
+// min-1 are required. fn is passed the builtin's full name, for error
+// messages, along with the compiled regex and the remaining arguments.
+func regexBuiltin(
+	name string,
+	min int,
+	types []object.ObjectType,
+	fn func(name string, re *regexp.Regexp, args []object.Object) object.Object,
+) *object.Builtin {
+	name = "regex." + name
+	return &object.Builtin{
+		Name: name,
+		Fn: func(args ...object.Object) object.Object {
+			if err := checkArgCount(name, args, min, len(types)+1); err != nil {
+				return err
+			}
+			var re *regexp.Regexp
+			switch arg := args[0].(type) {
+			case *object.Regex:
+				re = arg.Regexp
+			case *object.String:
+				var err *object.Error
+				re, err = compileRegex(name, arg.Value)
+				if err != nil {
+					return err
+				}
+			default:
+				return newError("argument to `%s` must be REGEX or STRING, "+
+					"got %s", name, object.TypeName(arg))
+			}
+			if err := checkArgs(name, args[1:], 0, types...); err != nil {
+				return err
+			}
+			return fn(name, re, args[1:])
+		},
+	}
+}
+
+// compileRegex compiles pattern for the builtin name. If pattern is invalid,
+// the error gives the offset, in characters, of the part of it which
+// couldn't be parsed, where that's known.
+func compileRegex(name, pattern string) (*regexp.Regexp, *object.Error) {
+	re, err := regexp.Compile(pattern)
+	if err == nil {
+		return re, nil
+	}
+	syntaxErr, ok := err.(*syntax.Error)
+	if !ok {
+		return nil, newError("`%s` failed: %s", name, err)
+	}
+
+	// Missing parentheses are reported against the whole pattern, and
+	// trailing backslashes against nothing, but both are at its end. Other
+	// errors reported against the whole pattern, like an unexpected ), don't
+	// say where in it they are.
+	var offset int
+	switch {
+	case syntaxErr.Expr == "" || syntaxErr.Code == syntax.ErrMissingParen:
+		offset = len(pattern)
+	case syntaxErr.Expr == pattern:
+		return nil, newError("`%s` invalid pattern %q: %s", name, pattern,
+			syntaxErr.Code)
+	default:
+		offset = strings.Index(pattern, syntaxErr.Expr)
+		if offset < 0 {
+			offset = len(pattern)
+		}
+	}
+	return nil, newError("`%s` invalid pattern %q at offset %d: %s", name,
+		pattern, utf8.RuneCountInString(pattern[:offset]), syntaxErr.Code)
+}
+
+// regexMatch implements match(re, s), which reports whether s contains a
+// match of re.
+func regexMatch(
+	name string,
+	re *regexp.Regexp,
+	args []object.Object,
+) object.Object {
+	return nativeBoolToBooleanObject(re.MatchString(stringValue(args[0])))
+}
+
+// regexFind implements find(re, s), which returns the first match of re in
+// s, or null if there isn't one.
+func (e *Evaluator) regexFind(
+	name string,
+	re *regexp.Regexp,
+	args []object.Object,
+) object.Object {
+	loc := re.FindStringIndex(stringValue(args[0]))
+	if loc == nil {
+		return NULL
+	}
+	return e.alloc(&object.String{Value: stringValue(args[0])[loc[0]:loc[1]]})
+}
+
+// regexFindAll implements find_all(re, s) and find_all(re, s, n), which
+// return the successive matches of re in s, stopping after n if n isn't
+// negative.
+func (e *Evaluator) regexFindAll(
+	name string,
+	re *regexp.Regexp,
+	args []object.Object,
+) object.Object {
+	n := int64(-1)
+	if len(args) == 2 {
+		n = args[1].(*object.Integer).Value
+	}
+	return e.stringArray(re.FindAllString(stringValue(args[0]), int(n)))
+}
+
+// regexReplace implements replace(re, s, replacement), which replaces every
+// match of re in s. $1 or ${1} in replacement stands for the text of the
+// first capture group, and ${name} for the group called name.
+//
+// Like re.ReplaceAllString, but the result is checked against e's limits as
+// it's built, as each match can be replaced by much more text.
+func (e *Evaluator) regexReplace(
+	name string,
+	re *regexp.Regexp,
+	args []object.Object,
+) object.Object {
+	s, template := stringValue(args[0]), stringValue(args[1])
+	var out []byte
+	last := 0
+	for _, match := range re.FindAllStringSubmatchIndex(s, -1) {
+		out = append(out, s[last:match[0]]...)
+		out = re.ExpandString(out, template, s, match)
+		last = match[1]
+		if err := e.checkStringLength(name, int64(len(out))); err != nil {
+			return err
+		}
+	}
+	out = append(out, s[last:]...)
+	return e.alloc(&object.String{Value: string(out)})
+}
+
+// regexSplit implements split(re, s) and split(re, s, n), which split s
+// around the matches of re, into at most n parts if n isn't negative.
+func (e *Evaluator) regexSplit(
+	name string,
+	re *regexp.Regexp,
+	args []object.Object,
+) object.Object {
+	n := int64(-1)
+	if len(args) == 2 {
+		n = args[1].(*object.Integer).Value
+	}
+	return e.stringArray(re.Split(stringValue(args[0]), int(n)))
+}
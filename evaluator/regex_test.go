@@ -0,0 +1,97 @@
+package evaluator
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRegexModule(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`regex.compile("a+b")`, "regex(a+b)"},
+		{`let re = regex.compile("\\d+"); re`, `regex(\d+)`},
+		{`regex.match("\\d+", "abc123")`, true},
+		{`regex.match(regex.compile("^\\d+$"), "abc123")`, false},
+		{`regex.find("\\d+", "abc123def45")`, "123"},
+		{`regex.find("\\d+", "abc")`, nil},
+		{`regex.find("x*", "abc")`, ""},
+		{`regex.find_all("\\d+", "a1b22c333")`, "[1, 22, 333]"},
+		{`regex.find_all("\\d+", "a1b22c333", 2)`, "[1, 22]"},
+		{`regex.find_all("\\d+", "abc")`, "[]"},
+		{`regex.replace("(\\w+)@(\\w+)", "me@host, you@there", "$2 for $1")`,
+			"host for me, there for you"},
+		{`regex.replace("(?P<first>\\w+) (?P<last>\\w+)", "Ada Lovelace", "${last}, ${first}")`,
+			"Lovelace, Ada"},
+		{`regex.replace("a(x*)b", "-ab-axxb-", "${1}W")`, "-W-xxW-"},
+		{`regex.split(",\\s*", "a, b,c,   d")`, "[a, b, c, d]"},
+		{`regex.split(",", "a,b,c", 2)`, "[a, b,c]"},
+		{`regex.split("é", "aébéc")`, "[a, b, c]"},
+
+		{`regex.compile("a(b")`,
+			"ERROR: `regex.compile` invalid pattern \"a(b\" at offset 3: " +
+				"missing closing )"},
+		{`regex.compile("ab)")`,
+			"ERROR: `regex.compile` invalid pattern \"ab)\": unexpected )"},
+		{`regex.compile("a)b")`,
+			"ERROR: `regex.compile` invalid pattern \"a)b\": unexpected )"},
+		{`regex.compile("éa**")`,
+			"ERROR: `regex.compile` invalid pattern \"éa**\" at offset 2: " +
+				"invalid nested repetition operator"},
+		{`regex.compile("[z-a]")`,
+			"ERROR: `regex.compile` invalid pattern \"[z-a]\" at offset 1: " +
+				"invalid character class range"},
+		{`regex.compile("ab\\")`,
+			"ERROR: `regex.compile` invalid pattern \"ab\\\\\" at offset 3: " +
+				"trailing backslash at end of expression"},
+		{`regex.match("x\\q", "")`,
+			"ERROR: `regex.match` invalid pattern \"x\\\\q\" at offset 1: " +
+				"invalid escape sequence"},
+		{`regex.compile(1)`,
+			"ERROR: argument to `regex.compile` must be STRING, got INTEGER"},
+		{`regex.match(1, "a")`,
+			"ERROR: argument to `regex.match` must be REGEX or STRING, got INTEGER"},
+		{`regex.match("a", 1)`,
+			"ERROR: argument to `regex.match` must be STRING, got INTEGER"},
+		{`regex.find_all("a", "a", "2")`,
+			"ERROR: argument to `regex.find_all` must be INTEGER, got STRING"},
+		{`regex.replace("a", "a")`,
+			"ERROR: wrong number of arguments to `regex.replace`: want 3, got 2"},
+		{`regex.split("a")`,
+			"ERROR: wrong number of arguments to `regex.split`: want 2 to 3, got 1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(`let regex = import "regex"; ` + tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("%s - expected %q. got %q", tt.input, expected,
+					evaluated.Inspect())
+			}
+		}
+	}
+}
+
+// The strings the module builds count towards the program's byte limit.
+func TestRegexMemoryLimit(t *testing.T) {
+	tests := []string{
+		`let s = strings.repeat("a", 1000); regex.replace("a", s, s)`,
+		`regex.replace("(a)", strings.repeat("a", 1000), strings.repeat("$1", 500))`,
+		`regex.find_all(".", strings.repeat("a", 10000))`,
+		`regex.split("", strings.repeat("a", 10000))`,
+	}
+
+	for _, input := range tests {
+		evaluated := testEvalWithOptions(context.Background(),
+			`let regex = import "regex"; let strings = import "strings"; `+
+				input, Options{MaxBytes: 100000})
+		testErrorObject(t, evaluated,
+			"memory limit exceeded: more than 100000 bytes")
+	}
+}
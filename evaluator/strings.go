@@ -0,0 +1,357 @@
+package evaluator
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/jamesroutley/monkey/object"
+)
+
+// maxStringLength is the length, in bytes, of the longest string the strings
+// module will build.
+const maxStringLength = 1 << 30
+
+// stringsModule returns the members of the native strings module. Like len
+// and string indexing, its functions count characters rather than bytes.
+func (e *Evaluator) stringsModule() map[string]object.Object {
+	return map[string]object.Object{
+		"split":       stringsBuiltin("split", e.stringsSplit),
+		"join":        stringsBuiltin("join", e.stringsJoin),
+		"trim":        stringsBuiltin("trim", e.stringsTrim),
+		"replace":     stringsBuiltin("replace", e.stringsReplace),
+		"contains":    stringsBuiltin("contains", stringsContains),
+		"starts_with": stringsBuiltin("starts_with", stringsStartsWith),
+		"ends_with":   stringsBuiltin("ends_with", stringsEndsWith),
+		"upper":       stringsBuiltin("upper", e.stringsUpper),
+		"lower":       stringsBuiltin("lower", e.stringsLower),
+		"index_of":    stringsBuiltin("index_of", e.stringsIndexOf),
+		"repeat":      stringsBuiltin("repeat", e.stringsRepeat),
+		"pad_left":    stringsBuiltin("pad_left", e.stringsPadLeft),
+		"pad_right":   stringsBuiltin("pad_right", e.stringsPadRight),
+		"format":      stringsBuiltin("format", e.stringsFormat),
+	}
+}
+
+// stringsBuiltin returns the builtin strings.name. fn is passed the
+// builtin's full name, for error messages, along with its arguments.
+func stringsBuiltin(
+	name string,
+	fn func(name string, args []object.Object) object.Object,
+) *object.Builtin {
+	name = "strings." + name
+	return &object.Builtin{
+		Name: name,
+		Fn: func(args ...object.Object) object.Object {
+			return fn(name, args)
+		},
+	}
+}
+
+// stringValue returns the value of a String which has been checked by
+// checkArgs.
+func stringValue(obj object.Object) string {
+	return obj.(*object.String).Value
+}
+
+// checkStringLength returns an error if a string of n bytes, which the
+// builtin name is about to build, would be longer than maxStringLength or
+// take the program over its byte limit.
+func (e *Evaluator) checkStringLength(name string, n int64) *object.Error {
+	if n > maxStringLength {
+		return newError("`%s` result too long: %d bytes", name, n)
+	}
+	return e.reserve(n)
+}
+
+// stringArray returns an Array of the strings in values.
+func (e *Evaluator) stringArray(values []string) object.Object {
+	elements := make([]object.Object, len(values))
+	for i, value := range values {
+		elements[i] = e.alloc(&object.String{Value: value})
+		if isError(elements[i]) {
+			return elements[i]
+		}
+	}
+	return e.alloc(&object.Array{Elements: elements})
+}
+
+// stringsSplit implements split(s, sep). If sep is empty, s is split into
+// characters.
+func (e *Evaluator) stringsSplit(
+	name string,
+	args []object.Object,
+) object.Object {
+	err := checkArgs(name, args, 2, object.STRING_OBJ, object.STRING_OBJ)
+	if err != nil {
+		return err
+	}
+	return e.stringArray(
+		strings.Split(stringValue(args[0]), stringValue(args[1])))
+}
+
+// stringsJoin implements join(array, sep). The array's elements must be
+// strings.
+func (e *Evaluator) stringsJoin(
+	name string,
+	args []object.Object,
+) object.Object {
+	err := checkArgs(name, args, 2, object.ARRAY_OBJ, object.STRING_OBJ)
+	if err != nil {
+		return err
+	}
+	elements := args[0].(*object.Array).Elements
+	sep := stringValue(args[1])
+	parts := make([]string, len(elements))
+	length := int64(0)
+	for i, el := range elements {
+		str, ok := el.(*object.String)
+		if !ok {
+			return newError("`%s` element %d must be STRING, got %s", name,
+				i, object.TypeName(el))
+		}
+		parts[i] = str.Value
+		length += int64(len(str.Value) + len(sep))
+	}
+	if err := e.checkStringLength(name, length); err != nil {
+		return err
+	}
+	return e.alloc(&object.String{Value: strings.Join(parts, sep)})
+}
+
+// stringsTrim implements trim(s) and trim(s, chars), which remove leading
+// and trailing whitespace, or characters in chars, from s.
+func (e *Evaluator) stringsTrim(
+	name string,
+	args []object.Object,
+) object.Object {
+	err := checkArgs(name, args, 1, object.STRING_OBJ, object.STRING_OBJ)
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		trimmed := strings.TrimSpace(stringValue(args[0]))
+		return e.alloc(&object.String{Value: trimmed})
+	}
+	trimmed := strings.Trim(stringValue(args[0]), stringValue(args[1]))
+	return e.alloc(&object.String{Value: trimmed})
+}
+
+// stringsReplace implements replace(s, old, new), which replaces every
+// occurrence of old in s.
+func (e *Evaluator) stringsReplace(
+	name string,
+	args []object.Object,
+) object.Object {
+	err := checkArgs(name, args, 3,
+		object.STRING_OBJ, object.STRING_OBJ, object.STRING_OBJ)
+	if err != nil {
+		return err
+	}
+	s, old, replacement := stringValue(args[0]), stringValue(args[1]),
+		stringValue(args[2])
+	count := int64(strings.Count(s, old))
+	length := int64(len(s)) + count*int64(len(replacement)-len(old))
+	if err := e.checkStringLength(name, length); err != nil {
+		return err
+	}
+	return e.alloc(&object.String{Value: strings.ReplaceAll(s, old, replacement)})
+}
+
+func stringsContains(name string, args []object.Object) object.Object {
+	err := checkArgs(name, args, 2, object.STRING_OBJ, object.STRING_OBJ)
+	if err != nil {
+		return err
+	}
+	return nativeBoolToBooleanObject(
+		strings.Contains(stringValue(args[0]), stringValue(args[1])))
+}
+
+func stringsStartsWith(name string, args []object.Object) object.Object {
+	err := checkArgs(name, args, 2, object.STRING_OBJ, object.STRING_OBJ)
+	if err != nil {
+		return err
+	}
+	return nativeBoolToBooleanObject(
+		strings.HasPrefix(stringValue(args[0]), stringValue(args[1])))
+}
+
+func stringsEndsWith(name string, args []object.Object) object.Object {
+	err := checkArgs(name, args, 2, object.STRING_OBJ, object.STRING_OBJ)
+	if err != nil {
+		return err
+	}
+	return nativeBoolToBooleanObject(
+		strings.HasSuffix(stringValue(args[0]), stringValue(args[1])))
+}
+
+func (e *Evaluator) stringsUpper(
+	name string,
+	args []object.Object,
+) object.Object {
+	if err := checkArgs(name, args, 1, object.STRING_OBJ); err != nil {
+		return err
+	}
+	return e.alloc(&object.String{Value: strings.ToUpper(stringValue(args[0]))})
+}
+
+func (e *Evaluator) stringsLower(
+	name string,
+	args []object.Object,
+) object.Object {
+	if err := checkArgs(name, args, 1, object.STRING_OBJ); err != nil {
+		return err
+	}
+	return e.alloc(&object.String{Value: strings.ToLower(stringValue(args[0]))})
+}
+
+// stringsIndexOf implements index_of(s, sub), which returns the index of the
+// first character of the first occurrence of sub in s, or -1 if there isn't
+// one.
+func (e *Evaluator) stringsIndexOf(
+	name string,
+	args []object.Object,
+) object.Object {
+	err := checkArgs(name, args, 2, object.STRING_OBJ, object.STRING_OBJ)
+	if err != nil {
+		return err
+	}
+	s := stringValue(args[0])
+	i := strings.Index(s, stringValue(args[1]))
+	if i < 0 {
+		return e.alloc(&object.Integer{Value: -1})
+	}
+	return e.alloc(&object.Integer{Value: int64(utf8.RuneCountInString(s[:i]))})
+}
+
+func (e *Evaluator) stringsRepeat(
+	name string,
+	args []object.Object,
+) object.Object {
+	err := checkArgs(name, args, 2, object.STRING_OBJ, object.INTEGER_OBJ)
+	if err != nil {
+		return err
+	}
+	s := stringValue(args[0])
+	count := args[1].(*object.Integer).Value
+	if count < 0 {
+		return newError("`%s` count must not be negative, got %d", name,
+			count)
+	}
+	if len(s) > 0 && count > maxStringLength/int64(len(s)) {
+		return newError("`%s` result too long: %d copies of %d bytes", name,
+			count, len(s))
+	}
+	if err := e.reserve(count * int64(len(s))); err != nil {
+		return err
+	}
+	return e.alloc(&object.String{Value: strings.Repeat(s, int(count))})
+}
+
+// stringsPadLeft implements pad_left(s, width) and pad_left(s, width, pad),
+// which add pad, a single character defaulting to a space, to the start of
+// s until it's width characters long.
+func (e *Evaluator) stringsPadLeft(
+	name string,
+	args []object.Object,
+) object.Object {
+	return e.pad(name, args, func(s, padding string) string {
+		return padding + s
+	})
+}
+
+// stringsPadRight is like stringsPadLeft, but pads the end of s.
+func (e *Evaluator) stringsPadRight(
+	name string,
+	args []object.Object,
+) object.Object {
+	return e.pad(name, args, func(s, padding string) string {
+		return s + padding
+	})
+}
+
+func (e *Evaluator) pad(
+	name string,
+	args []object.Object,
+	join func(s, padding string) string,
+) object.Object {
+	err := checkArgs(name, args, 2,
+		object.STRING_OBJ, object.INTEGER_OBJ, object.STRING_OBJ)
+	if err != nil {
+		return err
+	}
+	s := stringValue(args[0])
+	width := args[1].(*object.Integer).Value
+	padChar := " "
+	if len(args) == 3 {
+		padChar = stringValue(args[2])
+		if utf8.RuneCountInString(padChar) != 1 {
+			return newError("`%s` pad must be a single character, got %q",
+				name, padChar)
+		}
+	}
+
+	length := int64(utf8.RuneCountInString(s))
+	if width <= length {
+		return args[0]
+	}
+	if width-length > maxStringLength/int64(len(padChar)) {
+		return newError("`%s` result too long: width %d", name, width)
+	}
+	size := int64(len(s)) + (width-length)*int64(len(padChar))
+	if err := e.reserve(size); err != nil {
+		return err
+	}
+	padding := strings.Repeat(padChar, int(width-length))
+	return e.alloc(&object.String{Value: join(s, padding)})
+}
+
+// stringsFormat implements format(template, values...). Each {} in the
+// template is replaced by the next value, as inspected, and {{ and }} stand
+// for literal braces.
+func (e *Evaluator) stringsFormat(
+	name string,
+	args []object.Object,
+) object.Object {
+	if err := checkArgCount(name, args, 1, -1); err != nil {
+		return err
+	}
+	if err := checkArgs(name, args[:1], 1, object.STRING_OBJ); err != nil {
+		return err
+	}
+	template := stringValue(args[0])
+	values := args[1:]
+
+	var out strings.Builder
+	next := 0
+	for i := 0; i < len(template); i++ {
+		switch {
+		case strings.HasPrefix(template[i:], "{{"):
+			out.WriteByte('{')
+			i++
+		case strings.HasPrefix(template[i:], "}}"):
+			out.WriteByte('}')
+			i++
+		case strings.HasPrefix(template[i:], "{}"):
+			if next >= len(values) {
+				return newError("`%s` has more placeholders than values: "+
+					"got %d values", name, len(values))
+			}
+			out.WriteString(values[next].Inspect())
+			next++
+			i++
+			if err := e.checkStringLength(name, int64(out.Len())); err != nil {
+				return err
+			}
+		case template[i] == '{' || template[i] == '}':
+			return newError("`%s` has an unmatched %q at offset %d", name,
+				template[i], i)
+		default:
+			out.WriteByte(template[i])
+		}
+	}
+	if next < len(values) {
+		return newError("`%s` has more values than placeholders: "+
+			"got %d values for %d placeholders", name, len(values), next)
+	}
+	return e.alloc(&object.String{Value: out.String()})
+}
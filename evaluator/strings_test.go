@@ -0,0 +1,100 @@
+package evaluator
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStringsModule(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`strings.split("a,b,c", ",")`, `[a, b, c]`},
+		{`strings.split("héy", "")`, `[h, é, y]`},
+		{`strings.split("", ",")`, `[]`},
+		{`strings.join(["a", "b", "c"], ", ")`, `a, b, c`},
+		{`strings.join([], "-")`, ``},
+		{`strings.trim("  hi \n")`, `hi`},
+		{`strings.trim("xxhixx", "x")`, `hi`},
+		{`strings.replace("a-b-c", "-", "+")`, `a+b+c`},
+		{`strings.contains("monkey", "key")`, `true`},
+		{`strings.contains("monkey", "donkey")`, `false`},
+		{`strings.starts_with("monkey", "mon")`, `true`},
+		{`strings.starts_with("monkey", "key")`, `false`},
+		{`strings.ends_with("monkey", "key")`, `true`},
+		{`strings.ends_with("monkey", "mon")`, `false`},
+		{`strings.upper("Héllo")`, `HÉLLO`},
+		{`strings.lower("HÉLLO")`, `héllo`},
+		{`strings.index_of("héllo", "l")`, `2`},
+		{`strings.index_of("hello", "z")`, `-1`},
+		{`strings.repeat("ab", 3)`, `ababab`},
+		{`strings.repeat("ab", 0)`, ``},
+		{`strings.pad_left("7", 3)`, `  7`},
+		{`strings.pad_left("7", 3, "0")`, `007`},
+		{`strings.pad_right("é", 3, "·")`, `é··`},
+		{`strings.pad_left("long", 2)`, `long`},
+		{`strings.format("{} has {} items", "cart", 3)`, `cart has 3 items`},
+		{`strings.format("{}: {}", [1, 2], {"a": true})`, `[1, 2]: {a: true}`},
+		{`strings.format("{{}} {}", if (false) { 1 })`, `{} null`},
+		{`strings.format("plain")`, `plain`},
+
+		{`strings.split("a")`,
+			"ERROR: wrong number of arguments to `strings.split`: want 2, got 1"},
+		{`strings.upper(1)`,
+			"ERROR: argument to `strings.upper` must be STRING, got INTEGER"},
+		{`strings.trim("a", "b", "c")`,
+			"ERROR: wrong number of arguments to `strings.trim`: want 1 to 2, got 3"},
+		{`strings.join(["a", 1], "")`,
+			"ERROR: `strings.join` element 1 must be STRING, got INTEGER"},
+		{`strings.repeat("a", -1)`,
+			"ERROR: `strings.repeat` count must not be negative, got -1"},
+		{`strings.repeat("ab", 9223372036854775807)`,
+			"ERROR: `strings.repeat` result too long: " +
+				"9223372036854775807 copies of 2 bytes"},
+		{`strings.pad_left("a", 3, "ab")`,
+			"ERROR: `strings.pad_left` pad must be a single character, got \"ab\""},
+		{`strings.format()`,
+			"ERROR: wrong number of arguments to `strings.format`: want at least 1, got 0"},
+		{`strings.format(1)`,
+			"ERROR: argument to `strings.format` must be STRING, got INTEGER"},
+		{`strings.format("{} {}", 1)`,
+			"ERROR: `strings.format` has more placeholders than values: got 1 values"},
+		{`strings.format("{}", 1, 2)`,
+			"ERROR: `strings.format` has more values than placeholders: " +
+				"got 2 values for 1 placeholders"},
+		{`strings.format("{ {}", 1)`,
+			"ERROR: `strings.format` has an unmatched '{' at offset 0"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(`let strings = import "strings"; ` + tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s - expected %q. got %q", tt.input, tt.expected,
+				evaluated.Inspect())
+		}
+	}
+}
+
+// The strings the module builds count towards the program's byte limit, and
+// are checked against it before they're built.
+func TestStringsMemoryLimit(t *testing.T) {
+	tests := []string{
+		`strings.repeat("ab", 100000000)`,
+		`strings.pad_left("a", 100000000)`,
+		`strings.pad_right("a", 100000000, "b")`,
+		`let s = strings.repeat("a", 1000); strings.replace(s, "a", s)`,
+		`let s = strings.repeat("a", 10000); strings.join([s, s, s, s, s, s, s, s, s, s, s, s, s, s, s, s], s)`,
+		`let s = strings.repeat("a", 100000); strings.format("{}{}{}", s, s, s)`,
+		`strings.split(strings.repeat("a,", 10000), ",")`,
+		`let s = strings.repeat("a", 100000); [strings.upper(s), strings.lower(s)]`,
+	}
+
+	for _, input := range tests {
+		evaluated := testEvalWithOptions(context.Background(),
+			`let strings = import "strings"; `+input,
+			Options{MaxBytes: 250000})
+		testErrorObject(t, evaluated,
+			"memory limit exceeded: more than 250000 bytes")
+	}
+}
@@ -0,0 +1,194 @@
+package evaluator
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/jamesroutley/monkey/object"
+)
+
+// maxDuration is the longest duration, in milliseconds, which can be
+// represented as a time.Duration.
+const maxDuration = math.MaxInt64 / int64(time.Millisecond)
+
+// timeModule returns the members of the native time module, which tells the
+// time using e's Clock.
+//
+// Times are represented as integers: the number of milliseconds since the
+// Unix epoch. Durations are numbers of milliseconds too, so they can be added
+// to times and each other with the usual operators, and built from the
+// module's units, e.g. 2 * time.hour. Times are formatted and parsed in UTC,
+// using Go's layout strings, which write out how the reference time
+// 2006-01-02 15:04:05 would be formatted.
+func (e *Evaluator) timeModule() map[string]object.Object {
+	clock := e.opts.Clock
+	if clock == nil {
+		clock = systemClock{}
+	}
+	start := clock.Now()
+
+	return map[string]object.Object{
+		"millisecond": &object.Integer{Value: 1},
+		"second":      &object.Integer{Value: 1000},
+		"minute":      &object.Integer{Value: 60 * 1000},
+		"hour":        &object.Integer{Value: 60 * 60 * 1000},
+		"day":         &object.Integer{Value: 24 * 60 * 60 * 1000},
+
+		"timestamp": &object.String{Value: time.RFC3339}, // RFC 3339.
+		"date":      &object.String{Value: "2006-01-02"},
+		"datetime":  &object.String{Value: "2006-01-02 15:04:05"},
+
+		"now": e.timeBuiltin("now",
+			func(name string, args []object.Object) object.Object {
+				if err := checkArgCount(name, args, 0, 0); err != nil {
+					return err
+				}
+				return &object.Integer{Value: clock.Now().UnixMilli()}
+			}),
+		"unix": e.timeBuiltin("unix",
+			func(name string, args []object.Object) object.Object {
+				if err := checkArgCount(name, args, 0, 0); err != nil {
+					return err
+				}
+				return &object.Integer{Value: clock.Now().Unix()}
+			}),
+		// monotonic returns the milliseconds elapsed since the module was
+		// imported. Unlike now, it never goes backwards when the system
+		// clock is changed.
+		"monotonic": e.timeBuiltin("monotonic",
+			func(name string, args []object.Object) object.Object {
+				if err := checkArgCount(name, args, 0, 0); err != nil {
+					return err
+				}
+				elapsed := clock.Now().Sub(start)
+				return &object.Integer{Value: elapsed.Milliseconds()}
+			}),
+		"sleep": e.timeBuiltin("sleep",
+			func(name string, args []object.Object) object.Object {
+				values, err := integerArgs(name, args, 1, 1)
+				if err != nil {
+					return err
+				}
+				d, err := toDuration(name, values[0])
+				if err != nil {
+					return err
+				}
+				if err := clock.Sleep(e.ctx, d); err != nil {
+					return newFatalError("execution cancelled: %s", err)
+				}
+				return NULL
+			}),
+
+		"format":          e.timeBuiltin("format", timeFormat),
+		"parse":           e.timeBuiltin("parse", timeParse),
+		"duration":        e.timeBuiltin("duration", timeDuration),
+		"format_duration": e.timeBuiltin("format_duration", timeFormatDuration),
+	}
+}
+
+// timeBuiltin returns the builtin time.name. fn is passed the builtin's full
+// name, for error messages, along with its arguments. The integers and
+// strings it returns are accounted for by e.
+func (e *Evaluator) timeBuiltin(
+	name string,
+	fn func(name string, args []object.Object) object.Object,
+) *object.Builtin {
+	name = "time." + name
+	return &object.Builtin{
+		Name: name,
+		Fn: func(args ...object.Object) object.Object {
+			result := fn(name, args)
+			switch result.(type) {
+			case *object.Integer, *object.String:
+				return e.alloc(result)
+			}
+			return result
+		},
+	}
+}
+
+// toDuration converts ms, a number of milliseconds passed to the builtin name,
+// to a time.Duration.
+func toDuration(name string, ms int64) (time.Duration, *object.Error) {
+	if ms < 0 {
+		return 0, newError("`%s` duration must not be negative, got %d",
+			name, ms)
+	}
+	if ms > maxDuration {
+		return 0, newError("`%s` duration too long: %d milliseconds", name,
+			ms)
+	}
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// timeFormat implements format(t, layout), which formats the time t using
+// layout.
+func timeFormat(name string, args []object.Object) object.Object {
+	err := checkArgs(name, args, 2, object.INTEGER_OBJ, object.STRING_OBJ)
+	if err != nil {
+		return err
+	}
+	t := time.UnixMilli(args[0].(*object.Integer).Value).UTC()
+	return &object.String{Value: t.Format(stringValue(args[1]))}
+}
+
+// timeParse implements parse(s, layout), which parses the time s, formatted
+// using layout. Times without a time zone are taken to be in UTC.
+func timeParse(name string, args []object.Object) object.Object {
+	err := checkArgs(name, args, 2, object.STRING_OBJ, object.STRING_OBJ)
+	if err != nil {
+		return err
+	}
+	t, parseErr := time.Parse(stringValue(args[1]), stringValue(args[0]))
+	if parseErr != nil {
+		return newError("`%s` failed: %s", name, parseErr)
+	}
+	return &object.Integer{Value: t.UnixMilli()}
+}
+
+// timeDuration implements duration(s), which parses a duration like "1h30m"
+// or "-2.5s", and returns it in milliseconds, truncating any fraction.
+func timeDuration(name string, args []object.Object) object.Object {
+	if err := checkArgs(name, args, 1, object.STRING_OBJ); err != nil {
+		return err
+	}
+	d, err := time.ParseDuration(stringValue(args[0]))
+	if err != nil {
+		return newError("`%s` failed: %s", name, err)
+	}
+	return &object.Integer{Value: d.Milliseconds()}
+}
+
+// timeFormatDuration implements format_duration(d), which formats the
+// duration d, in milliseconds, like "1h30m0s".
+func timeFormatDuration(name string, args []object.Object) object.Object {
+	values, err := integerArgs(name, args, 1, 1)
+	if err != nil {
+		return err
+	}
+	ms := values[0]
+	if ms > maxDuration || ms < -maxDuration {
+		return newError("`%s` duration too long: %d milliseconds", name, ms)
+	}
+	d := time.Duration(ms) * time.Millisecond
+	return &object.String{Value: d.String()}
+}
+
+// systemClock is the Clock used when the host doesn't provide one.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+func (systemClock) Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,143 @@
+package evaluator
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jamesroutley/monkey/object"
+)
+
+func TestTimeModule(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"time.now()", 1136214245000},
+		{"time.unix()", 1136214245},
+		{"time.now() + 2 * time.hour", 1136221445000},
+		{"time.day / time.second", 86400},
+		{"time.monotonic()", 0},
+		{"time.sleep(1500); time.monotonic()", 1500},
+		{"time.sleep(0); time.sleep(time.minute); time.now()", 1136214305000},
+
+		{"time.format(time.now(), time.timestamp)", "2006-01-02T15:04:05Z"},
+		{"time.format(time.now() + time.day, time.date)", "2006-01-03"},
+		{"time.format(1500, time.datetime)", "1970-01-01 00:00:01"},
+		{`time.format(0, "Jan 2, 2006 at 3:04pm")`, "Jan 1, 1970 at 12:00am"},
+		{`time.parse("2006-01-02", time.date)`, 1136160000000},
+		{`time.parse("2006-01-02T15:04:05.5+01:00", time.timestamp)`, 1136210645500},
+		{`let t = time.now(); time.parse(time.format(t, time.timestamp), time.timestamp) == t`,
+			true},
+		{`time.duration("1h30m")`, 5400000},
+		{`time.duration("-2.5s")`, -2500},
+		{`time.duration("1us")`, 0},
+		{`time.format_duration(5400000)`, "1h30m0s"},
+		{`time.format_duration(-1)`, "-1ms"},
+		{`time.format_duration(0)`, "0s"},
+
+		{"time.now(1)", "ERROR: wrong number of arguments to `time.now`: want 0, got 1"},
+		{"time.sleep(-1)", "ERROR: `time.sleep` duration must not be negative, got -1"},
+		{"time.sleep(9223372036854775807)",
+			"ERROR: `time.sleep` duration too long: 9223372036854775807 milliseconds"},
+		{`time.sleep("1s")`, "ERROR: argument to `time.sleep` must be INTEGER, got STRING"},
+		{`time.format("now", time.date)`,
+			"ERROR: argument to `time.format` must be INTEGER, got STRING"},
+		{`time.parse("yesterday", time.date)`,
+			"ERROR: `time.parse` failed: parsing time \"yesterday\" as \"2006-01-02\": " +
+				"cannot parse \"yesterday\" as \"2006\""},
+		{`time.duration("soon")`, "ERROR: `time.duration` failed: time: invalid duration \"soon\""},
+		{`time.format_duration(9223372036854775807)`,
+			"ERROR: `time.format_duration` duration too long: " +
+				"9223372036854775807 milliseconds"},
+	}
+
+	for _, tt := range tests {
+		clock := &fakeClock{now: time.Date(2006, 1, 2, 15, 4, 5, 0, time.UTC)}
+		evaluated := testEvalWithOptions(context.Background(),
+			`let time = import "time"; `+tt.input, Options{Clock: clock})
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			if evaluated.Inspect() != expected {
+				t.Errorf("%s - expected %q. got %q", tt.input, expected,
+					evaluated.Inspect())
+			}
+		}
+	}
+}
+
+func TestTimeSleepCancellation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(),
+		10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	evaluated := testEvalWithOptions(ctx,
+		`let time = import "time"; time.sleep(time.hour)`, Options{})
+	testErrorObject(t, evaluated,
+		"execution cancelled: context deadline exceeded")
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("sleep wasn't interrupted: took %s", elapsed)
+	}
+
+	// The system clock is used by default.
+	evaluated = testEvalWithOptions(context.Background(),
+		`let time = import "time"; time.sleep(20); time.monotonic()`,
+		Options{})
+	elapsed, ok := evaluated.(*object.Integer)
+	if !ok {
+		t.Fatalf("object is not an Integer. got %T (%+v)", evaluated,
+			evaluated)
+	}
+	if elapsed.Value < 20 {
+		t.Errorf("slept for less than 20ms: %dms", elapsed.Value)
+	}
+}
+
+// fakeClock is a Clock whose time only passes when it's slept on.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.now = c.now.Add(d)
+	return nil
+}
+
+// The values the module returns count towards the program's limits.
+func TestTimeMemoryLimit(t *testing.T) {
+	tests := []struct {
+		input    string
+		options  Options
+		expected string
+	}{
+		{
+			`let layout = strings.repeat("Monday ", 20000); time.format(0, layout)`,
+			Options{MaxBytes: 250000},
+			"memory limit exceeded: more than 250000 bytes",
+		},
+		{
+			`let t = []; while (true) { t = time.now(); }`,
+			Options{MaxObjects: 1000},
+			"object limit exceeded: more than 1000 objects",
+		},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEvalWithOptions(context.Background(),
+			`let time = import "time"; let strings = import "strings"; `+
+				tt.input, tt.options)
+		testErrorObject(t, evaluated, tt.expected)
+	}
+}
@@ -1,6 +1,8 @@
 package lexer
 
 import (
+	"strings"
+
 	"github.com/jamesroutley/monkey/token"
 )
 
@@ -10,11 +12,13 @@ type Lexer struct {
 	position     int  // current position in input (points to  current char)
 	readPosition int  // current reading position in input (after current char)
 	ch           byte // current char under examination
+	line         int  // line of the current char
+	column       int  // column of the current char
 }
 
 // New initialises and returns a Lexer
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
@@ -26,69 +30,104 @@ func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 
 	l.skipWhitespace()
+	pos := token.Position{Line: l.line, Column: l.column}
 
-	switch l.ch {
-	case '=':
-		if l.peekChar() == '=' {
-			ch := l.ch
-			l.readChar()
-			tok = token.Token{Type: token.EQ, Literal: string(ch) + string(l.ch)}
-		} else {
-			tok = newToken(token.ASSIGN, l.ch)
-		}
-	case ';':
-		tok = newToken(token.SEMICOLON, l.ch)
-	case '(':
-		tok = newToken(token.LPAREN, l.ch)
-	case ')':
-		tok = newToken(token.RPAREN, l.ch)
-	case ',':
-		tok = newToken(token.COMMA, l.ch)
-	case '+':
-		tok = newToken(token.PLUS, l.ch)
-	case '-':
-		tok = newToken(token.MINUS, l.ch)
-	case '!':
-		if l.peekChar() == '=' {
-			ch := l.ch
-			l.readChar()
-			tok = token.Token{Type: token.NOT_EQ, Literal: string(ch) + string(l.ch)}
-		} else {
-			tok = newToken(token.BANG, l.ch)
-		}
-	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
-	case '/':
-		tok = newToken(token.SLASH, l.ch)
-	case '<':
-		tok = newToken(token.LT, l.ch)
-	case '>':
-		tok = newToken(token.GT, l.ch)
-	case '{':
-		tok = newToken(token.RBRACE, l.ch)
-	case '}':
-		tok = newToken(token.LBRACE, l.ch)
-	case 0:
+	switch {
+	case l.ch == 0:
 		tok.Literal = ""
 		tok.Type = token.EOF
+	case isLetter(l.ch):
+		tok.Literal = l.readIdentifier()
+		tok.Type = token.LookupIdent(tok.Literal)
+		tok.Pos = pos
+		return tok
+	case isDigit(l.ch):
+		tok.Type = token.INT
+		tok.Literal = l.readNumber()
+		tok.Pos = pos
+		return tok
+	case l.ch == '"':
+		tok = l.readString()
 	default:
-		if isLetter(l.ch) {
-			tok.Literal = l.readIdentifier()
-			tok.Type = token.LookupIdent(tok.Literal)
-			return tok
-		} else if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
-			return tok
-		} else {
-			tok = newToken(token.ILLEGAL, l.ch)
-		}
+		tok = l.readPunctuation()
 	}
 
 	l.readChar()
+	tok.Pos = pos
 	return tok
 }
 
+// readString returns the string literal starting at l.position, without its
+// surrounding quotes and with its escape sequences replaced. l.ch is left on
+// the closing quote.
+// Returns a token.ILLEGAL token if the string isn't terminated or contains
+// an unknown escape sequence.
+func (l *Lexer) readString() token.Token {
+	start := l.position
+	var out strings.Builder
+	for {
+		l.readChar()
+		switch l.ch {
+		case '"':
+			return token.Token{Type: token.STRING, Literal: out.String()}
+		case 0:
+			return token.Token{
+				Type:    token.ILLEGAL,
+				Literal: l.input[start:l.position],
+			}
+		case '\\':
+			l.readChar()
+			if l.ch == 0 {
+				return token.Token{
+					Type:    token.ILLEGAL,
+					Literal: l.input[start:l.position],
+				}
+			}
+			ch, ok := escapes[l.ch]
+			if !ok {
+				return token.Token{
+					Type:    token.ILLEGAL,
+					Literal: l.input[start : l.position+1],
+				}
+			}
+			out.WriteByte(ch)
+		default:
+			out.WriteByte(l.ch)
+		}
+	}
+}
+
+// escapes maps the character after a backslash in a string literal to the
+// character the escape sequence stands for.
+var escapes = map[byte]byte{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'"':  '"',
+	'\\': '\\',
+}
+
+// readPunctuation returns the operator or delimiter starting at l.position.
+// The longest literal in token.Punctuation which matches wins, so "==" is
+// read as one token rather than two "=" tokens. l.ch is left on the last
+// char of the token.
+// Returns a token.ILLEGAL token if no operator or delimiter matches.
+func (l *Lexer) readPunctuation() token.Token {
+	for n := token.MaxPunctuationLength; n > 0; n-- {
+		if l.position+n > len(l.input) {
+			continue
+		}
+		literal := l.input[l.position : l.position+n]
+		if tokenType, ok := token.LookupPunctuation(literal); ok {
+			for i := 1; i < n; i++ {
+				l.readChar()
+			}
+			return token.Token{Type: tokenType, Literal: literal}
+		}
+	}
+	return newToken(token.ILLEGAL, l.ch)
+}
+
 // isDigit returns a bool indicating whether "byte" is a digit or not.
 func isDigit(ch byte) bool {
 	return '0' <= ch && ch <= '9'
@@ -110,6 +149,11 @@ func (l *Lexer) peekChar() byte {
 
 // readChar assignes the char at l.readPosition to l.ch. Increments l.position.
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+	l.column++
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -26,6 +26,16 @@ if (5 < 10) {
 
 10 == 10;
 10 != 9;
+10 <= 9 >= 8;
+a && b || c;
+10 % 3 ** 2;
+1 & 2 | 3 ^ 4 << 5 >> 6;
+x = 1; x += 2; x -= 3; x *= 4; x /= 5;
+while for in break continue
+"foobar"
+"foo bar"
+[1, 2];
+{"foo": "bar"}
 `
 
 	tests := []struct {
@@ -105,6 +115,74 @@ if (5 < 10) {
 		{token.NOT_EQ, "!="},
 		{token.INT, "9"},
 		{token.SEMICOLON, ";"},
+		{token.INT, "10"},
+		{token.LT_EQ, "<="},
+		{token.INT, "9"},
+		{token.GT_EQ, ">="},
+		{token.INT, "8"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.AND, "&&"},
+		{token.IDENT, "b"},
+		{token.OR, "||"},
+		{token.IDENT, "c"},
+		{token.SEMICOLON, ";"},
+		{token.INT, "10"},
+		{token.PERCENT, "%"},
+		{token.INT, "3"},
+		{token.POWER, "**"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.INT, "1"},
+		{token.BIT_AND, "&"},
+		{token.INT, "2"},
+		{token.BIT_OR, "|"},
+		{token.INT, "3"},
+		{token.BIT_XOR, "^"},
+		{token.INT, "4"},
+		{token.SHIFT_LEFT, "<<"},
+		{token.INT, "5"},
+		{token.SHIFT_RIGHT, ">>"},
+		{token.INT, "6"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.ASSIGN, "="},
+		{token.INT, "1"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.PLUS_ASSIGN, "+="},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.MINUS_ASSIGN, "-="},
+		{token.INT, "3"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.ASTERISK_ASSIGN, "*="},
+		{token.INT, "4"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.SLASH_ASSIGN, "/="},
+		{token.INT, "5"},
+		{token.SEMICOLON, ";"},
+		{token.WHILE, "while"},
+		{token.FOR, "for"},
+		{token.IN, "in"},
+		{token.BREAK, "break"},
+		{token.CONTINUE, "continue"},
+		{token.STRING, "foobar"},
+		{token.STRING, "foo bar"},
+		{token.LBRACKET, "["},
+		{token.INT, "1"},
+		{token.COMMA, ","},
+		{token.INT, "2"},
+		{token.RBRACKET, "]"},
+		{token.SEMICOLON, ";"},
+		{token.LBRACE, "{"},
+		{token.STRING, "foo"},
+		{token.COLON, ":"},
+		{token.STRING, "bar"},
+		{token.RBRACE, "}"},
 		{token.EOF, ""},
 	}
 
@@ -125,3 +203,128 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestNextTokenPunctuation(t *testing.T) {
+	for literal, expectedType := range token.Punctuation {
+		l := New(literal)
+		tok := l.NextToken()
+		if tok.Type != expectedType {
+			t.Errorf("%q - tokentype wrong. expected %q, got %q", literal,
+				expectedType, tok.Type)
+		}
+		if tok.Literal != literal {
+			t.Errorf("%q - literal wrong. expected %q, got %q", literal,
+				literal, tok.Literal)
+		}
+		if tok := l.NextToken(); tok.Type != token.EOF {
+			t.Errorf("%q - expected a single token. got extra %q", literal,
+				tok.Literal)
+		}
+	}
+}
+
+func TestNextTokenLongestMatch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.TokenType
+	}{
+		{"{}", []token.TokenType{token.LBRACE, token.RBRACE}},
+		{"}{", []token.TokenType{token.RBRACE, token.LBRACE}},
+		{"===", []token.TokenType{token.EQ, token.ASSIGN}},
+		{"<<=", []token.TokenType{token.SHIFT_LEFT, token.ASSIGN}},
+		{"***", []token.TokenType{token.POWER, token.ASTERISK}},
+		{"!!=", []token.TokenType{token.BANG, token.NOT_EQ}},
+		{"&&&", []token.TokenType{token.AND, token.BIT_AND}},
+		{"a@b", []token.TokenType{token.IDENT, token.ILLEGAL, token.IDENT}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for i, expectedType := range tt.expected {
+			tok := l.NextToken()
+			if tok.Type != expectedType {
+				t.Errorf("%q[%d] - tokentype wrong. expected %q, got %q",
+					tt.input, i, expectedType, tok.Type)
+			}
+		}
+		if tok := l.NextToken(); tok.Type != token.EOF {
+			t.Errorf("%q - expected EOF. got %q", tt.input, tok.Type)
+		}
+	}
+}
+
+func TestNextTokenUnterminatedString(t *testing.T) {
+	l := New(`"foo`)
+	tok := l.NextToken()
+	if tok.Type != token.ILLEGAL {
+		t.Fatalf("tokentype wrong. expected %q, got %q", token.ILLEGAL,
+			tok.Type)
+	}
+	if tok.Literal != `"foo` {
+		t.Fatalf("literal wrong. expected %q, got %q", `"foo`, tok.Literal)
+	}
+	if tok := l.NextToken(); tok.Type != token.EOF {
+		t.Fatalf("expected EOF. got %q", tok.Type)
+	}
+}
+
+func TestNextTokenStringEscapes(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{`"a\nb"`, token.STRING, "a\nb"},
+		{`"\t\r"`, token.STRING, "\t\r"},
+		{`"say \"hi\""`, token.STRING, `say "hi"`},
+		{`"back\\slash"`, token.STRING, `back\slash`},
+		{`"héllo"`, token.STRING, "héllo"},
+		{`"bad \q"`, token.ILLEGAL, `"bad \q`},
+		{`"end\`, token.ILLEGAL, `"end\`},
+	}
+
+	for _, tt := range tests {
+		tok := New(tt.input).NextToken()
+		if tok.Type != tt.expectedType {
+			t.Errorf("%s - tokentype wrong. expected %q, got %q", tt.input,
+				tt.expectedType, tok.Type)
+		}
+		if tok.Literal != tt.expectedLiteral {
+			t.Errorf("%s - literal wrong. expected %q, got %q", tt.input,
+				tt.expectedLiteral, tok.Literal)
+		}
+	}
+}
+
+func TestNextTokenPositions(t *testing.T) {
+	input := `let x = 5;
+  "a b" <= x
+`
+	tests := []struct {
+		expectedLiteral string
+		expectedPos     token.Position
+	}{
+		{"let", token.Position{Line: 1, Column: 1}},
+		{"x", token.Position{Line: 1, Column: 5}},
+		{"=", token.Position{Line: 1, Column: 7}},
+		{"5", token.Position{Line: 1, Column: 9}},
+		{";", token.Position{Line: 1, Column: 10}},
+		{"a b", token.Position{Line: 2, Column: 3}},
+		{"<=", token.Position{Line: 2, Column: 9}},
+		{"x", token.Position{Line: 2, Column: 12}},
+		{"", token.Position{Line: 3, Column: 1}},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected %q, got %q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+		if tok.Pos != tt.expectedPos {
+			t.Errorf("tests[%d] - position of %q wrong. expected %s, got %s",
+				i, tok.Literal, tt.expectedPos, tok.Pos)
+		}
+	}
+}
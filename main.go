@@ -1,12 +1,19 @@
 package main
 
 import (
+	"context"
 	"fmt"
-	"github.com/jamesroutley/monkey/repl"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/user"
+	"path/filepath"
+
+	"github.com/jamesroutley/monkey/evaluator"
+	"github.com/jamesroutley/monkey/lexer"
+	"github.com/jamesroutley/monkey/object"
+	"github.com/jamesroutley/monkey/parser"
+	"github.com/jamesroutley/monkey/repl"
 )
 
 func init() {
@@ -15,6 +22,10 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runScript(os.Args[1]))
+	}
+
 	user, err := user.Current()
 	if err != nil {
 		panic(err)
@@ -22,5 +33,47 @@ func main() {
 	fmt.Printf("Hello %s! This is the Monkey programming language!\n",
 		user.Username)
 	fmt.Printf("Feel free to type in commands.\n")
-	repl.Start(os.Stdin, os.Stdout)
+	opts := evaluator.Options{
+		Modules: evaluator.NewModuleLoader("", evaluator.ModulePathFromEnv()),
+		System:  &evaluator.System{},
+	}
+	repl.Start(os.Stdin, os.Stdout, opts)
+}
+
+// runScript runs the Monkey program in filename, and returns the exit status.
+func runScript(filename string) int {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if errs := p.ParseErrors(); len(errs) != 0 {
+		for _, err := range errs {
+			fmt.Fprintf(os.Stderr, "%s:%s\n", filename, err)
+		}
+		return 1
+	}
+
+	opts := evaluator.Options{
+		Modules: evaluator.NewModuleLoader(filepath.Dir(filename),
+			evaluator.ModulePathFromEnv()),
+		// The script's arguments start with its own filename.
+		System: &evaluator.System{Args: os.Args[1:]},
+	}
+	e := evaluator.New(context.Background(), opts)
+	program, errObj := e.ExpandMacros(program, object.NewEnvironment())
+	if errObj != nil {
+		fmt.Fprintf(os.Stderr, "%s:%s: %s\n", filename, errObj.Pos,
+			errObj.Message)
+		return 1
+	}
+	result := e.Eval(program, object.NewEnvironment())
+	if err, ok := result.(*object.Error); ok {
+		fmt.Fprintf(os.Stderr, "%s:%s: %s\n", filename, err.Pos, err.Message)
+		return 1
+	}
+	return 0
 }
@@ -0,0 +1,488 @@
+package monkey
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/jamesroutley/monkey/evaluator"
+	"github.com/jamesroutley/monkey/object"
+)
+
+var (
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// ToObject converts a Go value to a Monkey object:
+//
+//   - nil becomes null
+//   - bools become booleans
+//   - integers of any size become integers
+//   - strings become strings
+//   - slices and arrays become arrays
+//   - maps become hashes, with their keys in sorted order; the keys must
+//     convert to integers, booleans or strings
+//   - functions become builtins. Arguments are converted to the function's
+//     parameter types, and its result is converted back with ToObject. If the
+//     function's last result is a non-nil error, calling it fails. A Monkey
+//     function passed as an argument of function type is converted to a Go
+//     function of that type. If the Monkey function fails, the Go function
+//     returns the failure as its last result, if that's an error, and
+//     otherwise panics with a *CallbackError
+//   - structs and pointers to structs become GoValues
+//   - other pointers are followed, and the values they point to converted
+//   - object.Objects are used as they are
+func (i *Interpreter) ToObject(value interface{}) (object.Object, error) {
+	return i.toObject("function", reflect.ValueOf(value))
+}
+
+// toObject converts v to a Monkey object. Functions are given name, which
+// is used in error messages.
+func (i *Interpreter) toObject(name string, v reflect.Value) (object.Object, error) {
+	if !v.IsValid() {
+		return evaluator.NULL, nil
+	}
+	if !v.CanInterface() {
+		return nil, fmt.Errorf("cannot convert unexported value of type %s",
+			v.Type())
+	}
+	if obj, ok := v.Interface().(object.Object); ok {
+		return obj, nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return evaluator.TRUE, nil
+		}
+		return evaluator.FALSE, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		u := v.Uint()
+		if u > 1<<63-1 {
+			return nil, fmt.Errorf("%d overflows INTEGER", u)
+		}
+		return &object.Integer{Value: int64(u)}, nil
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		elements := make([]object.Object, v.Len())
+		for j := range elements {
+			el, err := i.toObject(name, v.Index(j))
+			if err != nil {
+				return nil, err
+			}
+			elements[j] = el
+		}
+		return &object.Array{Elements: elements}, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		// Go maps are iterated in a random order, so the keys are sorted to
+		// give the hash a deterministic one.
+		keys := make([]object.Hashable, 0, v.Len())
+		values := map[object.HashKey]reflect.Value{}
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := i.toObject(name, iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			hashKey, ok := key.(object.Hashable)
+			if !ok {
+				return nil, fmt.Errorf("unusable as hash key: %s",
+					key.Type())
+			}
+			keys = append(keys, hashKey)
+			values[hashKey.HashKey()] = iter.Value()
+		}
+		sort.Slice(keys, func(a, b int) bool {
+			return lessHashKey(keys[a].HashKey(), keys[b].HashKey())
+		})
+		hash := object.NewHash()
+		for _, key := range keys {
+			value, err := i.toObject(name, values[key.HashKey()])
+			if err != nil {
+				return nil, err
+			}
+			hash.Set(key, value)
+		}
+		return hash, nil
+	case reflect.Func:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return i.goFuncToBuiltin(name, v), nil
+	case reflect.Struct:
+		return &GoValue{interp: i, value: v}, nil
+	case reflect.Ptr:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		if v.Elem().Kind() == reflect.Struct {
+			return &GoValue{interp: i, value: v}, nil
+		}
+		return i.toObject(name, v.Elem())
+	case reflect.Interface:
+		if v.IsNil() {
+			return evaluator.NULL, nil
+		}
+		return i.toObject(name, v.Elem())
+	}
+	return nil, fmt.Errorf("cannot convert Go value of type %s", v.Type())
+}
+
+// goFuncToBuiltin wraps the Go function fn in a builtin.
+func (i *Interpreter) goFuncToBuiltin(name string, fn reflect.Value) *object.Builtin {
+	t := fn.Type()
+	call := func(args ...object.Object) (result object.Object) {
+		numIn := t.NumIn()
+		if t.IsVariadic() {
+			if len(args) < numIn-1 {
+				return newError("wrong number of arguments to `%s`: "+
+					"want at least %d, got %d", name, numIn-1, len(args))
+			}
+		} else if len(args) != numIn {
+			return newError("wrong number of arguments to `%s`: "+
+				"want %d, got %d", name, numIn, len(args))
+		}
+
+		in := make([]reflect.Value, len(args))
+		for j, arg := range args {
+			var paramType reflect.Type
+			if t.IsVariadic() && j >= numIn-1 {
+				paramType = t.In(numIn - 1).Elem()
+			} else {
+				paramType = t.In(j)
+			}
+			v, err := i.fromObjectTo(arg, paramType)
+			if err != nil {
+				return newError("argument %d to `%s`: %s", j+1, name, err)
+			}
+			in[j] = v
+		}
+
+		// A Monkey function passed in as a callback may fail. The callback
+		// panics with the failure if it has no error result to return it
+		// in, so recover it here.
+		defer func() {
+			if r := recover(); r != nil {
+				err, ok := r.(*CallbackError)
+				if !ok {
+					panic(r)
+				}
+				result = &object.Error{
+					Message: err.Err.Message,
+					Pos:     err.Err.Pos,
+				}
+			}
+		}()
+
+		out := fn.Call(in)
+		if len(out) > 0 && t.Out(len(out)-1) == errorType {
+			if err := out[len(out)-1]; !err.IsNil() {
+				return newError("%s", err.Interface().(error))
+			}
+			out = out[:len(out)-1]
+		}
+		if len(out) == 0 {
+			return evaluator.NULL
+		}
+		obj, err := i.toObject(name, out[0])
+		if err != nil {
+			return newError("result of `%s`: %s", name, err)
+		}
+		return obj
+	}
+	return &object.Builtin{Name: name, Fn: call}
+}
+
+// FromObject converts a Monkey object to a Go value:
+//
+//   - null becomes nil
+//   - integers become int64s
+//   - booleans become bools
+//   - strings become strings
+//   - arrays and tuples become []interface{}
+//   - hashes become map[string]interface{} if all their keys are strings,
+//     and map[interface{}]interface{} otherwise
+//   - functions and builtins become
+//     func(args ...interface{}) (interface{}, error)
+//   - GoValues become the Go values they wrap
+//
+// Other objects are returned as they are.
+func (i *Interpreter) FromObject(obj object.Object) interface{} {
+	switch obj := obj.(type) {
+	case nil, *object.Null:
+		return nil
+	case *object.Integer:
+		return obj.Value
+	case *object.Boolean:
+		return obj.Value
+	case *object.String:
+		return obj.Value
+	case *object.Array:
+		return i.sliceFromObjects(obj.Elements)
+	case *object.Tuple:
+		return i.sliceFromObjects(obj.Elements)
+	case *object.Hash:
+		return i.hashFromObject(obj)
+	case *object.Function, *object.Builtin:
+		return func(args ...interface{}) (interface{}, error) {
+			return i.call(obj, args)
+		}
+	case *GoValue:
+		return obj.Value()
+	default:
+		return obj
+	}
+}
+
+func (i *Interpreter) sliceFromObjects(objs []object.Object) []interface{} {
+	elements := make([]interface{}, len(objs))
+	for j, el := range objs {
+		elements[j] = i.FromObject(el)
+	}
+	return elements
+}
+
+func (i *Interpreter) hashFromObject(hash *object.Hash) interface{} {
+	stringKeys := true
+	for _, key := range hash.Keys {
+		if key.Type != object.STRING_OBJ {
+			stringKeys = false
+			break
+		}
+	}
+	if stringKeys {
+		m := make(map[string]interface{}, len(hash.Keys))
+		for _, pair := range hash.OrderedPairs() {
+			m[pair.Key.(*object.String).Value] = i.FromObject(pair.Value)
+		}
+		return m
+	}
+	m := make(map[interface{}]interface{}, len(hash.Keys))
+	for _, pair := range hash.OrderedPairs() {
+		m[i.FromObject(pair.Key)] = i.FromObject(pair.Value)
+	}
+	return m
+}
+
+// call calls the Monkey function fn with args, converting them with
+// ToObject, and converts its result with FromObject.
+func (i *Interpreter) call(fn object.Object, args []interface{}) (interface{}, error) {
+	objs := make([]object.Object, len(args))
+	for j, arg := range args {
+		obj, err := i.ToObject(arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %v", j+1, err)
+		}
+		objs[j] = obj
+	}
+	result := i.evaluator().Apply(fn, objs)
+	if err, ok := result.(*object.Error); ok {
+		return nil, &RuntimeError{Pos: err.Pos, Message: err.Message}
+	}
+	return i.FromObject(result), nil
+}
+
+// fromObjectTo converts obj to a Go value of type t.
+func (i *Interpreter) fromObjectTo(obj object.Object, t reflect.Type) (reflect.Value, error) {
+	mismatch := func() (reflect.Value, error) {
+		if gv, ok := obj.(*GoValue); ok {
+			return reflect.Value{}, fmt.Errorf("cannot use %s as %s",
+				gv.value.Type(), t)
+		}
+		return reflect.Value{}, fmt.Errorf("cannot use %s as %s",
+			object.TypeName(obj), t)
+	}
+
+	if reflect.TypeOf(obj).AssignableTo(t) {
+		return reflect.ValueOf(obj), nil
+	}
+	if gv, ok := obj.(*GoValue); ok {
+		v := gv.value
+		if v.Type().AssignableTo(t) {
+			return v, nil
+		}
+		// Dereference pointers passed to functions which take the value.
+		if v.Kind() == reflect.Ptr && !v.IsNil() &&
+			v.Elem().Type().AssignableTo(t) {
+			return v.Elem(), nil
+		}
+		return mismatch()
+	}
+	if t.Kind() == reflect.Interface && t != objectType {
+		value := i.FromObject(obj)
+		if value == nil {
+			return reflect.Zero(t), nil
+		}
+		v := reflect.ValueOf(value)
+		if !v.Type().AssignableTo(t) {
+			return mismatch()
+		}
+		return v, nil
+	}
+
+	v := reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.Bool:
+		b, ok := obj.(*object.Boolean)
+		if !ok {
+			return mismatch()
+		}
+		v.SetBool(b.Value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		integer, ok := obj.(*object.Integer)
+		if !ok {
+			return mismatch()
+		}
+		if v.OverflowInt(integer.Value) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s",
+				integer.Value, t)
+		}
+		v.SetInt(integer.Value)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		integer, ok := obj.(*object.Integer)
+		if !ok {
+			return mismatch()
+		}
+		if integer.Value < 0 || v.OverflowUint(uint64(integer.Value)) {
+			return reflect.Value{}, fmt.Errorf("%d overflows %s",
+				integer.Value, t)
+		}
+		v.SetUint(uint64(integer.Value))
+	case reflect.Float32, reflect.Float64:
+		integer, ok := obj.(*object.Integer)
+		if !ok {
+			return mismatch()
+		}
+		v.SetFloat(float64(integer.Value))
+	case reflect.String:
+		s, ok := obj.(*object.String)
+		if !ok {
+			return mismatch()
+		}
+		v.SetString(s.Value)
+	case reflect.Slice:
+		array, ok := obj.(*object.Array)
+		if !ok {
+			return mismatch()
+		}
+		v.Set(reflect.MakeSlice(t, len(array.Elements), len(array.Elements)))
+		for j, el := range array.Elements {
+			elem, err := i.fromObjectTo(el, t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("element %d: %v", j, err)
+			}
+			v.Index(j).Set(elem)
+		}
+	case reflect.Map:
+		hash, ok := obj.(*object.Hash)
+		if !ok {
+			return mismatch()
+		}
+		v.Set(reflect.MakeMapWithSize(t, len(hash.Keys)))
+		for _, pair := range hash.OrderedPairs() {
+			key, err := i.fromObjectTo(pair.Key, t.Key())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("key %s: %v",
+					pair.Key.Inspect(), err)
+			}
+			value, err := i.fromObjectTo(pair.Value, t.Elem())
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("value of %s: %v",
+					pair.Key.Inspect(), err)
+			}
+			v.SetMapIndex(key, value)
+		}
+	case reflect.Func:
+		switch obj.(type) {
+		case *object.Function, *object.Builtin:
+			return i.monkeyFuncToGo(obj, t), nil
+		}
+		return mismatch()
+	default:
+		return mismatch()
+	}
+	return v, nil
+}
+
+// CallbackError is what a Go function made from a Monkey function panics
+// with if the Monkey function fails, and the Go function has no error result
+// to return the failure in. The panic is recovered if the Go function is
+// called by a Go function which the program called, and the program fails
+// with Err. A host which stores the Go function and calls it later must
+// recover the panic itself, or use a function type with an error result.
+type CallbackError struct {
+	Err *RuntimeError
+}
+
+func (e *CallbackError) Error() string {
+	return e.Err.Error()
+}
+
+// monkeyFuncToGo wraps the Monkey function fn in a Go function of type t.
+func (i *Interpreter) monkeyFuncToGo(fn object.Object, t reflect.Type) reflect.Value {
+	return reflect.MakeFunc(t, func(in []reflect.Value) []reflect.Value {
+		out := make([]reflect.Value, t.NumOut())
+		for j := range out {
+			out[j] = reflect.Zero(t.Out(j))
+		}
+		returnsError := t.NumOut() > 0 && t.Out(t.NumOut()-1) == errorType
+		fail := func(err *object.Error) []reflect.Value {
+			runtimeErr := &RuntimeError{Pos: err.Pos, Message: err.Message}
+			if !returnsError {
+				panic(&CallbackError{Err: runtimeErr})
+			}
+			out[len(out)-1] = reflect.ValueOf(runtimeErr)
+			return out
+		}
+
+		args := make([]object.Object, len(in))
+		for j, arg := range in {
+			obj, err := i.toObject("function", arg)
+			if err != nil {
+				return fail(newError("argument %d: %s", j+1, err))
+			}
+			args[j] = obj
+		}
+		result := i.evaluator().Apply(fn, args)
+		if err, ok := result.(*object.Error); ok {
+			return fail(err)
+		}
+		if len(out) > 0 && !(len(out) == 1 && returnsError) {
+			v, err := i.fromObjectTo(result, t.Out(0))
+			if err != nil {
+				return fail(newError("result: %s", err))
+			}
+			out[0] = v
+		}
+		return out
+	})
+}
+
+func newError(format string, a ...interface{}) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...)}
+}
+
+// lessHashKey orders hash keys by type, then value.
+func lessHashKey(a, b object.HashKey) bool {
+	if a.Type != b.Type {
+		return a.Type < b.Type
+	}
+	if a.Value != b.Value {
+		return a.Value < b.Value
+	}
+	return a.Text < b.Text
+}
@@ -0,0 +1,92 @@
+package monkey
+
+import (
+	"fmt"
+	"go/token"
+	"reflect"
+
+	"github.com/jamesroutley/monkey/object"
+)
+
+// GO_VALUE_OBJ is the type of GoValues.
+const GO_VALUE_OBJ = "GO_VALUE"
+
+// GoValue is a Go struct, or pointer to one, passed into a Monkey program.
+// Its exported fields and methods are accessed with the . operator, e.g.
+// user.Name or user.Greet("hi"). Methods are converted to builtins in the
+// same way as functions passed to RegisterFunc.
+type GoValue struct {
+	interp *Interpreter
+	value  reflect.Value
+}
+
+// Value returns the wrapped Go value.
+func (gv *GoValue) Value() interface{} {
+	return gv.value.Interface()
+}
+
+func (gv *GoValue) Inspect() string {
+	return fmt.Sprintf("%v", gv.value.Interface())
+}
+func (gv *GoValue) Type() object.ObjectType {
+	return GO_VALUE_OBJ
+}
+
+// Member returns the exported field or method called name.
+func (gv *GoValue) Member(name string) (object.Object, error) {
+	typeName := goTypeName(gv.value.Type())
+
+	// Look methods up on the value as it was passed in, so a pointer has
+	// both its pointer and value receiver methods.
+	if method := gv.value.MethodByName(name); method.IsValid() {
+		return gv.interp.goFuncToBuiltin(typeName+"."+name, method), nil
+	}
+
+	v := gv.value
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, fmt.Errorf("cannot access %s of nil %s", name,
+				v.Type())
+		}
+		v = v.Elem()
+	}
+	field, ok := v.Type().FieldByName(name)
+	if !ok {
+		// reflect doesn't list unexported methods, which have a PkgPath,
+		// but they can't be called from outside their package anyway.
+		if !token.IsExported(name) {
+			return nil, fmt.Errorf(
+				"cannot access unexported field or method %s of %s", name,
+				typeName)
+		}
+		return nil, fmt.Errorf("%s has no field or method %s", typeName,
+			name)
+	}
+	if field.PkgPath != "" {
+		return nil, fmt.Errorf("cannot access unexported field %s of %s",
+			name, typeName)
+	}
+	// A promoted field can't be reached through a nil embedded pointer.
+	fv, err := v.FieldByIndexErr(field.Index)
+	if err != nil {
+		return nil, fmt.Errorf("cannot access %s of %s: it's promoted "+
+			"through a nil embedded pointer", name, typeName)
+	}
+	obj, err := gv.interp.toObject(typeName+"."+name, fv)
+	if err != nil {
+		return nil, fmt.Errorf("field %s of %s: %v", name, typeName, err)
+	}
+	return obj, nil
+}
+
+// goTypeName returns the name of t, without any pointer indirection, for
+// use in error messages.
+func goTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Name() != "" {
+		return t.Name()
+	}
+	return t.String()
+}
@@ -0,0 +1,172 @@
+package monkey
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/jamesroutley/monkey/evaluator"
+)
+
+type testUser struct {
+	Name    string
+	Age     int
+	Tags    []string
+	Address *testAddress
+	secret  string
+}
+
+func (u testUser) Greet(greeting string) string {
+	return greeting + ", " + u.Name
+}
+
+func (u *testUser) Birthday() int {
+	u.Age++
+	return u.Age
+}
+
+func (u testUser) whisper() string {
+	return u.secret
+}
+
+func (u *testUser) Fail() error {
+	return fmt.Errorf("%s can't do that", u.Name)
+}
+
+type testAddress struct {
+	City string
+}
+
+type testAdmin struct {
+	*testUser
+	Level int
+}
+
+func TestGoValueMembers(t *testing.T) {
+	user := &testUser{
+		Name:    "Ada",
+		Age:     36,
+		Tags:    []string{"a", "b"},
+		Address: &testAddress{City: "London"},
+		secret:  "hidden",
+	}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"user.Name", "Ada"},
+		{"user.Age + 1", int64(37)},
+		{"len(user.Tags)", int64(2)},
+		{"user.Address.City", "London"},
+		{`user.Greet("Hello")`, "Hello, Ada"},
+		{"let greet = user.Greet; greet(\"Hi\")", "Hi, Ada"},
+		{"user.Birthday(); user.Birthday()", int64(38)},
+		{"user.Fail", nil},
+		{"user", user},
+		{"same(user)", true},
+	}
+
+	for _, tt := range tests {
+		interp := New(evaluator.Options{})
+		interp.Set("user", user)
+		interp.RegisterFunc("same", func(u *testUser) bool { return u == user })
+		result, err := run(interp, tt.input)
+		if err != nil {
+			t.Errorf("%q - unexpected error: %v", tt.input, err)
+			continue
+		}
+		if tt.expected == nil {
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("%q - expected %#v, got %#v", tt.input, tt.expected,
+				result)
+		}
+	}
+
+	if user.Age != 38 {
+		t.Errorf("methods didn't modify user. Age is %d", user.Age)
+	}
+}
+
+func TestGoValueStructByValue(t *testing.T) {
+	interp := New(evaluator.Options{})
+	interp.Set("user", testUser{Name: "Bob"})
+	interp.RegisterFunc("name", func(u testUser) string { return u.Name })
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`user.Greet("Hey")`, "Hey, Bob"},
+		{"name(user)", "Bob"},
+	}
+	for _, tt := range tests {
+		result, err := run(interp, tt.input)
+		if err != nil {
+			t.Errorf("%q - unexpected error: %v", tt.input, err)
+			continue
+		}
+		if result != tt.expected {
+			t.Errorf("%q - expected %#v, got %#v", tt.input, tt.expected,
+				result)
+		}
+	}
+}
+
+func TestGoValueErrors(t *testing.T) {
+	interp := New(evaluator.Options{})
+	interp.Set("user", &testUser{Name: "Ada"})
+	interp.Set("value", testUser{Name: "Bob"})
+	interp.Set("admin", &testAdmin{Level: 1})
+	interp.RegisterFunc("age", func(age int) int { return age })
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"user.secret", "1:5: cannot access unexported field secret of testUser"},
+		{"user.whisper", "1:5: cannot access unexported field or method whisper of testUser"},
+		{"user.missing", "1:5: cannot access unexported field or method missing of testUser"},
+		{"user.Missing", "1:5: testUser has no field or method Missing"},
+		{"admin.Level + admin.Name",
+			"1:20: cannot access Name of testAdmin: it's promoted through a nil embedded pointer"},
+		{"user.Address.City", "1:13: member access not supported: NULL.City"},
+		{"user.Greet(1)",
+			"1:11: argument 1 to `testUser.Greet`: cannot use INTEGER as string"},
+		{"user.Greet()",
+			"1:11: wrong number of arguments to `testUser.Greet`: want 1, got 0"},
+		{"user.Fail()", "1:10: Ada can't do that"},
+		{"age(user)", "1:4: argument 1 to `age`: cannot use *monkey.testUser as int"},
+		// Pointer methods aren't available on values passed by value.
+		{"value.Birthday", "1:6: testUser has no field or method Birthday"},
+	}
+
+	for _, tt := range tests {
+		_, err := run(interp, tt.input)
+		if err == nil {
+			t.Errorf("%q - expected an error", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("%q - wrong error. expected %q, got %q", tt.input,
+				tt.expected, err.Error())
+		}
+	}
+}
+
+func TestGoValueInspect(t *testing.T) {
+	interp := New(evaluator.Options{})
+	obj, err := interp.ToObject(testAddress{City: "Paris"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if obj.Type() != GO_VALUE_OBJ {
+		t.Errorf("wrong type. expected %s, got %s", GO_VALUE_OBJ, obj.Type())
+	}
+	if obj.Inspect() != "{Paris}" {
+		t.Errorf("wrong Inspect. expected %q, got %q", "{Paris}",
+			obj.Inspect())
+	}
+}
@@ -0,0 +1,153 @@
+// Package monkey embeds the Monkey interpreter in Go programs.
+//
+// An Interpreter holds a set of global bindings. Go values and functions are
+// exposed to Monkey programs with Set and RegisterFunc, programs are compiled
+// with Compile and run with Run, and results are read back with Get or from
+// Run's return value. Values are converted between Go and Monkey
+// automatically, see ToObject for the rules.
+package monkey
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jamesroutley/monkey/ast"
+	"github.com/jamesroutley/monkey/evaluator"
+	"github.com/jamesroutley/monkey/lexer"
+	"github.com/jamesroutley/monkey/object"
+	"github.com/jamesroutley/monkey/parser"
+	"github.com/jamesroutley/monkey/token"
+)
+
+// Interpreter runs Monkey programs against a shared set of global bindings.
+// An Interpreter must not be used from multiple goroutines at once.
+type Interpreter struct {
+	opts   evaluator.Options
+	env    *object.Environment
+	macros *object.Environment
+
+	// running is the evaluator of the program currently being run, if any.
+	// Go functions called by the program use it to call back into Monkey
+	// functions, so the calls count towards the program's limits.
+	running *evaluator.Evaluator
+}
+
+// New initialises and returns an Interpreter with no global bindings.
+// Each call to Run is limited by opts.
+func New(opts evaluator.Options) *Interpreter {
+	return &Interpreter{
+		opts:   opts,
+		env:    object.NewEnvironment(),
+		macros: object.NewEnvironment(),
+	}
+}
+
+// Program is a compiled Monkey program. It can be run any number of times,
+// by any Interpreter.
+type Program struct {
+	program *ast.Program
+}
+
+// String returns the program's source, as parsed.
+func (p *Program) String() string {
+	return p.program.String()
+}
+
+// SyntaxError is returned by Compile when a program can't be parsed.
+type SyntaxError struct {
+	Errors []*parser.Error
+}
+
+func (e *SyntaxError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return "syntax error: " + strings.Join(msgs, "; ")
+}
+
+// RuntimeError is returned by Run when a program fails.
+type RuntimeError struct {
+	// Pos is the position in the program's source where the error happened.
+	Pos     token.Position
+	Message string
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Pos.String() + ": " + e.Message
+}
+
+// Compile parses src. If src has syntax errors, a *SyntaxError is returned.
+func (i *Interpreter) Compile(src string) (*Program, error) {
+	l := lexer.New(src)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.ParseErrors()) != 0 {
+		return nil, &SyntaxError{Errors: p.ParseErrors()}
+	}
+	return &Program{program: program}, nil
+}
+
+// Run runs prog and returns the value of its last statement, converted to a
+// Go value by FromObject. Global bindings and macros the program creates are
+// kept, so they can be used by later programs, and bindings read with Get.
+//
+// Run stops when ctx is done, or when the program reaches one of the
+// Interpreter's limits. If the program fails, a *RuntimeError is returned.
+func (i *Interpreter) Run(ctx context.Context, prog *Program) (interface{}, error) {
+	e := evaluator.New(ctx, i.opts)
+	previous := i.running
+	i.running = e
+	defer func() { i.running = previous }()
+
+	program, errObj := e.ExpandMacros(prog.program, i.macros)
+	if errObj != nil {
+		return nil, &RuntimeError{Pos: errObj.Pos, Message: errObj.Message}
+	}
+	result := e.Eval(program, i.env)
+	if err, ok := result.(*object.Error); ok {
+		return nil, &RuntimeError{Pos: err.Pos, Message: err.Message}
+	}
+	return i.FromObject(result), nil
+}
+
+// Set binds name to value in the Interpreter's global environment, after
+// converting value with ToObject.
+func (i *Interpreter) Set(name string, value interface{}) error {
+	obj, err := i.toObject(name, reflect.ValueOf(value))
+	if err != nil {
+		return fmt.Errorf("setting %s: %v", name, err)
+	}
+	i.env.Set(name, obj)
+	return nil
+}
+
+// Get returns the value bound to name in the Interpreter's global
+// environment, converted with FromObject.
+func (i *Interpreter) Get(name string) (interface{}, bool) {
+	obj, ok := i.env.Get(name)
+	if !ok {
+		return nil, false
+	}
+	return i.FromObject(obj), true
+}
+
+// RegisterFunc makes the Go function fn callable from Monkey as name. See
+// ToObject for how arguments and results are converted.
+func (i *Interpreter) RegisterFunc(name string, fn interface{}) error {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return fmt.Errorf("registering %s: %T is not a function", name, fn)
+	}
+	return i.Set(name, fn)
+}
+
+// evaluator returns the evaluator to call Monkey functions with from Go.
+func (i *Interpreter) evaluator() *evaluator.Evaluator {
+	if i.running != nil {
+		return i.running
+	}
+	return evaluator.New(context.Background(), i.opts)
+}
@@ -0,0 +1,347 @@
+package monkey
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jamesroutley/monkey/evaluator"
+	"github.com/jamesroutley/monkey/token"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"1 + 2", int64(3)},
+		{"1 < 2", true},
+		{`"a" + "b"`, "ab"},
+		{"if (false) { 1 }", nil},
+		{"[1, true, \"x\"]", []interface{}{int64(1), true, "x"}},
+		{"(1, \"x\")", []interface{}{int64(1), "x"}},
+		{`{"a": 1, "b": [2]}`, map[string]interface{}{
+			"a": int64(1),
+			"b": []interface{}{int64(2)},
+		}},
+		{`{1: "a", true: "b"}`, map[interface{}]interface{}{
+			int64(1): "a",
+			true:     "b",
+		}},
+	}
+
+	for _, tt := range tests {
+		interp := New(evaluator.Options{})
+		result, err := run(interp, tt.input)
+		if err != nil {
+			t.Errorf("%q - unexpected error: %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("%q - expected %#v, got %#v", tt.input, tt.expected,
+				result)
+		}
+	}
+}
+
+func TestProgramsAreReusable(t *testing.T) {
+	interp := New(evaluator.Options{})
+	prog, err := interp.Compile("n = n + 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := interp.Set("n", 0); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := interp.Run(context.Background(), prog); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if n, _ := interp.Get("n"); n != int64(3) {
+		t.Errorf("n has wrong value. expected 3, got %v", n)
+	}
+
+	// A compiled program can be run by another Interpreter.
+	other := New(evaluator.Options{})
+	other.Set("n", 41)
+	result, err := other.Run(context.Background(), prog)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != int64(42) {
+		t.Errorf("wrong result. expected 42, got %v", result)
+	}
+}
+
+func TestMacrosPersist(t *testing.T) {
+	interp := New(evaluator.Options{})
+	_, err := run(interp,
+		"let unless = macro(c, a) { quote(if (!unquote(c)) { unquote(a) }) };")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	result, err := run(interp, `unless(1 > 2, "ok")`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != "ok" {
+		t.Errorf("wrong result. expected %q, got %v", "ok", result)
+	}
+
+	_, err = run(interp, "let m = macro() { 1 };\nm()")
+	var runtimeErr *RuntimeError
+	if !errors.As(err, &runtimeErr) {
+		t.Fatalf("expected a *RuntimeError. got %T (%v)", err, err)
+	}
+	expected := "2:2: macro m must return a quoted AST, got INTEGER"
+	if runtimeErr.Error() != expected {
+		t.Errorf("wrong error. expected %q, got %q", expected,
+			runtimeErr.Error())
+	}
+}
+
+func TestGlobals(t *testing.T) {
+	interp := New(evaluator.Options{})
+	if err := interp.Set("names", []string{"a", "b"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := run(interp, "let count = len(names);"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	count, ok := interp.Get("count")
+	if !ok {
+		t.Fatalf("count is not set")
+	}
+	if count != int64(2) {
+		t.Errorf("count has wrong value. expected 2, got %v", count)
+	}
+	if _, ok := interp.Get("missing"); ok {
+		t.Errorf("expected missing to be unset")
+	}
+
+	if err := interp.Set("ch", make(chan int)); err == nil {
+		t.Errorf("expected an error setting a channel")
+	}
+}
+
+// Go maps are converted to hashes with their keys in a deterministic order.
+func TestMapKeyOrder(t *testing.T) {
+	interp := New(evaluator.Options{})
+	tests := []struct {
+		value    interface{}
+		expected string
+	}{
+		{map[string]int{"d": 4, "b": 2, "a": 1, "e": 5, "c": 3},
+			"{a: 1, b: 2, c: 3, d: 4, e: 5}"},
+		{map[int]bool{3: true, -1: false, 2: true, 10: false},
+			"{-1: false, 2: true, 3: true, 10: false}"},
+		{map[interface{}]int{"a": 1, true: 2, 1: 3, false: 4},
+			"{false: 4, true: 2, 1: 3, a: 1}"},
+	}
+
+	for _, tt := range tests {
+		// Iterating over a map gives a different order each time, so an
+		// unsorted conversion would be unlikely to pass repeatedly.
+		for i := 0; i < 20; i++ {
+			obj, err := interp.ToObject(tt.value)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if obj.Inspect() != tt.expected {
+				t.Fatalf("wrong hash. expected %q, got %q", tt.expected,
+					obj.Inspect())
+			}
+		}
+	}
+}
+
+func TestRegisterFunc(t *testing.T) {
+	interp := New(evaluator.Options{})
+	interp.RegisterFunc("add", func(a, b int64) int64 { return a + b })
+	interp.RegisterFunc("join", func(sep string, parts ...string) string {
+		return strings.Join(parts, sep)
+	})
+	interp.RegisterFunc("keys", func(m map[string]int) []string {
+		keys := []string{}
+		for k := range m {
+			keys = append(keys, k)
+		}
+		return keys
+	})
+	interp.RegisterFunc("fail", func() (int, error) {
+		return 0, errors.New("it failed")
+	})
+	interp.RegisterFunc("apply", func(f func(int) int, x int) int {
+		return f(x)
+	})
+	interp.RegisterFunc("small", func(x int8) int8 { return x })
+	interp.RegisterFunc("nothing", func() {})
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"add(1, 2)", int64(3)},
+		{`join("-", "a", "b", "c")`, "a-b-c"},
+		{`join("-")`, ""},
+		{`keys({"k": 1})`, []interface{}{"k"}},
+		{"apply(fn(x) { x * 2 }, 21)", int64(42)},
+		{"nothing()", nil},
+	}
+
+	for _, tt := range tests {
+		result, err := run(interp, tt.input)
+		if err != nil {
+			t.Errorf("%q - unexpected error: %v", tt.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(result, tt.expected) {
+			t.Errorf("%q - expected %#v, got %#v", tt.input, tt.expected,
+				result)
+		}
+	}
+
+	errorTests := []struct {
+		input    string
+		expected string
+	}{
+		{"add(1)", "1:4: wrong number of arguments to `add`: want 2, got 1"},
+		{"add(1, true)",
+			"1:4: argument 2 to `add`: cannot use BOOLEAN as int64"},
+		{"join()",
+			"1:5: wrong number of arguments to `join`: want at least 1, got 0"},
+		{"fail()", "1:5: it failed"},
+		{"small(1000)", "1:6: argument 1 to `small`: 1000 overflows int8"},
+		{"apply(fn(x) { x / 0 }, 1)", "1:17: division by zero: 1 / 0"},
+	}
+
+	for _, tt := range errorTests {
+		_, err := run(interp, tt.input)
+		if err == nil {
+			t.Errorf("%q - expected an error", tt.input)
+			continue
+		}
+		if err.Error() != tt.expected {
+			t.Errorf("%q - wrong error. expected %q, got %q", tt.input,
+				tt.expected, err.Error())
+		}
+	}
+
+	if err := interp.RegisterFunc("x", 5); err == nil {
+		t.Errorf("expected an error registering a non-function")
+	}
+}
+
+func TestMonkeyFunctionsFromGo(t *testing.T) {
+	interp := New(evaluator.Options{})
+	if _, err := run(interp, "let double = fn(x) { x * 2 };"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	double, _ := interp.Get("double")
+	fn, ok := double.(func(args ...interface{}) (interface{}, error))
+	if !ok {
+		t.Fatalf("double is not a function. got %T", double)
+	}
+	result, err := fn(21)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result != int64(42) {
+		t.Errorf("wrong result. expected 42, got %v", result)
+	}
+	if _, err := fn(true); err == nil {
+		t.Errorf("expected an error doubling a boolean")
+	}
+}
+
+// A Go function made from a Monkey function can be stored and called after
+// the program has finished.
+func TestStoredCallbacks(t *testing.T) {
+	interp := New(evaluator.Options{})
+	var apply func(int) int
+	var tryApply func(int) (int, error)
+	interp.RegisterFunc("store", func(f func(int) int, g func(int) (int, error)) {
+		apply, tryApply = f, g
+	})
+	if _, err := run(interp, "let f = fn(x) { 10 / x }; store(f, f);"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := apply(2); got != 5 {
+		t.Errorf("wrong result. expected 5, got %d", got)
+	}
+	if _, err := tryApply(0); err == nil ||
+		err.Error() != "1:20: division by zero: 10 / 0" {
+		t.Errorf("wrong error. got %v", err)
+	}
+
+	defer func() {
+		err, ok := recover().(*CallbackError)
+		if !ok {
+			t.Fatalf("expected a *CallbackError panic. got %v", err)
+		}
+		if err.Error() != "1:20: division by zero: 10 / 0" {
+			t.Errorf("wrong error. got %q", err.Error())
+		}
+	}()
+	apply(0)
+	t.Errorf("expected apply(0) to panic")
+}
+
+func TestErrors(t *testing.T) {
+	interp := New(evaluator.Options{})
+
+	_, err := interp.Compile("let x = 1;\nlet = 2;")
+	syntaxErr, ok := err.(*SyntaxError)
+	if !ok {
+		t.Fatalf("error is not *SyntaxError. got %T (%v)", err, err)
+	}
+	if len(syntaxErr.Errors) == 0 {
+		t.Fatalf("expected syntax errors")
+	}
+	expectedPos := token.Position{Line: 2, Column: 5}
+	if syntaxErr.Errors[0].Pos != expectedPos {
+		t.Errorf("wrong position. expected %s, got %s", expectedPos,
+			syntaxErr.Errors[0].Pos)
+	}
+
+	_, err = run(interp, "let x = 1;\nx + true")
+	runtimeErr, ok := err.(*RuntimeError)
+	if !ok {
+		t.Fatalf("error is not *RuntimeError. got %T (%v)", err, err)
+	}
+	expected := "2:3: type mismatch: INTEGER + BOOLEAN"
+	if runtimeErr.Error() != expected {
+		t.Errorf("wrong error. expected %q, got %q", expected, runtimeErr)
+	}
+}
+
+func TestRunCancellation(t *testing.T) {
+	interp := New(evaluator.Options{})
+	prog, err := interp.Compile("while (true) { }")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(),
+		10*time.Millisecond)
+	defer cancel()
+
+	_, err = interp.Run(ctx, prog)
+	if err == nil || !strings.Contains(err.Error(), "execution cancelled") {
+		t.Errorf("expected a cancellation error. got %v", err)
+	}
+}
+
+func run(interp *Interpreter, input string) (interface{}, error) {
+	prog, err := interp.Compile(input)
+	if err != nil {
+		return nil, err
+	}
+	return interp.Run(context.Background(), prog)
+}
@@ -0,0 +1,51 @@
+package object
+
+// Environment stores the bindings visible at a point in a Monkey program.
+// Environments are chained: a function call gets a new Environment whose
+// outer Environment is the one the function was defined in.
+type Environment struct {
+	store map[string]Object
+	outer *Environment
+}
+
+// NewEnvironment initialises and returns an empty top-level Environment.
+func NewEnvironment() *Environment {
+	s := make(map[string]Object)
+	return &Environment{store: s, outer: nil}
+}
+
+// NewEnclosedEnvironment returns a new Environment nested inside outer.
+func NewEnclosedEnvironment(outer *Environment) *Environment {
+	env := NewEnvironment()
+	env.outer = outer
+	return env
+}
+
+// Get looks up name in the Environment and then in each enclosing
+// Environment in turn.
+func (e *Environment) Get(name string) (Object, bool) {
+	obj, ok := e.store[name]
+	if !ok && e.outer != nil {
+		obj, ok = e.outer.Get(name)
+	}
+	return obj, ok
+}
+
+// Set creates or overwrites the binding name in this Environment.
+func (e *Environment) Set(name string, val Object) Object {
+	e.store[name] = val
+	return val
+}
+
+// Assign updates an existing binding, in whichever Environment in the chain
+// defines it. Returns false, without creating a binding, if name isn't
+// defined anywhere.
+func (e *Environment) Assign(name string, val Object) bool {
+	for env := e; env != nil; env = env.outer {
+		if _, ok := env.store[name]; ok {
+			env.store[name] = val
+			return true
+		}
+	}
+	return false
+}
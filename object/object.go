@@ -1,7 +1,13 @@
 package object
 
 import (
+	"bytes"
 	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jamesroutley/monkey/ast"
+	"github.com/jamesroutley/monkey/token"
 )
 
 type ObjectType string
@@ -9,7 +15,37 @@ type ObjectType string
 const (
 	BOOLEAN_OBJ = "BOOLEAN"
 	INTEGER_OBJ = "INTEGER"
-	NULL_OBJ = "NULL"
+	NULL_OBJ    = "NULL"
+	ERROR_OBJ   = "ERROR"
+
+	EXCEPTION_OBJ = "EXCEPTION"
+	RESULT_OBJ    = "RESULT"
+	STRUCT_OBJ    = "STRUCT"
+	METHOD_OBJ    = "METHOD"
+	ENUM_OBJ      = "ENUM"
+	VARIANT_OBJ   = "VARIANT"
+
+	STRUCT_VALUE_OBJ = "STRUCT_VALUE"
+	ENUM_VALUE_OBJ   = "ENUM_VALUE"
+
+	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
+	FUNCTION_OBJ     = "FUNCTION"
+	BUILTIN_OBJ      = "BUILTIN"
+	TAIL_CALL_OBJ    = "TAIL_CALL"
+	RANGE_OBJ        = "RANGE"
+
+	STRING_OBJ = "STRING"
+	ARRAY_OBJ  = "ARRAY"
+	TUPLE_OBJ  = "TUPLE"
+	HASH_OBJ   = "HASH"
+
+	MODULE_OBJ = "MODULE"
+	REGEX_OBJ  = "REGEX"
+
+	QUOTE_OBJ = "QUOTE"
+	MACRO_OBJ = "MACRO"
 )
 
 type Object interface {
@@ -17,6 +53,28 @@ type Object interface {
 	Inspect() string
 }
 
+// TypeName returns the name of obj's type, for messages: the name of its
+// struct or enum type, if it's a struct or enum value, and otherwise its
+// ObjectType.
+func TypeName(obj Object) string {
+	switch obj := obj.(type) {
+	case *Struct:
+		return obj.Def.Name
+	case *EnumValue:
+		return obj.Variant.Enum.Name
+	}
+	return string(obj.Type())
+}
+
+// MemberAccessor is implemented by objects with named members, which are
+// accessed with the . operator, e.g. value.name.
+type MemberAccessor interface {
+	Object
+	// Member returns the member called name, or an error explaining why
+	// it can't be accessed.
+	Member(name string) (Object, error)
+}
+
 type Integer struct {
 	Value int64
 }
@@ -39,7 +97,7 @@ func (i *Boolean) Type() ObjectType {
 	return BOOLEAN_OBJ
 }
 
-type Null struct {}
+type Null struct{}
 
 func (n *Null) Inspect() string {
 	return "null"
@@ -47,3 +105,637 @@ func (n *Null) Inspect() string {
 func (n *Null) Type() ObjectType {
 	return NULL_OBJ
 }
+
+// Error is a runtime error produced while evaluating a Monkey program. It
+// unwinds the program until it's caught by a try expression.
+type Error struct {
+	Message string
+	// Pos is the position of the AST node whose evaluation produced the
+	// error, if known.
+	Pos token.Position
+	// Value is the value passed to throw, or nil if the error was raised by
+	// the interpreter.
+	Value Object
+	// Stack holds the Monkey functions the error has unwound through,
+	// innermost first.
+	Stack []Frame
+	// Fatal errors, like those raised when a resource limit is reached,
+	// can't be caught.
+	Fatal bool
+}
+
+func (e *Error) Inspect() string {
+	return "ERROR: " + e.Message
+}
+func (e *Error) Type() ObjectType {
+	return ERROR_OBJ
+}
+
+// Frame is a call to a Monkey function.
+type Frame struct {
+	Function string
+	// Pos is the position of the call, if known.
+	Pos token.Position
+}
+
+func (f Frame) String() string {
+	if !f.Pos.IsValid() {
+		return f.Function
+	}
+	return f.Function + " called at " + f.Pos.String()
+}
+
+// Exception is an error caught by a try expression, which is bound to the
+// name given in its catch clause.
+type Exception struct {
+	// Value is the value thrown, or for errors raised by the interpreter,
+	// the message.
+	Value Object
+	// Message describes the error.
+	Message *String
+	// Stack is an array of strings describing the calls the error unwound
+	// through, innermost first.
+	Stack *Array
+	// Err is the caught error, which is raised again if the exception is
+	// thrown.
+	Err *Error
+}
+
+func (e *Exception) Inspect() string {
+	return "exception(" + e.Message.Value + ")"
+}
+func (e *Exception) Type() ObjectType {
+	return EXCEPTION_OBJ
+}
+
+// Member returns the exception's value, message or stack.
+func (e *Exception) Member(name string) (Object, error) {
+	switch name {
+	case "value":
+		return e.Value, nil
+	case "message":
+		return e.Message, nil
+	case "stack":
+		return e.Stack, nil
+	default:
+		return nil, fmt.Errorf("exception has no member %s", name)
+	}
+}
+
+// ReturnValue wraps the value of a return statement, so the evaluator can
+// stop evaluating the rest of the enclosing function body.
+type ReturnValue struct {
+	Value Object
+	// Propagated is set when the value is an err result returned by the ?
+	// operator, rather than by a return statement.
+	Propagated bool
+}
+
+func (rv *ReturnValue) Inspect() string {
+	return rv.Value.Inspect()
+}
+func (rv *ReturnValue) Type() ObjectType {
+	return RETURN_VALUE_OBJ
+}
+
+// Break signals that the innermost enclosing loop should stop.
+type Break struct{}
+
+func (b *Break) Inspect() string {
+	return "break"
+}
+func (b *Break) Type() ObjectType {
+	return BREAK_OBJ
+}
+
+// Continue signals that the innermost enclosing loop should skip to its next
+// iteration.
+type Continue struct{}
+
+func (c *Continue) Inspect() string {
+	return "continue"
+}
+func (c *Continue) Type() ObjectType {
+	return CONTINUE_OBJ
+}
+
+// Result is the result of an operation which may fail: either ok, holding
+// the operation's value, or err, holding a value describing why it failed.
+type Result struct {
+	Ok    bool
+	Value Object
+}
+
+func (r *Result) Inspect() string {
+	if r.Ok {
+		return "ok(" + r.Value.Inspect() + ")"
+	}
+	return "err(" + r.Value.Inspect() + ")"
+}
+func (r *Result) Type() ObjectType {
+	return RESULT_OBJ
+}
+
+// StructType is a struct type declared by a struct statement. Calling it
+// constructs a Struct.
+type StructType struct {
+	Name   string
+	Fields []string
+	// Methods are declared by impl statements.
+	Methods map[string]*Function
+}
+
+func (st *StructType) Inspect() string {
+	return "struct " + st.Name
+}
+func (st *StructType) Type() ObjectType {
+	return STRUCT_OBJ
+}
+
+// Member returns the method called name, which can be called with a struct
+// as its first argument.
+func (st *StructType) Member(name string) (Object, error) {
+	method, ok := st.Methods[name]
+	if !ok {
+		return nil, fmt.Errorf("%s has no method %s", st.Name, name)
+	}
+	return method, nil
+}
+
+// FieldIndex returns the index of the field called name, or -1 if there
+// isn't one.
+func (st *StructType) FieldIndex(name string) int {
+	return fieldIndex(st.Fields, name)
+}
+
+func fieldIndex(fields []string, name string) int {
+	for i, field := range fields {
+		if field == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Struct is a value of a struct type. Its Values are in the order of its
+// type's Fields. Structs are immutable: a with expression makes an updated
+// copy.
+type Struct struct {
+	Def    *StructType
+	Values []Object
+}
+
+func (s *Struct) Inspect() string {
+	fields := make([]string, len(s.Values))
+	for i, value := range s.Values {
+		fields[i] = s.Def.Fields[i] + ": " + value.Inspect()
+	}
+	return s.Def.Name + "{" + strings.Join(fields, ", ") + "}"
+}
+
+// Type returns STRUCT_VALUE_OBJ, whatever the struct's type, so a struct
+// type can't be mistaken for a builtin type with the same name. TypeName
+// returns the name of its type.
+func (s *Struct) Type() ObjectType {
+	return STRUCT_VALUE_OBJ
+}
+
+// Member returns the field called name, or if there isn't one, the method
+// called name, bound to the struct.
+func (s *Struct) Member(name string) (Object, error) {
+	if i := s.Def.FieldIndex(name); i >= 0 {
+		return s.Values[i], nil
+	}
+	if method, ok := s.Def.Methods[name]; ok {
+		return &BoundMethod{Receiver: s, Method: method}, nil
+	}
+	return nil, fmt.Errorf("%s has no field or method %s", s.Def.Name, name)
+}
+
+// EnumType is an enum type declared by an enum statement. Its members are
+// its variants and methods.
+type EnumType struct {
+	Name     string
+	Variants []*Variant
+	// Methods are declared by impl statements.
+	Methods map[string]*Function
+}
+
+func (et *EnumType) Inspect() string {
+	return "enum " + et.Name
+}
+func (et *EnumType) Type() ObjectType {
+	return ENUM_OBJ
+}
+
+// Variant returns the variant called name, or nil if there isn't one.
+func (et *EnumType) Variant(name string) *Variant {
+	for _, v := range et.Variants {
+		if v.Name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// Member returns the variant called name, or if there isn't one, the method
+// called name. A variant without a payload has only one value, which is
+// returned instead of the variant.
+func (et *EnumType) Member(name string) (Object, error) {
+	if v := et.Variant(name); v != nil {
+		if v.Value != nil {
+			return v.Value, nil
+		}
+		return v, nil
+	}
+	if method, ok := et.Methods[name]; ok {
+		return method, nil
+	}
+	return nil, fmt.Errorf("%s has no variant or method %s", et.Name, name)
+}
+
+// Variant is one of an enum's variants. Calling a variant with a payload
+// constructs an EnumValue. A variant without a payload has one value, Value.
+type Variant struct {
+	Enum   *EnumType
+	Name   string
+	Fields []string
+	Value  *EnumValue
+}
+
+func (v *Variant) Inspect() string {
+	return "variant " + v.QualifiedName()
+}
+func (v *Variant) Type() ObjectType {
+	return VARIANT_OBJ
+}
+
+// QualifiedName returns the variant's name, prefixed by its enum's, e.g.
+// Shape.Circle.
+func (v *Variant) QualifiedName() string {
+	return v.Enum.Name + "." + v.Name
+}
+
+// FieldIndex returns the index of the payload field called name, or -1 if
+// there isn't one.
+func (v *Variant) FieldIndex(name string) int {
+	return fieldIndex(v.Fields, name)
+}
+
+// EnumValue is a value of an enum type. Its Values are its payload, in the
+// order of its variant's Fields.
+type EnumValue struct {
+	Variant *Variant
+	Values  []Object
+}
+
+func (ev *EnumValue) Inspect() string {
+	if len(ev.Variant.Fields) == 0 {
+		return ev.Variant.QualifiedName()
+	}
+	values := make([]string, len(ev.Values))
+	for i, value := range ev.Values {
+		values[i] = value.Inspect()
+	}
+	return ev.Variant.QualifiedName() + "(" + strings.Join(values, ", ") + ")"
+}
+
+// Type returns ENUM_VALUE_OBJ, whatever the value's enum type, so an enum
+// type can't be mistaken for a builtin type with the same name. TypeName
+// returns the name of its type.
+func (ev *EnumValue) Type() ObjectType {
+	return ENUM_VALUE_OBJ
+}
+
+// Member returns the payload field called name, or if there isn't one, the
+// method called name, bound to the value.
+func (ev *EnumValue) Member(name string) (Object, error) {
+	if i := ev.Variant.FieldIndex(name); i >= 0 {
+		return ev.Values[i], nil
+	}
+	if method, ok := ev.Variant.Enum.Methods[name]; ok {
+		return &BoundMethod{Receiver: ev, Method: method}, nil
+	}
+	return nil, fmt.Errorf("%s has no field or method %s",
+		ev.Variant.QualifiedName(), name)
+}
+
+// BoundMethod is a method bound to the value it was accessed on, e.g. p.add
+// in p.add(q). Calling it calls the method with the value as its first
+// argument.
+type BoundMethod struct {
+	Receiver Object
+	Method   *Function
+}
+
+func (bm *BoundMethod) Inspect() string {
+	return "method " + bm.Method.Name
+}
+func (bm *BoundMethod) Type() ObjectType {
+	return METHOD_OBJ
+}
+
+// Function is a function value. Env is the Environment the function literal
+// was evaluated in, which gives Monkey closures.
+type Function struct {
+	Parameters []*ast.Parameter
+	Body       *ast.BlockStatement
+	Env        *Environment
+	// Name is the name the function literal was bound to by a let
+	// statement, if any.
+	Name string
+}
+
+func (f *Function) Inspect() string {
+	var out bytes.Buffer
+	params := []string{}
+	for _, p := range f.Parameters {
+		params = append(params, p.String())
+	}
+	out.WriteString("fn(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(f.Body.String())
+	out.WriteString("\n}")
+	return out.String()
+}
+func (f *Function) Type() ObjectType {
+	return FUNCTION_OBJ
+}
+
+// Quote is an unevaluated piece of a program, produced by quote. Macros
+// return Quotes, which replace the calls to them.
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Inspect() string {
+	return "QUOTE(" + q.Node.String() + ")"
+}
+func (q *Quote) Type() ObjectType {
+	return QUOTE_OBJ
+}
+
+// Macro is a macro defined by a MacroLiteral. Macros are called while a
+// program's macros are expanded, with their arguments quoted rather than
+// evaluated.
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Inspect() string {
+	var out bytes.Buffer
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+	out.WriteString("macro(")
+	out.WriteString(strings.Join(params, ", "))
+	out.WriteString(") {\n")
+	out.WriteString(m.Body.String())
+	out.WriteString("\n}")
+	return out.String()
+}
+func (m *Macro) Type() ObjectType {
+	return MACRO_OBJ
+}
+
+// TailCall is a call to Function which has been deferred because it's in
+// tail position. It's returned in place of the call's result, and the
+// evaluator makes the call once the calling function has returned, so the
+// stack doesn't grow.
+type TailCall struct {
+	Function  *Function
+	Arguments []Object
+	Named     []NamedArgument
+}
+
+// NamedArgument is an argument passed to a function's parameter by name.
+type NamedArgument struct {
+	Name  string
+	Value Object
+}
+
+func (tc *TailCall) Inspect() string {
+	return "tail call to " + tc.Function.Inspect()
+}
+func (tc *TailCall) Type() ObjectType {
+	return TAIL_CALL_OBJ
+}
+
+// BuiltinFunction is the Go implementation of a builtin function.
+type BuiltinFunction func(args ...Object) Object
+
+// Builtin is a function value implemented in Go rather than Monkey.
+type Builtin struct {
+	Name string
+	Fn   BuiltinFunction
+	// SideEffects is set if the builtin affects the world outside the
+	// program, e.g. by writing output. Such builtins can be disabled when
+	// running untrusted code.
+	SideEffects bool
+}
+
+func (b *Builtin) Inspect() string {
+	return "builtin function"
+}
+func (b *Builtin) Type() ObjectType {
+	return BUILTIN_OBJ
+}
+
+// Range is the sequence of integers from Start up to, but not including,
+// Stop, going up (or down, if negative) by Step. Ranges are lazy: iterating
+// over one doesn't allocate the whole sequence up front.
+type Range struct {
+	Start int64
+	Stop  int64
+	Step  int64
+}
+
+func (r *Range) Inspect() string {
+	if r.Step == 1 {
+		return fmt.Sprintf("range(%d, %d)", r.Start, r.Stop)
+	}
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.Stop, r.Step)
+}
+func (r *Range) Type() ObjectType {
+	return RANGE_OBJ
+}
+
+type String struct {
+	Value string
+}
+
+func (s *String) Inspect() string {
+	return s.Value
+}
+func (s *String) Type() ObjectType {
+	return STRING_OBJ
+}
+
+type Array struct {
+	Elements []Object
+}
+
+func (a *Array) Inspect() string {
+	var out bytes.Buffer
+	elements := []string{}
+	for _, e := range a.Elements {
+		elements = append(elements, e.Inspect())
+	}
+	out.WriteString("[")
+	out.WriteString(strings.Join(elements, ", "))
+	out.WriteString("]")
+	return out.String()
+}
+func (a *Array) Type() ObjectType {
+	return ARRAY_OBJ
+}
+
+// Tuple is a fixed sequence of values, such as the several values returned
+// by a function.
+type Tuple struct {
+	Elements []Object
+}
+
+func (t *Tuple) Inspect() string {
+	elements := []string{}
+	for _, e := range t.Elements {
+		elements = append(elements, e.Inspect())
+	}
+	return "(" + strings.Join(elements, ", ") + ")"
+}
+func (t *Tuple) Type() ObjectType {
+	return TUPLE_OBJ
+}
+
+// HashKey identifies a hash key by value, so that e.g. two separately
+// allocated Strings with the same value refer to the same hash entry.
+type HashKey struct {
+	Type  ObjectType
+	Value int64  // Value of integer and boolean keys.
+	Text  string // Value of string keys.
+}
+
+// Hashable is implemented by objects which can be used as hash keys.
+type Hashable interface {
+	Object
+	HashKey() HashKey
+}
+
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: i.Value}
+}
+
+func (b *Boolean) HashKey() HashKey {
+	var value int64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+func (s *String) HashKey() HashKey {
+	return HashKey{Type: s.Type(), Text: s.Value}
+}
+
+// HashPair is an entry in a Hash. The original key is kept alongside the
+// value so the hash can be inspected and iterated over.
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash maps hashable keys to values. Entries are kept in the order their
+// keys were first added, so inspecting or iterating over a hash is
+// deterministic.
+type Hash struct {
+	Pairs map[HashKey]HashPair
+	Keys  []HashKey
+}
+
+// NewHash initialises and returns an empty Hash.
+func NewHash() *Hash {
+	return &Hash{Pairs: make(map[HashKey]HashPair)}
+}
+
+// Set adds or replaces the entry for key.
+func (h *Hash) Set(key Hashable, value Object) {
+	hashKey := key.HashKey()
+	if _, ok := h.Pairs[hashKey]; !ok {
+		h.Keys = append(h.Keys, hashKey)
+	}
+	h.Pairs[hashKey] = HashPair{Key: key, Value: value}
+}
+
+// Get returns the value for key, and whether there is one.
+func (h *Hash) Get(key Hashable) (Object, bool) {
+	pair, ok := h.Pairs[key.HashKey()]
+	return pair.Value, ok
+}
+
+// OrderedPairs returns the hash's entries in the order they were added.
+func (h *Hash) OrderedPairs() []HashPair {
+	pairs := make([]HashPair, len(h.Keys))
+	for i, key := range h.Keys {
+		pairs[i] = h.Pairs[key]
+	}
+	return pairs
+}
+
+func (h *Hash) Inspect() string {
+	var out bytes.Buffer
+	pairs := []string{}
+	for _, pair := range h.OrderedPairs() {
+		pairs = append(pairs, pair.Key.Inspect()+": "+pair.Value.Inspect())
+	}
+	out.WriteString("{")
+	out.WriteString(strings.Join(pairs, ", "))
+	out.WriteString("}")
+	return out.String()
+}
+func (h *Hash) Type() ObjectType {
+	return HASH_OBJ
+}
+
+// Module is an imported Monkey source file. Its top-level bindings are its
+// members, except for those whose names start with an underscore, which are
+// private to the module.
+type Module struct {
+	Name string
+	Env  *Environment
+}
+
+func (m *Module) Inspect() string {
+	return "module(" + m.Name + ")"
+}
+func (m *Module) Type() ObjectType {
+	return MODULE_OBJ
+}
+
+// Member returns the module's top-level binding called name.
+func (m *Module) Member(name string) (Object, error) {
+	if strings.HasPrefix(name, "_") {
+		return nil, fmt.Errorf("cannot access private member %s of module %s",
+			name, m.Name)
+	}
+	value, ok := m.Env.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("module %s has no member %s", m.Name, name)
+	}
+	return value, nil
+}
+
+// Regex is a compiled regular expression, created by the regex module.
+type Regex struct {
+	Regexp *regexp.Regexp
+}
+
+func (r *Regex) Inspect() string {
+	return "regex(" + r.Regexp.String() + ")"
+}
+func (r *Regex) Type() ObjectType {
+	return REGEX_OBJ
+}
@@ -0,0 +1,130 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/jamesroutley/monkey/lexer"
+	"github.com/jamesroutley/monkey/token"
+)
+
+// conformanceTests is the grammar conformance suite. Each input is lexed,
+// parsed and printed back with String(), which makes the structure of the
+// AST explicit. Between them the inputs must use every token type in package
+// token (see TestGrammarConformanceCoverage), so a token which is lexed or
+// parsed wrongly shows up here.
+var conformanceTests = []struct {
+	input    string
+	expected string
+}{
+	// Literals and identifiers
+	{"5;", "5"},
+	{"foobar;", "foobar"},
+	{"true; false;", "truefalse"},
+	{`"hello world";`, `"hello world"`},
+	{`"tab\t \"quoted\" back\\slash\n";`, `"tab\t \"quoted\" back\\slash\n"`},
+	{"[1, a, 2 + 3];", "[1, a, (2 + 3)]"},
+	{`{"a": 1, b: c, 1 + 1: true};`, `{"a": 1, b: c, (1 + 1): true}`},
+	{"{};", "{}"},
+	{"(a, b + c);", "(a, (b + c))"},
+
+	// Statements
+	{"let x = 5;", "let x = 5;"},
+	{"return x;", "return x;"},
+	{"return x, y;", "return (x, y);"},
+	{`throw "x" + y;`, `throw ("x" + y);`},
+	{"struct Point { x, y, }; struct Empty {}", "struct Point {x, y}struct Empty {}"},
+	{"enum Shape { Circle(r), Rect(w, h,), Empty, }", "enum Shape {Circle(r), Rect(w, h), Empty}"},
+	{"impl Point { fn len(self) { 2 }; fn add(a, b) { a } }", "impl Point {fn len(self)2 fn add(a, b)a}"},
+	{"let [a, [b, _], ...rest] = xs;", "let [a, [b, _], ...rest] = xs;"},
+	{"let {x, y: alias, z: [c]} = h;", "let {x, y: alias, z: [c]} = h;"},
+	{"let f = fn(x, y) { return x; };", "let f = fn(x, y)return x;;"},
+	{"fn(a, b = a + 1, ...rest) { };", "fn(a, b = (a + 1), ...rest)"},
+	{"let m = macro(a, b) { quote(a); };", "let m = macro(a, b)quote(a);"},
+
+	// Prefix operators
+	{"!x;", "(!x)"},
+	{"-x;", "(-x)"},
+
+	// Infix operators
+	{"a + b - c;", "((a + b) - c)"},
+	{"a * b / c % d;", "(((a * b) / c) % d)"},
+	{"a ** b ** c;", "(a ** (b ** c))"},
+	{"a < b == c > d;", "((a < b) == (c > d))"},
+	{"a <= b != c >= d;", "((a <= b) != (c >= d))"},
+	{"a && b || c;", "((a && b) || c)"},
+	{"a & b | c ^ d;", "((a & b) | (c ^ d))"},
+	{"a << b >> c;", "((a << b) >> c)"},
+
+	// Assignment
+	{"x = y;", "(x = y)"},
+	{"x += 1; x -= 1; x *= 2; x /= 2;", "(x += 1)(x -= 1)(x *= 2)(x /= 2)"},
+
+	// Grouping and calls
+	{"(a + b) * c;", "((a + b) * c)"},
+	{"f(a, b + c);", "f(a, (b + c))"},
+	{"f(a, ...xs, b: 1 + 2, c: d);", "f(a, ...xs, b: (1 + 2), c: d)"},
+	{"a[b + 1][c];", "((a[(b + 1)])[c])"},
+	{"f(x)[0];", "(f(x)[0])"},
+	{"a.b.c(d)[e];", "(((a.b).c)(d)[e])"},
+	{"f(x)?.y?;", "(((f(x)?).y)?)"},
+	{"p.q with { x: 1 + 2, y: y } == p;", "(((p.q) with {x: (1 + 2), y: y}) == p)"},
+	{`let m = import "lib/m"; m.f();`, `let m = import "lib/m";(m.f)()`},
+
+	// Blocks
+	{"if (a) { b } else { c };", "ifa belse c"},
+	{"if (a) { b; c }", "ifa bc"},
+	{"if (a) { b } else if (c) { d } else { e }", "ifa belse ifc delse e"},
+	{`switch (x) { case 1, 2: a; b case y: default: c }`,
+		`switch(x) {case 1, 2: ab case y:  default: c}`},
+	{"fn() { }();", "fn()()"},
+	{"try { a } catch (e) { b } finally { c };", "try acatch(e) bfinally c"},
+	{"try { a } finally { c };", "try afinally c"},
+	{`match (x) { 1 | -2 => a, "s" => b, n if n > 0 => n, _ => c, }`,
+		`match(x) {1 | -2 => a, "s" => b, n if (n > 0) => n, _ => c}`},
+	{`match (s) { S.A(x, S.B(_, 1)) => x, S.C | S.D() => y }`,
+		`match(s) {S.A(x, S.B(_, 1)) => x, S.C | S.D() => y}`},
+
+	// Loops
+	{"while (a) { b; }", "whilea b"},
+	{"for (x in xs) { break; continue; }", "for(x in xs) break;continue;"},
+}
+
+func TestGrammarConformance(t *testing.T) {
+	for _, tt := range conformanceTests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		actual := program.String()
+		if actual != tt.expected {
+			t.Errorf("%q: expected %q. got %q", tt.input, tt.expected,
+				actual)
+		}
+	}
+}
+
+func TestGrammarConformanceCoverage(t *testing.T) {
+	expected := map[token.TokenType]bool{
+		token.IDENT:  true,
+		token.INT:    true,
+		token.STRING: true,
+	}
+	for _, tokenType := range token.Punctuation {
+		expected[tokenType] = true
+	}
+	for _, tokenType := range token.Keywords {
+		expected[tokenType] = true
+	}
+
+	for _, tt := range conformanceTests {
+		l := lexer.New(tt.input)
+		for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+			delete(expected, tok.Type)
+		}
+	}
+
+	for tokenType := range expected {
+		t.Errorf("token %q isn't used by any conformance test", tokenType)
+	}
+}
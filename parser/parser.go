@@ -3,7 +3,6 @@ package parser
 
 import (
 	"fmt"
-	"log"
 	"strconv"
 
 	"github.com/jamesroutley/monkey/ast"
@@ -16,43 +15,76 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN      // = or +=
+	LOGICALOR   // ||
+	LOGICALAND  // &&
 	EQUALS      // ==
 	LESSGREATER // > or <
+	BITWISEOR   // |
+	BITWISEXOR  // ^
+	BITWISEAND  // &
+	SHIFT       // << or >>
 	SUM         // +
 	PRODUCT     // *
 	PREFIX      // -x or !x
-	CALL        // myFunction(x)
+	EXPONENT    // **
+	CALL        // myFunction(x) or value with { x: 1 }
+	INDEX       // array[index], value.member or result?
 )
 
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.OR:              LOGICALOR,
+	token.AND:             LOGICALAND,
+	token.EQ:              EQUALS,
+	token.NOT_EQ:          EQUALS,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.LT_EQ:           LESSGREATER,
+	token.GT_EQ:           LESSGREATER,
+	token.BIT_OR:          BITWISEOR,
+	token.BIT_XOR:         BITWISEXOR,
+	token.BIT_AND:         BITWISEAND,
+	token.SHIFT_LEFT:      SHIFT,
+	token.SHIFT_RIGHT:     SHIFT,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.SLASH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.PERCENT:         PRODUCT,
+	token.POWER:           EXPONENT,
+	token.LPAREN:          CALL,
+	token.WITH:            CALL,
+	token.LBRACKET:        INDEX,
+	token.DOT:             INDEX,
+	token.QUESTION:        INDEX,
 }
 
 // Parser implements the parser for the Monkey language.
 type Parser struct {
 	l      *lexer.Lexer // Lexer used to lex the Money code.
-	errors []string     // Errors produced while parsing.
+	errors []*Error     // Errors produced while parsing.
 
 	curToken  token.Token // Current token being parsed.
 	peekToken token.Token // Next token to be parsed.
 
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
+
+	// Number of loops enclosing the current token, within the current
+	// function. Used to reject break and continue outside of a loop.
+	loopDepth int
 }
 
 // New initialises and returns a pointer to a Parser.
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l:      l,
-		errors: []string{},
+		errors: []*Error{},
 	}
 
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
@@ -65,6 +97,14 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
+	p.registerPrefix(token.STRING, p.parseStringLiteral)
+	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)
+	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
+	p.registerPrefix(token.IMPORT, p.parseImportExpression)
+	p.registerPrefix(token.MACRO, p.parseMacroLiteral)
+	p.registerPrefix(token.MATCH, p.parseMatchExpression)
+	p.registerPrefix(token.SWITCH, p.parseSwitchExpression)
+	p.registerPrefix(token.TRY, p.parseTryExpression)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
 	p.registerInfix(token.PLUS, p.parseInfixExpression)
@@ -73,9 +113,29 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression)
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.BIT_AND, p.parseInfixExpression)
+	p.registerInfix(token.BIT_OR, p.parseInfixExpression)
+	p.registerInfix(token.BIT_XOR, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_LEFT, p.parseInfixExpression)
+	p.registerInfix(token.SHIFT_RIGHT, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseRightAssociativeInfixExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.LBRACKET, p.parseIndexExpression)
+	p.registerInfix(token.DOT, p.parseMemberExpression)
+	p.registerInfix(token.QUESTION, p.parsePropagateExpression)
+	p.registerInfix(token.WITH, p.parseWithExpression)
 
 	// Read two tokens, so curToken and peekToken are both set.
 	p.nextToken()
@@ -90,16 +150,40 @@ func (p *Parser) nextToken() {
 	p.peekToken = p.l.NextToken()
 }
 
-// Errors returns the Parser's errors.
+// Error is an error found while parsing, at position Pos in the source.
+type Error struct {
+	Pos     token.Position
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Pos.String() + ": " + e.Message
+}
+
+// Errors returns the messages of the Parser's errors.
 func (p *Parser) Errors() []string {
+	msgs := make([]string, len(p.errors))
+	for i, err := range p.errors {
+		msgs[i] = err.Message
+	}
+	return msgs
+}
+
+// ParseErrors returns the Parser's errors, including their positions.
+func (p *Parser) ParseErrors() []*Error {
 	return p.errors
 }
 
+// addError records an error at pos.
+func (p *Parser) addError(pos token.Position, format string, a ...interface{}) {
+	msg := fmt.Sprintf(format, a...)
+	p.errors = append(p.errors, &Error{Pos: pos, Message: msg})
+}
+
 // ParseProgram parses the program loaded into the lexer and returns its AST.
 // Monkey programs consist of a list of statements. These statements are
 // recursively parsed, one by one.
 func (p *Parser) ParseProgram() *ast.Program {
-	log.Printf("Parsing program")
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
 
@@ -110,8 +194,6 @@ func (p *Parser) ParseProgram() *ast.Program {
 		}
 		p.nextToken()
 	}
-	log.Println("Finished parsing")
-	log.Printf("Program: %v", program)
 	return program
 }
 
@@ -132,39 +214,454 @@ func (p *Parser) parseStatement() ast.Statement {
 		return p.parseLetStatement()
 	case token.RETURN:
 		return p.parseReturnStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK:
+		return p.parseBreakStatement()
+	case token.CONTINUE:
+		return p.parseContinueStatement()
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.STRUCT:
+		return p.parseStructStatement()
+	case token.ENUM:
+		return p.parseEnumStatement()
+	case token.IMPL:
+		return p.parseImplStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
 // parseLetStatement parses 'let' statements.
-// e.g. 'let x = 5;'
+// e.g. 'let x = 5;' or 'let [a, b] = pair;'
 func (p *Parser) parseLetStatement() *ast.LetStatement {
-	log.Println("Parsing 'let' statement")
 	stmt := &ast.LetStatement{Token: p.curToken}
 
-	if !p.expectPeek(token.IDENT) {
+	if p.peekTokenIs(token.LBRACKET) || p.peekTokenIs(token.LBRACE) {
+		p.nextToken()
+		stmt.Pattern = p.parseBindingPattern()
+		if stmt.Pattern == nil {
+			return nil
+		}
+	} else if p.expectPeek(token.IDENT) {
+		stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	} else {
 		return nil
 	}
-	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
 	}
 	p.nextToken()
 	stmt.Value = p.parseExpression(LOWEST)
+	if fn, ok := stmt.Value.(*ast.FunctionLiteral); ok && stmt.Name != nil {
+		fn.Name = stmt.Name.Value
+	}
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
 	return stmt
 }
 
+// parseBindingPattern parses what a let statement destructures its value
+// into: a name, or an array or hash pattern containing further patterns.
+func (p *Parser) parseBindingPattern() ast.Expression {
+	switch p.curToken.Type {
+	case token.IDENT:
+		return p.parseIdentifier()
+	case token.LBRACKET:
+		return p.parseArrayPattern()
+	case token.LBRACE:
+		return p.parseHashPattern()
+	}
+	p.addError(p.curToken.Pos, "expected a name or pattern, got %s instead",
+		p.curToken.Type)
+	return nil
+}
+
+// parseArrayPattern parses an array pattern.
+// e.g. '[a, [b, c], ...rest]'
+func (p *Parser) parseArrayPattern() ast.Expression {
+	pattern := &ast.ArrayPattern{Token: p.curToken}
+	for !p.peekTokenIs(token.RBRACKET) {
+		p.nextToken()
+		if p.curTokenIs(token.ELLIPSIS) {
+			// The rest of the elements can only be bound at the end.
+			if !p.expectPeek(token.IDENT) {
+				return nil
+			}
+			pattern.Rest = &ast.Identifier{Token: p.curToken,
+				Value: p.curToken.Literal}
+			break
+		}
+		element := p.parseBindingPattern()
+		if element == nil {
+			return nil
+		}
+		pattern.Elements = append(pattern.Elements, element)
+		if !p.peekTokenIs(token.RBRACKET) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	return pattern
+}
+
+// parseHashPattern parses a hash pattern.
+// e.g. '{x, y: alias, z: [a, b]}'
+func (p *Parser) parseHashPattern() ast.Expression {
+	pattern := &ast.HashPattern{Token: p.curToken}
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		key := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		// The key and the name it's bound to are separate nodes, so the name
+		// can be renamed without changing the key.
+		pair := ast.HashPatternPair{
+			Key:   key,
+			Value: &ast.Identifier{Token: key.Token, Value: key.Value},
+		}
+		if p.peekTokenIs(token.COLON) {
+			p.nextToken()
+			p.nextToken()
+			pair.Value = p.parseBindingPattern()
+			if pair.Value == nil {
+				return nil
+			}
+		}
+		pattern.Pairs = append(pattern.Pairs, pair)
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	return pattern
+}
+
+// parseReturnStatement parses 'return' statements. Returning several values,
+// e.g. 'return a, b;', returns them as a tuple.
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
-	log.Println("Parsing 'return' statement")
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 
 	p.nextToken()
 
 	stmt.ReturnValue = p.parseExpression(LOWEST)
+	if p.peekTokenIs(token.COMMA) {
+		tuple := &ast.TupleLiteral{
+			Token: token.Token{
+				Type:    token.LPAREN,
+				Literal: "(",
+				Pos:     p.curToken.Pos,
+			},
+			Elements: []ast.Expression{stmt.ReturnValue},
+		}
+		for p.peekTokenIs(token.COMMA) {
+			p.nextToken()
+			p.nextToken()
+			tuple.Elements = append(tuple.Elements, p.parseExpression(LOWEST))
+		}
+		stmt.ReturnValue = tuple
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parseWhileStatement parses 'while' loops.
+// e.g. 'while (x < 10) { x += 1; }'
+func (p *Parser) parseWhileStatement() *ast.WhileStatement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseLoopBody()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parseForStatement parses 'for' loops.
+// e.g. 'for (i in range(10)) { total += i; }'
+func (p *Parser) parseForStatement() *ast.ForStatement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseLoopBody()
+
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parseLoopBody parses the block statement of a loop, allowing break and
+// continue within it.
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+	return p.parseBlockStatement()
+}
+
+// parseStructStatement parses struct declarations.
+// e.g. 'struct Point { x, y }'
+func (p *Parser) parseStructStatement() *ast.StructStatement {
+	stmt := &ast.StructStatement{Token: p.curToken}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	seen := map[string]bool{}
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		field := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if seen[field.Value] {
+			p.addError(field.Token.Pos, "duplicate field %s", field.Value)
+		}
+		seen[field.Value] = true
+		stmt.Fields = append(stmt.Fields, field)
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parseEnumStatement parses the declaration of an enum type.
+// e.g. 'enum Shape { Circle(r), Rect(w, h), Empty }'
+func (p *Parser) parseEnumStatement() *ast.EnumStatement {
+	stmt := &ast.EnumStatement{Token: p.curToken}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	seen := map[string]bool{}
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		variant := &ast.EnumVariant{
+			Name: &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal},
+		}
+		if seen[variant.Name.Value] {
+			p.addError(variant.Name.Token.Pos, "duplicate variant %s",
+				variant.Name.Value)
+		}
+		seen[variant.Name.Value] = true
+		if p.peekTokenIs(token.LPAREN) {
+			p.nextToken()
+			variant.Fields = p.parseVariantFields()
+			if variant.Fields == nil {
+				return nil
+			}
+		}
+		stmt.Variants = append(stmt.Variants, variant)
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parseVariantFields parses the names of the fields of an enum variant's
+// payload, e.g. '(w, h)', starting at the '('. It returns nil if there's an
+// error.
+func (p *Parser) parseVariantFields() []*ast.Identifier {
+	fields := []*ast.Identifier{}
+	seen := map[string]bool{}
+	for !p.peekTokenIs(token.RPAREN) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		field := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if seen[field.Value] {
+			p.addError(field.Token.Pos, "duplicate field %s", field.Value)
+		}
+		seen[field.Value] = true
+		fields = append(fields, field)
+		if !p.peekTokenIs(token.RPAREN) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	return fields
+}
+
+// parseImplStatement parses the declaration of a struct type's methods.
+// e.g. 'impl Point { fn norm(self) { self.x * self.x + self.y * self.y } }'
+func (p *Parser) parseImplStatement() *ast.ImplStatement {
+	stmt := &ast.ImplStatement{Token: p.curToken}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Type = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	seen := map[string]bool{}
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.FUNCTION) {
+			return nil
+		}
+		fnToken := p.curToken
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if seen[name.Value] {
+			p.addError(name.Token.Pos, "duplicate method %s", name.Value)
+		}
+		seen[name.Value] = true
+
+		lit, ok := p.parseFunctionLiteral().(*ast.FunctionLiteral)
+		if !ok {
+			return nil
+		}
+		lit.Token = fnToken
+		lit.Name = stmt.Type.Value + "." + name.Value
+		if len(lit.Parameters) == 0 || lit.Parameters[0].Rest {
+			p.addError(name.Token.Pos, "method %s has no receiver parameter",
+				name.Value)
+		}
+		stmt.Methods = append(stmt.Methods, &ast.Method{
+			Name:     name,
+			Function: lit,
+		})
+		if p.peekTokenIs(token.SEMICOLON) {
+			p.nextToken()
+		}
+	}
+	p.nextToken()
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parseThrowStatement parses 'throw' statements.
+// e.g. 'throw "not found";'
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+	if stmt.Value == nil {
+		return nil
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+// parseTryExpression parses try expressions.
+// e.g. 'try { f() } catch (e) { 0 } finally { done() }'
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.curToken}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Body = p.parseBlockStatement()
+
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		expression.CatchName = &ast.Identifier{
+			Token: p.curToken,
+			Value: p.curToken.Literal,
+		}
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		expression.Catch = p.parseBlockStatement()
+	}
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		expression.Finally = p.parseBlockStatement()
+	}
+
+	if expression.Catch == nil && expression.Finally == nil {
+		p.addError(expression.Token.Pos, "try without catch or finally")
+		return nil
+	}
+	return expression
+}
+
+func (p *Parser) parseBreakStatement() *ast.BreakStatement {
+	stmt := &ast.BreakStatement{Token: p.curToken}
+	if p.loopDepth == 0 {
+		p.addError(p.curToken.Pos, "break outside loop")
+	}
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+	return stmt
+}
+
+func (p *Parser) parseContinueStatement() *ast.ContinueStatement {
+	stmt := &ast.ContinueStatement{Token: p.curToken}
+	if p.loopDepth == 0 {
+		p.addError(p.curToken.Pos, "continue outside loop")
+	}
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -172,7 +669,6 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 }
 
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
-	log.Println("Parsing 'expression' statement")
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 	stmt.Expression = p.parseExpression(LOWEST)
 
@@ -184,13 +680,11 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
-	log.Printf("Parsing expression. Precidence = %d", precedence)
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
 		p.noPrefixParseFnError(p.curToken.Type)
 		return nil
 	}
-	log.Printf("Prefix token type = '%s'", p.curToken.Type)
 	leftExp := prefix()
 
 	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
@@ -201,23 +695,20 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 		p.nextToken()
 		leftExp = infix(leftExp)
 	}
-	log.Printf("Left expression = ")
 	return leftExp
 }
 
 func (p *Parser) parseIdentifier() ast.Expression {
-	log.Printf("Parsing identifier")
 	return &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
 }
 
 func (p *Parser) parseIntegerLiteral() ast.Expression {
-	log.Printf("Parsing integer literal")
 	lit := &ast.IntegerLiteral{Token: p.curToken}
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.addError(p.curToken.Pos, "could not parse %q as integer",
+			p.curToken.Literal)
 		return nil
 	}
 	lit.Value = value
@@ -229,7 +720,6 @@ func (p *Parser) parseBoolean() ast.Expression {
 }
 
 func (p *Parser) parsePrefixExpression() ast.Expression {
-	log.Printf("Parsing prefix expression")
 	expression := &ast.PrefixExpression{
 		Token:    p.curToken,
 		Operator: p.curToken.Literal,
@@ -255,36 +745,245 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	return expression
 }
 
+// parseRightAssociativeInfixExpression parses infix expressions whose
+// operator groups to the right, e.g. '2 ** 3 ** 2' is '2 ** (3 ** 2)'.
+// The right operand is parsed with a slightly lower precedence, so a following
+// operator of the same precedence binds to it rather than to the left operand.
+func (p *Parser) parseRightAssociativeInfixExpression(
+	left ast.Expression,
+) ast.Expression {
+	expression := &ast.InfixExpression{
+		Token:    p.curToken,
+		Operator: p.curToken.Literal,
+		Left:     left,
+	}
+
+	precedence := p.curPrecedence()
+	p.nextToken()
+	expression.Right = p.parseExpression(precedence - 1)
+
+	return expression
+}
+
+// parseAssignExpression parses assignments to existing bindings.
+// e.g. 'x = 5' or 'x += 1'
+// Assignment is right associative, so 'x = y = 5' assigns 5 to both.
+func (p *Parser) parseAssignExpression(left ast.Expression) ast.Expression {
+	name, ok := left.(*ast.Identifier)
+	if !ok {
+		p.addError(p.curToken.Pos, "cannot assign to %s", left)
+		return nil
+	}
+	expression := &ast.AssignExpression{
+		Token:    p.curToken,
+		Name:     name,
+		Operator: p.curToken.Literal,
+	}
+
+	precedence := p.curPrecedence()
+	p.nextToken()
+	expression.Value = p.parseExpression(precedence - 1)
+
+	return expression
+}
+
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
 	exp.Arguments = p.parseCallArguments()
 	return exp
 }
 
+// parseCallArguments parses the arguments of a call expression. Each is an
+// expression, a spread expression, e.g. '...args', or a named argument, e.g.
+// 'b: 2'. Named arguments must follow the others.
 func (p *Parser) parseCallArguments() []ast.Expression {
 	args := []ast.Expression{}
-	if p.peekTokenIs(token.RPAREN) {
+	named := false
+	for !p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		var arg ast.Expression
+		switch {
+		case p.curTokenIs(token.ELLIPSIS):
+			spread := &ast.SpreadExpression{Token: p.curToken}
+			p.nextToken()
+			spread.Value = p.parseExpression(LOWEST)
+			arg = spread
+		case p.curTokenIs(token.IDENT) && p.peekTokenIs(token.COLON):
+			name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+			p.nextToken()
+			p.nextToken()
+			arg = &ast.NamedArgument{
+				Token: name.Token,
+				Name:  name,
+				Value: p.parseExpression(LOWEST),
+			}
+			named = true
+		default:
+			arg = p.parseExpression(LOWEST)
+		}
+		if arg == nil {
+			return nil
+		}
+		if _, ok := arg.(*ast.NamedArgument); !ok && named {
+			p.addError(arg.Pos(), "positional argument follows named argument")
+		}
+		args = append(args, arg)
+		if !p.peekTokenIs(token.RPAREN) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	return args
+}
+
+// parseExpressionList parses a comma separated list of expressions, ending
+// with the token end. e.g. the elements of an array.
+func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
+	list := []ast.Expression{}
+	if p.peekTokenIs(end) {
 		p.nextToken()
-		return args
+		return list
 	}
 	p.nextToken()
-	args = append(args, p.parseExpression(LOWEST))
+	list = append(list, p.parseExpression(LOWEST))
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		args = append(args, p.parseExpression(LOWEST))
+		list = append(list, p.parseExpression(LOWEST))
 	}
 
-	if !p.expectPeek(token.RPAREN) {
+	if !p.expectPeek(end) {
 		return nil
 	}
-	return args
+	return list
+}
+
+func (p *Parser) parseStringLiteral() ast.Expression {
+	return &ast.StringLiteral{Token: p.curToken, Value: p.curToken.Literal}
+}
+
+func (p *Parser) parseArrayLiteral() ast.Expression {
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	array.Elements = p.parseExpressionList(token.RBRACKET)
+	return array
+}
+
+func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
+	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
+	p.nextToken()
+	exp.Index = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RBRACKET) {
+		return nil
+	}
+	return exp
+}
+
+// parseImportExpression parses module imports.
+// e.g. 'import "lib/list"'
+func (p *Parser) parseImportExpression() ast.Expression {
+	exp := &ast.ImportExpression{Token: p.curToken}
+	if !p.expectPeek(token.STRING) {
+		return nil
+	}
+	exp.Path = p.curToken.Literal
+	return exp
+}
+
+// parseMemberExpression parses member access.
+// e.g. 'point.x'
+// A member may be named by a keyword, e.g. 'regex.match', as it can't be
+// mistaken for one after the dot.
+func (p *Parser) parseMemberExpression(object ast.Expression) ast.Expression {
+	exp := &ast.MemberExpression{Token: p.curToken, Object: object}
+	if kw, ok := token.Keywords[p.peekToken.Literal]; ok && p.peekTokenIs(kw) {
+		p.nextToken()
+	} else if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Member = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	return exp
+}
+
+// parsePropagateExpression parses the postfix ? operator.
+// e.g. 'read(path)?'
+func (p *Parser) parsePropagateExpression(value ast.Expression) ast.Expression {
+	return &ast.PropagateExpression{Token: p.curToken, Value: value}
+}
+
+// parseWithExpression parses the copying of a struct with changed fields.
+// e.g. 'p with { x: 3 }'
+func (p *Parser) parseWithExpression(value ast.Expression) ast.Expression {
+	exp := &ast.WithExpression{Token: p.curToken, Struct: value}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	seen := map[string]bool{}
+	for !p.peekTokenIs(token.RBRACE) {
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		name := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if seen[name.Value] {
+			p.addError(name.Token.Pos, "duplicate field %s", name.Value)
+		}
+		seen[name.Value] = true
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		exp.Fields = append(exp.Fields, ast.WithField{
+			Name:  name,
+			Value: p.parseExpression(LOWEST),
+		})
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	return exp
+}
+
+// parseHashLiteral parses hash literals.
+// e.g. '{"one": 1, "two": 1 + 1}'
+func (p *Parser) parseHashLiteral() ast.Expression {
+	hash := &ast.HashLiteral{Token: p.curToken, Pairs: []ast.HashPair{}}
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		key := p.parseExpression(LOWEST)
+		if !p.expectPeek(token.COLON) {
+			return nil
+		}
+		p.nextToken()
+		value := p.parseExpression(LOWEST)
+		hash.Pairs = append(hash.Pairs, ast.HashPair{Key: key, Value: value})
+
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	if !p.expectPeek(token.RBRACE) {
+		return nil
+	}
+	return hash
 }
 
+// parseGroupedExpression parses an expression in parentheses, or a tuple
+// literal if the parentheses contain several expressions.
+// e.g. '(a + b)' or '(a, b)'
 func (p *Parser) parseGroupedExpression() ast.Expression {
+	tok := p.curToken
 	p.nextToken()
 	exp := p.parseExpression(LOWEST)
+	if p.peekTokenIs(token.COMMA) {
+		tuple := &ast.TupleLiteral{Token: tok, Elements: []ast.Expression{exp}}
+		for p.peekTokenIs(token.COMMA) {
+			p.nextToken()
+			p.nextToken()
+			tuple.Elements = append(tuple.Elements, p.parseExpression(LOWEST))
+		}
+		exp = tuple
+	}
 	if !p.expectPeek(token.RPAREN) {
 		return nil
 	}
@@ -308,6 +1007,20 @@ func (p *Parser) parseIfExpression() ast.Expression {
 
 	if p.peekTokenIs(token.ELSE) {
 		p.nextToken()
+		// An else if is an if expression nested in the alternative.
+		if p.peekTokenIs(token.IF) {
+			p.nextToken()
+			stmt := &ast.ExpressionStatement{Token: p.curToken}
+			stmt.Expression = p.parseIfExpression()
+			if stmt.Expression == nil {
+				return nil
+			}
+			expression.Alternative = &ast.BlockStatement{
+				Token:      stmt.Token,
+				Statements: []ast.Statement{stmt},
+			}
+			return expression
+		}
 		if !p.expectPeek(token.LBRACE) {
 			return nil
 		}
@@ -317,6 +1030,245 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+// parseSwitchExpression parses a switch expression.
+// e.g. 'switch (x) { case 1, 2: "small"; default: "big" }'
+// The body of each case runs until the next case or the end of the switch.
+func (p *Parser) parseSwitchExpression() ast.Expression {
+	expression := &ast.SwitchExpression{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	expression.Value = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	p.nextToken()
+	for !p.curTokenIs(token.RBRACE) {
+		switch p.curToken.Type {
+		case token.CASE:
+			c := &ast.SwitchCase{Token: p.curToken}
+			p.nextToken()
+			c.Values = append(c.Values, p.parseExpression(LOWEST))
+			for p.peekTokenIs(token.COMMA) {
+				p.nextToken()
+				p.nextToken()
+				c.Values = append(c.Values, p.parseExpression(LOWEST))
+			}
+			if !p.expectPeek(token.COLON) {
+				return nil
+			}
+			c.Body = p.parseCaseBody()
+			expression.Cases = append(expression.Cases, c)
+		case token.DEFAULT:
+			if expression.Default != nil {
+				p.addError(p.curToken.Pos, "switch has more than one default case")
+			}
+			if !p.expectPeek(token.COLON) {
+				return nil
+			}
+			expression.Default = p.parseCaseBody()
+		default:
+			p.addError(p.curToken.Pos, "expected case or default, got %s "+
+				"instead", p.curToken.Type)
+			return nil
+		}
+	}
+	return expression
+}
+
+// parseCaseBody parses the statements following the colon of a switch case,
+// up to the next case or the end of the switch.
+func (p *Parser) parseCaseBody() *ast.BlockStatement {
+	block := &ast.BlockStatement{Token: p.curToken}
+	block.Statements = []ast.Statement{}
+	p.nextToken()
+	for !p.curTokenIs(token.CASE) && !p.curTokenIs(token.DEFAULT) &&
+		!p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
+		stmt := p.parseStatement()
+		if stmt != nil {
+			block.Statements = append(block.Statements, stmt)
+		}
+		p.nextToken()
+	}
+	return block
+}
+
+func (p *Parser) parseMatchExpression() ast.Expression {
+	expression := &ast.MatchExpression{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	expression.Value = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	// Arms are separated by commas, with an optional trailing comma.
+	for !p.peekTokenIs(token.RBRACE) {
+		p.nextToken()
+		arm := p.parseMatchArm()
+		if arm == nil {
+			return nil
+		}
+		expression.Arms = append(expression.Arms, arm)
+		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	return expression
+}
+
+// parseMatchArm parses a match arm, e.g. 1 | 2 => "small" or
+// n if n > 0 => n, starting at its first pattern.
+func (p *Parser) parseMatchArm() *ast.MatchArm {
+	arm := &ast.MatchArm{}
+	for {
+		pattern := p.parsePattern()
+		if pattern == nil {
+			return nil
+		}
+		arm.Patterns = append(arm.Patterns, pattern)
+		if !p.peekTokenIs(token.BIT_OR) {
+			break
+		}
+		p.nextToken()
+		p.nextToken()
+	}
+	if len(arm.Patterns) > 1 {
+		p.checkOrPatternBindings(arm.Patterns)
+	}
+	if p.peekTokenIs(token.IF) {
+		p.nextToken()
+		p.nextToken()
+		arm.Guard = p.parseExpression(LOWEST)
+	}
+	if !p.expectPeek(token.ARROW) {
+		return nil
+	}
+	p.nextToken()
+	arm.Body = p.parseExpression(LOWEST)
+	return arm
+}
+
+// checkOrPatternBindings checks that each of the alternatives of an
+// or-pattern binds the same names, adding an error if not. Otherwise which
+// names were bound would depend on which alternative matched.
+func (p *Parser) checkOrPatternBindings(patterns []ast.Expression) {
+	var names []*ast.Identifier
+	bound := make([]map[string]bool, len(patterns))
+	for i, pattern := range patterns {
+		bound[i] = map[string]bool{}
+		for _, ident := range patternBindings(pattern, nil) {
+			bound[i][ident.Value] = true
+			names = append(names, ident)
+		}
+	}
+	for i, pattern := range patterns {
+		for _, ident := range names {
+			if !bound[i][ident.Value] {
+				p.addError(pattern.Pos(),
+					"%s is not bound in every alternative of an or-pattern",
+					ident.Value)
+				return
+			}
+		}
+	}
+}
+
+// patternBindings appends the names which pattern binds to names.
+func patternBindings(
+	pattern ast.Expression,
+	names []*ast.Identifier,
+) []*ast.Identifier {
+	switch pattern := pattern.(type) {
+	case *ast.Identifier:
+		if pattern.Value != "_" {
+			names = append(names, pattern)
+		}
+	case *ast.VariantPattern:
+		for _, field := range pattern.Fields {
+			names = patternBindings(field, names)
+		}
+	}
+	return names
+}
+
+// parsePattern parses a pattern in a match arm: an integer, which may be
+// negative, boolean or string literal, an identifier, or an enum variant,
+// e.g. Shape.Rect(w, _).
+func (p *Parser) parsePattern() ast.Expression {
+	switch p.curToken.Type {
+	case token.INT:
+		return p.parseIntegerLiteral()
+	case token.MINUS:
+		pos := p.curToken.Pos
+		if !p.expectPeek(token.INT) {
+			return nil
+		}
+		tok := p.curToken
+		tok.Literal = "-" + tok.Literal
+		tok.Pos = pos
+		value, err := strconv.ParseInt(tok.Literal, 0, 64)
+		if err != nil {
+			p.addError(tok.Pos, "could not parse %q as integer", tok.Literal)
+			return nil
+		}
+		return &ast.IntegerLiteral{Token: tok, Value: value}
+	case token.TRUE, token.FALSE:
+		return p.parseBoolean()
+	case token.STRING:
+		return p.parseStringLiteral()
+	case token.IDENT:
+		if p.peekTokenIs(token.DOT) {
+			return p.parseVariantPattern()
+		}
+		return p.parseIdentifier()
+	}
+	p.addError(p.curToken.Pos, "expected a pattern, got %s instead",
+		p.curToken.Type)
+	return nil
+}
+
+// parseVariantPattern parses a pattern which matches an enum variant, e.g.
+// Shape.Rect(w, _) or Shape.Empty, starting at the enum's name.
+func (p *Parser) parseVariantPattern() ast.Expression {
+	pattern := &ast.VariantPattern{
+		Token: p.curToken,
+		Enum:  &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal},
+	}
+	p.nextToken()
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	pattern.Variant = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.peekTokenIs(token.LPAREN) {
+		return pattern
+	}
+	p.nextToken()
+	pattern.HasFields = true
+	for !p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		field := p.parsePattern()
+		if field == nil {
+			return nil
+		}
+		pattern.Fields = append(pattern.Fields, field)
+		if !p.peekTokenIs(token.RPAREN) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	p.nextToken()
+	return pattern
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
@@ -336,15 +1288,92 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	if !p.expectPeek(token.LPAREN) {
 		return nil
 	}
+	// A loop around the function literal doesn't extend into its parameters'
+	// defaults or its body.
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	defer func() { p.loopDepth = loopDepth }()
 	lit.Parameters = p.parseFunctionParameters()
 	if !p.expectPeek(token.LBRACE) {
 		return nil
 	}
 	lit.Body = p.parseBlockStatement()
+	markTailCalls(lit)
+	return lit
+}
+
+// parseMacroLiteral parses a macro literal, which is like a function
+// literal, except that calls in its body aren't marked as tail calls, as
+// macros are expanded before the program is evaluated.
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	lit := &ast.MacroLiteral{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	lit.Parameters = p.parseMacroParameters()
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	lit.Body = p.parseBlockStatement()
+	p.loopDepth = loopDepth
 	return lit
 }
 
-func (p *Parser) parseFunctionParameters() []*ast.Identifier {
+// parseFunctionParameters parses the parameters of a function literal.
+// e.g. '(a, b = 10, ...rest)'
+// Parameters with default values must follow those without, and a rest
+// parameter must come last.
+func (p *Parser) parseFunctionParameters() []*ast.Parameter {
+	params := []*ast.Parameter{}
+	seen := map[string]bool{}
+	for !p.peekTokenIs(token.RPAREN) {
+		param := &ast.Parameter{}
+		if p.peekTokenIs(token.ELLIPSIS) {
+			p.nextToken()
+			param.Rest = true
+			param.Token = p.curToken
+		}
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		if !param.Rest {
+			param.Token = p.curToken
+		}
+		param.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if seen[param.Name.Value] {
+			p.addError(param.Name.Pos(), "duplicate parameter %s",
+				param.Name.Value)
+		}
+		seen[param.Name.Value] = true
+
+		if !param.Rest && p.peekTokenIs(token.ASSIGN) {
+			p.nextToken()
+			p.nextToken()
+			param.Default = p.parseExpression(LOWEST)
+		} else if !param.Rest && len(params) > 0 &&
+			params[len(params)-1].Default != nil {
+			p.addError(param.Pos(), "parameter %s without a default value "+
+				"follows one with a default value", param.Name.Value)
+		}
+		params = append(params, param)
+		if param.Rest {
+			break
+		}
+		if !p.peekTokenIs(token.RPAREN) && !p.expectPeek(token.COMMA) {
+			return nil
+		}
+	}
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	return params
+}
+
+// parseMacroParameters parses the parameters of a macro literal, which are
+// only names.
+func (p *Parser) parseMacroParameters() []*ast.Identifier {
 	identifiers := []*ast.Identifier{}
 	if p.peekTokenIs(token.RPAREN) {
 		p.nextToken()
@@ -366,14 +1395,12 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, msg)
+	p.addError(p.curToken.Pos, "no prefix parse function for %s found", t)
 }
 
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("expected next token to be %s, got %s instead",
+	p.addError(p.peekToken.Pos, "expected next token to be %s, got %s instead",
 		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
 }
 
 func (p *Parser) curTokenIs(t token.TokenType) bool {
@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"reflect"
 	"testing"
 
 	"github.com/jamesroutley/monkey/ast"
 	"github.com/jamesroutley/monkey/lexer"
+	"github.com/jamesroutley/monkey/token"
 )
 
 func init() {
@@ -16,16 +18,16 @@ func init() {
 }
 
 func TestLetStatements(t *testing.T) {
-	tests := []struct{
-		input string
+	tests := []struct {
+		input              string
 		expectedIdentifier string
-		expectedValue interface{}
+		expectedValue      interface{}
 	}{
 		{"let x = 5;", "x", 5},
 		{"let y = true;", "y", true},
 		{"let foobar = y;", "foobar", "y"},
 	}
-	for _, tt := range tests{
+	for _, tt := range tests {
 		l := lexer.New(tt.input)
 		p := New(l)
 
@@ -48,15 +50,15 @@ func TestLetStatements(t *testing.T) {
 }
 
 func TestReturnStatements(t *testing.T) {
-	tests := []struct{
-		input string
+	tests := []struct {
+		input               string
 		expectedReturnValue interface{}
 	}{
 		{"return 5;", 5},
 		{"return 10;", 10},
 		{"return 993322", 993322},
 	}
-	for _, tt := range tests{
+	for _, tt := range tests {
 		l := lexer.New(tt.input)
 		p := New(l)
 
@@ -176,7 +178,7 @@ func TestIntegerLiteralExpression(t *testing.T) {
 		t.Errorf("literal.Value not %d. got=%d", 5, literal.Value)
 	}
 	if literal.TokenLiteral() != "5" {
-		t.Errorf("literal.TokenLiteral() not %d. got=%d", 5,
+		t.Errorf("literal.TokenLiteral() not %d. got=%s", 5,
 			literal.TokenLiteral())
 	}
 }
@@ -322,6 +324,339 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
+func TestElseIfExpression(t *testing.T) {
+	input := `if (a) { 1 } else if (b) { 2 } else if (c) { 3 } else { 4 }`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Body does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+
+	// Each else if is an if expression, alone in its parent's alternative.
+	exp := stmt.Expression
+	for _, condition := range []string{"a", "b", "c"} {
+		ifExp, ok := exp.(*ast.IfExpression)
+		if !ok {
+			t.Fatalf("expression is not ast.IfExpression. got=%T", exp)
+		}
+		if !testIdentifier(t, ifExp.Condition, condition) {
+			return
+		}
+		if ifExp.Alternative == nil || len(ifExp.Alternative.Statements) != 1 {
+			t.Fatalf("alternative doesn't have 1 statement")
+		}
+		alternative, ok := ifExp.Alternative.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("Statements[0] is not type ast.ExpressionStatement, got %T",
+				ifExp.Alternative.Statements[0])
+		}
+		exp = alternative.Expression
+	}
+	testIntegerLiteral(t, exp, 4)
+
+	expected := "ifa 1else ifb 2else ifc 3else 4"
+	if program.String() != expected {
+		t.Errorf("expected %q. got %q", expected, program.String())
+	}
+}
+
+func TestSwitchExpression(t *testing.T) {
+	input := `switch (x) { case 1, 2: "small"; case n: let y = n; y default: 0 }`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Body does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.SwitchExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.SwitchExpression. got=%T",
+			stmt.Expression)
+	}
+	if !testIdentifier(t, exp.Value, "x") {
+		return
+	}
+	if len(exp.Cases) != 2 {
+		t.Fatalf("switch doesn't have 2 cases. got %d", len(exp.Cases))
+	}
+
+	tests := []struct {
+		values     []interface{}
+		statements int
+	}{
+		{[]interface{}{1, 2}, 1},
+		{[]interface{}{"n"}, 2},
+	}
+	for i, tt := range tests {
+		c := exp.Cases[i]
+		if len(c.Values) != len(tt.values) {
+			t.Fatalf("case %d doesn't have %d values. got %d", i,
+				len(tt.values), len(c.Values))
+		}
+		for j, value := range tt.values {
+			testLiteralExpression(t, c.Values[j], value)
+		}
+		if len(c.Body.Statements) != tt.statements {
+			t.Errorf("case %d doesn't have %d statements. got %d", i,
+				tt.statements, len(c.Body.Statements))
+		}
+	}
+	if exp.Default == nil || exp.Default.String() != "0" {
+		t.Errorf("wrong default case. got %v", exp.Default)
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	input := `try { f(); 1 } catch (e) { 2 } finally { 3 }`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Body does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.TryExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.TryExpression. got=%T",
+			stmt.Expression)
+	}
+	if len(exp.Body.Statements) != 2 {
+		t.Errorf("body doesn't have 2 statements. got %d",
+			len(exp.Body.Statements))
+	}
+	if !testIdentifier(t, exp.CatchName, "e") {
+		return
+	}
+	if exp.Catch.String() != "2" {
+		t.Errorf("catch block is not %q. got %q", "2", exp.Catch.String())
+	}
+	if exp.Finally.String() != "3" {
+		t.Errorf("finally block is not %q. got %q", "3", exp.Finally.String())
+	}
+}
+
+func TestStructStatement(t *testing.T) {
+	input := `struct Point { x, y }`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Body does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.StructStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.StructStatement. got=%T",
+			program.Statements[0])
+	}
+	if !testIdentifier(t, stmt.Name, "Point") {
+		return
+	}
+	if len(stmt.Fields) != 2 {
+		t.Fatalf("struct doesn't have 2 fields. got %d", len(stmt.Fields))
+	}
+	testIdentifier(t, stmt.Fields[0], "x")
+	testIdentifier(t, stmt.Fields[1], "y")
+}
+
+func TestEnumStatement(t *testing.T) {
+	input := `enum Shape { Circle(r), Rect(w, h), Empty }`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Body does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.EnumStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.EnumStatement. got=%T",
+			program.Statements[0])
+	}
+	if !testIdentifier(t, stmt.Name, "Shape") {
+		return
+	}
+	tests := []struct {
+		name   string
+		fields []string
+	}{
+		{"Circle", []string{"r"}},
+		{"Rect", []string{"w", "h"}},
+		{"Empty", []string{}},
+	}
+	if len(stmt.Variants) != len(tests) {
+		t.Fatalf("enum doesn't have %d variants. got %d", len(tests),
+			len(stmt.Variants))
+	}
+	for i, tt := range tests {
+		variant := stmt.Variants[i]
+		testIdentifier(t, variant.Name, tt.name)
+		if len(variant.Fields) != len(tt.fields) {
+			t.Errorf("variant %s doesn't have %d fields. got %d", tt.name,
+				len(tt.fields), len(variant.Fields))
+			continue
+		}
+		for j, field := range tt.fields {
+			testIdentifier(t, variant.Fields[j], field)
+		}
+	}
+}
+
+func TestVariantPattern(t *testing.T) {
+	tests := []struct {
+		input     string
+		enum      string
+		variant   string
+		fields    []string
+		hasFields bool
+	}{
+		{"match (s) { Shape.Rect(w, _) => 1 }", "Shape", "Rect",
+			[]string{"w", "_"}, true},
+		{"match (s) { Shape.Rect => 1 }", "Shape", "Rect", nil, false},
+		{"match (s) { Shape.Rect() => 1 }", "Shape", "Rect", nil, true},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		match := stmt.Expression.(*ast.MatchExpression)
+		pattern, ok := match.Arms[0].Patterns[0].(*ast.VariantPattern)
+		if !ok {
+			t.Fatalf("pattern is not ast.VariantPattern. got=%T",
+				match.Arms[0].Patterns[0])
+		}
+		testIdentifier(t, pattern.Enum, tt.enum)
+		testIdentifier(t, pattern.Variant, tt.variant)
+		if pattern.HasFields != tt.hasFields {
+			t.Errorf("%s - pattern.HasFields wrong. want %t, got %t",
+				tt.input, tt.hasFields, pattern.HasFields)
+		}
+		if len(pattern.Fields) != len(tt.fields) {
+			t.Fatalf("%s - pattern doesn't have %d fields. got %d", tt.input,
+				len(tt.fields), len(pattern.Fields))
+		}
+		for i, field := range tt.fields {
+			testIdentifier(t, pattern.Fields[i], field)
+		}
+	}
+}
+
+func TestImplStatement(t *testing.T) {
+	input := `impl Point { fn add(self, other) { self } fn len(self) { 2 } }`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Body does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ImplStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ImplStatement. got=%T",
+			program.Statements[0])
+	}
+	if !testIdentifier(t, stmt.Type, "Point") {
+		return
+	}
+	if len(stmt.Methods) != 2 {
+		t.Fatalf("impl doesn't have 2 methods. got %d", len(stmt.Methods))
+	}
+	testIdentifier(t, stmt.Methods[0].Name, "add")
+	testIdentifier(t, stmt.Methods[1].Name, "len")
+	if len(stmt.Methods[0].Function.Parameters) != 2 {
+		t.Fatalf("add doesn't have 2 parameters. got %d",
+			len(stmt.Methods[0].Function.Parameters))
+	}
+	if stmt.Methods[0].Function.Name != "Point.add" {
+		t.Errorf("add's function name wrong. want %q, got %q", "Point.add",
+			stmt.Methods[0].Function.Name)
+	}
+}
+
+func TestMatchExpression(t *testing.T) {
+	input := `match (x) { 1 | -2 => "small", n if n > 9 => n, true => t, _ => 0 }`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Body does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+	exp, ok := stmt.Expression.(*ast.MatchExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.MatchExpression. got=%T",
+			stmt.Expression)
+	}
+	if !testIdentifier(t, exp.Value, "x") {
+		return
+	}
+	if len(exp.Arms) != 4 {
+		t.Fatalf("match doesn't have 4 arms. got %d", len(exp.Arms))
+	}
+
+	tests := []struct {
+		patterns []interface{}
+		guard    string
+		body     string
+	}{
+		{[]interface{}{1, -2}, "", `"small"`},
+		{[]interface{}{"n"}, "(n > 9)", "n"},
+		{[]interface{}{true}, "", "t"},
+		{[]interface{}{"_"}, "", "0"},
+	}
+	for i, tt := range tests {
+		arm := exp.Arms[i]
+		if len(arm.Patterns) != len(tt.patterns) {
+			t.Errorf("arm %d: expected %d patterns. got %d", i,
+				len(tt.patterns), len(arm.Patterns))
+			continue
+		}
+		for j, pattern := range tt.patterns {
+			testLiteralExpression(t, arm.Patterns[j], pattern)
+		}
+		guard := ""
+		if arm.Guard != nil {
+			guard = arm.Guard.String()
+		}
+		if guard != tt.guard {
+			t.Errorf("arm %d: expected guard %q. got %q", i, tt.guard, guard)
+		}
+		if arm.Body.String() != tt.body {
+			t.Errorf("arm %d: expected body %q. got %q", i, tt.body,
+				arm.Body.String())
+		}
+	}
+}
+
 func TestParsingPrefixExpressions(t *testing.T) {
 	prefixTests := []struct {
 		input    string
@@ -382,6 +717,17 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 < 5;", 5, "<", 5},
 		{"5 == 5;", 5, "==", 5},
 		{"5 != 5;", 5, "!=", 5},
+		{"5 % 5;", 5, "%", 5},
+		{"5 ** 5;", 5, "**", 5},
+		{"5 <= 5;", 5, "<=", 5},
+		{"5 >= 5;", 5, ">=", 5},
+		{"5 & 5;", 5, "&", 5},
+		{"5 | 5;", 5, "|", 5},
+		{"5 ^ 5;", 5, "^", 5},
+		{"5 << 5;", 5, "<<", 5},
+		{"5 >> 5;", 5, ">>", 5},
+		{"true && false", true, "&&", false},
+		{"true || false", true, "||", false},
 		{"true == true", true, "==", true},
 		{"true != false", true, "!=", false},
 		{"false == false", false, "==", false},
@@ -439,8 +785,8 @@ func TestFunctionLiteralParsing(t *testing.T) {
 		t.Fatalf("function literal params wrong. Want 2. Got %d",
 			len(function.Parameters))
 	}
-	testLiteralExpression(t, function.Parameters[0], "x")
-	testLiteralExpression(t, function.Parameters[1], "y")
+	testLiteralExpression(t, function.Parameters[0].Name, "x")
+	testLiteralExpression(t, function.Parameters[1].Name, "y")
 	if len(function.Body.Statements) != 1 {
 		t.Fatalf("function.Body.Statements doesn't have 1 statement, got %d",
 			len(function.Body.Statements))
@@ -453,6 +799,55 @@ func TestFunctionLiteralParsing(t *testing.T) {
 	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
 
+func TestMacroLiteralParsing(t *testing.T) {
+	input := `macro(x, y) { f(x + y); }`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statement. got %d",
+			1, len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] not ast.ExpressionStatement. got %T",
+			program.Statements[0])
+	}
+	macro, ok := stmt.Expression.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("stmt.Expression not ast.MacroLiteral, got %T",
+			stmt.Expression)
+	}
+	if len(macro.Parameters) != 2 {
+		t.Fatalf("macro literal params wrong. Want 2. Got %d",
+			len(macro.Parameters))
+	}
+	testLiteralExpression(t, macro.Parameters[0], "x")
+	testLiteralExpression(t, macro.Parameters[1], "y")
+	if len(macro.Body.Statements) != 1 {
+		t.Fatalf("macro.Body.Statements doesn't have 1 statement, got %d",
+			len(macro.Body.Statements))
+	}
+	bodyStmt, ok := macro.Body.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("macro body stmt is not ast.ExpressionStatement, got %T",
+			macro.Body.Statements[0])
+	}
+	call, ok := bodyStmt.Expression.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("macro body expression is not ast.CallExpression, got %T",
+			bodyStmt.Expression)
+	}
+	// Macro bodies are evaluated during expansion, not as functions.
+	if call.Tail {
+		t.Errorf("call in macro body marked as a tail call")
+	}
+	testInfixExpression(t, call.Arguments[0], "x", "+", "y")
+}
+
 func TestFunctionParameterParsing(t *testing.T) {
 	tests := []struct {
 		input          string
@@ -461,6 +856,11 @@ func TestFunctionParameterParsing(t *testing.T) {
 		{input: "fn(){};", expectedParams: []string{}},
 		{input: "fn(x){};", expectedParams: []string{"x"}},
 		{input: "fn(x, y, z){};", expectedParams: []string{"x", "y", "z"}},
+		{input: "fn(x, y = 10, z = x * 2){};",
+			expectedParams: []string{"x", "y = 10", "z = (x * 2)"}},
+		{input: "fn(...rest){};", expectedParams: []string{"...rest"}},
+		{input: "fn(x, y = 1, ...rest){};",
+			expectedParams: []string{"x", "y = 1", "...rest"}},
 	}
 
 	for _, tt := range tests {
@@ -476,8 +876,41 @@ func TestFunctionParameterParsing(t *testing.T) {
 				len(tt.expectedParams), len(function.Parameters))
 		}
 
-		for i, ident := range tt.expectedParams {
-			testLiteralExpression(t, function.Parameters[i], ident)
+		for i, param := range tt.expectedParams {
+			if function.Parameters[i].String() != param {
+				t.Errorf("parameter %d wrong. expected %q, got %q", i, param,
+					function.Parameters[i].String())
+			}
+		}
+	}
+}
+
+func TestFunctionNames(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let add = fn(a, b) { a + b };", "add"},
+		{"fn(a, b) { a + b };", ""},
+		{"let f = g(fn() {});", ""},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		name := "<no function>"
+		ast.Inspect(program, func(node ast.Node) bool {
+			if fn, ok := node.(*ast.FunctionLiteral); ok {
+				name = fn.Name
+			}
+			return true
+		})
+		if name != tt.expected {
+			t.Errorf("%q - expected name %q. got %q", tt.input, tt.expected,
+				name)
 		}
 	}
 }
@@ -515,6 +948,634 @@ func TestCallExpressionParsing(t *testing.T) {
 	testInfixExpression(t, exp.Arguments[2], 4, "+", 5)
 }
 
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		name     string
+		operator string
+		value    interface{}
+	}{
+		{"x = 5;", "x", "=", 5},
+		{"x += 5;", "x", "+=", 5},
+		{"x -= y;", "x", "-=", "y"},
+		{"x *= true;", "x", "*=", true},
+		{"x /= 2", "x", "/=", 2},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got %d",
+				len(program.Statements))
+		}
+		stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+		if !ok {
+			t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got %T",
+				program.Statements[0])
+		}
+		exp, ok := stmt.Expression.(*ast.AssignExpression)
+		if !ok {
+			t.Fatalf("stmt.Expression is not ast.AssignExpression. got %T",
+				stmt.Expression)
+		}
+		if !testIdentifier(t, exp.Name, tt.name) {
+			return
+		}
+		if exp.Operator != tt.operator {
+			t.Errorf("exp.Operator is not '%s'. got %q", tt.operator,
+				exp.Operator)
+		}
+		if !testLiteralExpression(t, exp.Value, tt.value) {
+			return
+		}
+	}
+}
+
+func TestInvalidAssignTarget(t *testing.T) {
+	l := lexer.New("5 = 6;")
+	p := New(l)
+	p.ParseProgram()
+
+	errors := p.Errors()
+	if len(errors) == 0 {
+		t.Fatalf("expected a parser error")
+	}
+	if errors[0] != "cannot assign to 5" {
+		t.Errorf("wrong error. got %q", errors[0])
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < y) { x += 1; }`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got %d",
+			len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.WhileStatement. got %T",
+			program.Statements[0])
+	}
+	if !testInfixExpression(t, stmt.Condition, "x", "<", "y") {
+		return
+	}
+	if len(stmt.Body.Statements) != 1 {
+		t.Fatalf("body doesn't have 1 statement. got %d",
+			len(stmt.Body.Statements))
+	}
+	if stmt.String() != "while(x < y) (x += 1)" {
+		t.Errorf("stmt.String() wrong. got %q", stmt.String())
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	input := `for (i in range(10)) { if (i == 5) { break; } continue; };`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain 1 statement. got %d",
+			len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ForStatement. got %T",
+			program.Statements[0])
+	}
+	if !testIdentifier(t, stmt.Variable, "i") {
+		return
+	}
+	call, ok := stmt.Iterable.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("stmt.Iterable is not ast.CallExpression. got %T",
+			stmt.Iterable)
+	}
+	if !testIdentifier(t, call.Function, "range") {
+		return
+	}
+	if len(stmt.Body.Statements) != 2 {
+		t.Fatalf("body doesn't have 2 statements. got %d",
+			len(stmt.Body.Statements))
+	}
+	if _, ok := stmt.Body.Statements[1].(*ast.ContinueStatement); !ok {
+		t.Errorf("body.Statements[1] is not ast.ContinueStatement. got %T",
+			stmt.Body.Statements[1])
+	}
+	expected := "for(i in range(10)) if(i == 5) break;continue;"
+	if stmt.String() != expected {
+		t.Errorf("stmt.String() wrong. expected %q, got %q", expected,
+			stmt.String())
+	}
+}
+
+func TestLoopControlOutsideLoop(t *testing.T) {
+	tests := []struct {
+		input         string
+		expectedError string
+	}{
+		{"break;", "break outside loop"},
+		{"continue;", "continue outside loop"},
+		{"if (true) { break; }", "break outside loop"},
+		// A function body is not part of the loop it's defined in.
+		{"while (true) { fn() { continue; } }", "continue outside loop"},
+		{"while (true) { fn(a = if (true) { break; } else { 1 }) { a } }",
+			"break outside loop"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) != 1 {
+			t.Errorf("expected 1 parser error for %q. got %v", tt.input,
+				errors)
+			continue
+		}
+		if errors[0] != tt.expectedError {
+			t.Errorf("wrong error. expected %q, got %q", tt.expectedError,
+				errors[0])
+		}
+	}
+}
+
+func TestTailCallMarking(t *testing.T) {
+	input := `
+fn(n) {
+	notTailA(n);
+	let x = notTailB(n);
+	if (n == 0) {
+		return tailA(n);
+	}
+	while (n > 0) {
+		if (n == 1) { return tailB(n); }
+		notTailC(n);
+	}
+	1 + notTailD(n);
+	fn() { tailC(notTailE(n)) };
+	fn() { match (n) { 0 => tailF(n), _ if notTailG(n) => tailG(n) } };
+	fn() { switch (notTailH(n)) { case 0: notTailI(n); tailH(n) default: tailI(n) } };
+	fn() { if (n) { 1 } else if (notTailJ(n)) { tailJ(n) } };
+	fn() { try { return notTailK(n); } catch (e) { notTailL(n) } };
+	fn() { try { 1 } finally { notTailM(n) } };
+	if (n) { notTailF(n); tailD(n) } else { tailE(n) }
+}`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	tail := map[string]bool{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		if call, ok := node.(*ast.CallExpression); ok {
+			tail[call.Function.String()] = call.Tail
+		}
+		return true
+	})
+
+	expected := map[string]bool{
+		"notTailA": false,
+		"notTailB": false,
+		"notTailC": false,
+		"notTailD": false,
+		"notTailE": false,
+		"notTailF": false,
+		"notTailG": false,
+		"notTailH": false,
+		"notTailI": false,
+		"notTailJ": false,
+		"notTailK": false,
+		"notTailL": false,
+		"notTailM": false,
+		"tailA":    true,
+		"tailB":    true,
+		"tailC":    true,
+		"tailD":    true,
+		"tailE":    true,
+		"tailF":    true,
+		"tailG":    true,
+		"tailH":    true,
+		"tailI":    true,
+		"tailJ":    true,
+	}
+	for name, expectedTail := range expected {
+		actual, ok := tail[name]
+		if !ok {
+			t.Errorf("call to %s not found", name)
+			continue
+		}
+		if actual != expectedTail {
+			t.Errorf("call to %s: expected Tail to be %t. got %t", name,
+				expectedTail, actual)
+		}
+	}
+}
+
+func TestTopLevelCallsAreNotTailCalls(t *testing.T) {
+	l := lexer.New("f(1); return g(2);")
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	ast.Inspect(program, func(node ast.Node) bool {
+		if call, ok := node.(*ast.CallExpression); ok && call.Tail {
+			t.Errorf("call %s outside of a function marked as tail call",
+				call)
+		}
+		return true
+	})
+}
+
+func TestStringLiteralExpression(t *testing.T) {
+	input := `"hello world";`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	literal, ok := stmt.Expression.(*ast.StringLiteral)
+	if !ok {
+		t.Fatalf("exp not *ast.StringLiteral. got=%T", stmt.Expression)
+	}
+	if literal.Value != "hello world" {
+		t.Errorf("literal.Value not %q. got=%q", "hello world", literal.Value)
+	}
+}
+
+func TestArrayLiteralParsing(t *testing.T) {
+	input := "[1, 2 * 2, 3 + 3]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	array, ok := stmt.Expression.(*ast.ArrayLiteral)
+	if !ok {
+		t.Fatalf("exp not ast.ArrayLiteral. got=%T", stmt.Expression)
+	}
+	if len(array.Elements) != 3 {
+		t.Fatalf("len(array.Elements) not 3. got=%d", len(array.Elements))
+	}
+	testIntegerLiteral(t, array.Elements[0], 1)
+	testInfixExpression(t, array.Elements[1], 2, "*", 2)
+	testInfixExpression(t, array.Elements[2], 3, "+", 3)
+}
+
+func TestIndexExpressionParsing(t *testing.T) {
+	input := "myArray[1 + 1]"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	indexExp, ok := stmt.Expression.(*ast.IndexExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.IndexExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, indexExp.Left, "myArray") {
+		return
+	}
+	testInfixExpression(t, indexExp.Index, 1, "+", 1)
+}
+
+func TestMemberExpressionParsing(t *testing.T) {
+	input := "point.x"
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	exp, ok := stmt.Expression.(*ast.MemberExpression)
+	if !ok {
+		t.Fatalf("exp not *ast.MemberExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, exp.Object, "point") {
+		return
+	}
+	testIdentifier(t, exp.Member, "x")
+
+	// Members can be named by keywords.
+	l = lexer.New("regex.match")
+	p = New(l)
+	program = p.ParseProgram()
+	checkParserErrors(t, p)
+	if program.String() != "(regex.match)" {
+		t.Errorf("expected %q. got %q", "(regex.match)", program.String())
+	}
+
+	l = lexer.New("point.1")
+	p = New(l)
+	p.ParseProgram()
+	if len(p.Errors()) == 0 {
+		t.Errorf("expected an error for a member which isn't an identifier")
+	}
+}
+
+func TestHashLiteralParsing(t *testing.T) {
+	input := `{"one": 1, "two": 2, "three": 3}`
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	hash, ok := stmt.Expression.(*ast.HashLiteral)
+	if !ok {
+		t.Fatalf("exp is not ast.HashLiteral. got=%T", stmt.Expression)
+	}
+
+	// Pairs keep their source order.
+	expected := []struct {
+		key   string
+		value int64
+	}{
+		{"one", 1},
+		{"two", 2},
+		{"three", 3},
+	}
+	if len(hash.Pairs) != len(expected) {
+		t.Fatalf("hash.Pairs has wrong length. got=%d", len(hash.Pairs))
+	}
+	for i, pair := range hash.Pairs {
+		literal, ok := pair.Key.(*ast.StringLiteral)
+		if !ok {
+			t.Errorf("key is not ast.StringLiteral. got=%T", pair.Key)
+			continue
+		}
+		if literal.Value != expected[i].key {
+			t.Errorf("key %d wrong. expected %q, got %q", i,
+				expected[i].key, literal.Value)
+		}
+		testIntegerLiteral(t, pair.Value, expected[i].value)
+	}
+}
+
+func TestParseErrorPositions(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedPos token.Position
+		expectedMsg string
+	}{
+		{
+			"let = 5;",
+			token.Position{Line: 1, Column: 5},
+			"expected next token to be IDENT, got = instead",
+		},
+		{
+			"let x = 1;\n  break;",
+			token.Position{Line: 2, Column: 3},
+			"break outside loop",
+		},
+		{
+			"[1, 2",
+			token.Position{Line: 1, Column: 6},
+			"expected next token to be ], got EOF instead",
+		},
+		{
+			"fn(a, b = 1, c) {}",
+			token.Position{Line: 1, Column: 14},
+			"parameter c without a default value follows one with a " +
+				"default value",
+		},
+		{
+			"fn(a, ...b, c) {}",
+			token.Position{Line: 1, Column: 11},
+			"expected next token to be ), got , instead",
+		},
+		{
+			"fn(a, b, a) {}",
+			token.Position{Line: 1, Column: 10},
+			"duplicate parameter a",
+		},
+		{
+			"f(a: 1, 2)",
+			token.Position{Line: 1, Column: 9},
+			"positional argument follows named argument",
+		},
+		{
+			"let [a, ...rest, b] = xs;",
+			token.Position{Line: 1, Column: 16},
+			"expected next token to be ], got , instead",
+		},
+		{
+			"let [a, 1] = xs;",
+			token.Position{Line: 1, Column: 9},
+			"expected a name or pattern, got INT instead",
+		},
+		{
+			`let {"x": y} = h;`,
+			token.Position{Line: 1, Column: 6},
+			"expected next token to be IDENT, got STRING instead",
+		},
+		{
+			"match (x) {\n  1 => a\n  _ => b\n}",
+			token.Position{Line: 3, Column: 3},
+			"expected next token to be ,, got IDENT instead",
+		},
+		{
+			"match (x) { a + 1 => a }",
+			token.Position{Line: 1, Column: 15},
+			"expected next token to be =>, got + instead",
+		},
+		{
+			"match (x) { [a] => a }",
+			token.Position{Line: 1, Column: 13},
+			"expected a pattern, got [ instead",
+		},
+		{
+			"if (a) { 1 } else if { 2 }",
+			token.Position{Line: 1, Column: 22},
+			"expected next token to be (, got { instead",
+		},
+		{
+			"switch (x) { 1: a }",
+			token.Position{Line: 1, Column: 14},
+			"expected case or default, got INT instead",
+		},
+		{
+			"switch (x) { case 1 a }",
+			token.Position{Line: 1, Column: 21},
+			"expected next token to be :, got IDENT instead",
+		},
+		{
+			"switch (x) { default: a default: b }",
+			token.Position{Line: 1, Column: 25},
+			"switch has more than one default case",
+		},
+		{
+			"struct Point { x, y, x }",
+			token.Position{Line: 1, Column: 22},
+			"duplicate field x",
+		},
+		{
+			"struct Point { x y }",
+			token.Position{Line: 1, Column: 18},
+			"expected next token to be ,, got IDENT instead",
+		},
+		{
+			"impl Point { fn len(self) {} fn len(self) {} }",
+			token.Position{Line: 1, Column: 33},
+			"duplicate method len",
+		},
+		{
+			"impl Point { fn new() {} }",
+			token.Position{Line: 1, Column: 17},
+			"method new has no receiver parameter",
+		},
+		{
+			"impl Point { len(self) {} }",
+			token.Position{Line: 1, Column: 14},
+			"expected next token to be FUNCTION, got IDENT instead",
+		},
+		{
+			"p with { x: 1, x: 2 }",
+			token.Position{Line: 1, Column: 16},
+			"duplicate field x",
+		},
+		{
+			`p with { "x": 1 }`,
+			token.Position{Line: 1, Column: 10},
+			"expected next token to be IDENT, got STRING instead",
+		},
+		{
+			"try { 1 }",
+			token.Position{Line: 1, Column: 1},
+			"try without catch or finally",
+		},
+		{
+			"try { 1 } catch { 2 }",
+			token.Position{Line: 1, Column: 17},
+			"expected next token to be (, got { instead",
+		},
+		{
+			"match (x) { 1 | n => n }",
+			token.Position{Line: 1, Column: 13},
+			"n is not bound in every alternative of an or-pattern",
+		},
+		{
+			"match (x) { S.A(a) | S.B(b) => a }",
+			token.Position{Line: 1, Column: 13},
+			"b is not bound in every alternative of an or-pattern",
+		},
+		{
+			"match (x) { S.A | S.B(_, [n]) => n }",
+			token.Position{Line: 1, Column: 26},
+			"expected a pattern, got [ instead",
+		},
+		{
+			"match (x) { S.A(n, m) | S.B(_, S.C(n)) => n }",
+			token.Position{Line: 1, Column: 25},
+			"m is not bound in every alternative of an or-pattern",
+		},
+		{
+			"match (x) { S.1 => 1 }",
+			token.Position{Line: 1, Column: 15},
+			"expected next token to be IDENT, got INT instead",
+		},
+		{
+			"enum Shape { Circle(r), Circle }",
+			token.Position{Line: 1, Column: 25},
+			"duplicate variant Circle",
+		},
+		{
+			"enum Shape { Rect(w, w) }",
+			token.Position{Line: 1, Column: 22},
+			"duplicate field w",
+		},
+		{
+			"enum Shape { Circle(1) }",
+			token.Position{Line: 1, Column: 21},
+			"expected next token to be IDENT, got INT instead",
+		},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.ParseErrors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q. got none", tt.input)
+			continue
+		}
+		if errors[0].Pos != tt.expectedPos {
+			t.Errorf("%q - wrong position. expected %s, got %s", tt.input,
+				tt.expectedPos, errors[0].Pos)
+		}
+		if errors[0].Message != tt.expectedMsg {
+			t.Errorf("%q - wrong message. expected %q, got %q", tt.input,
+				tt.expectedMsg, errors[0].Message)
+		}
+	}
+}
+
+// TestParseErrorsDontCascade checks that errors in otherwise well-formed
+// code don't stop the parser, which would report errors for the rest of it.
+func TestParseErrorsDontCascade(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"fn(a, a) { a }; 1", []string{"duplicate parameter a"}},
+		{"fn(a = 1, b) { a }; 1", []string{
+			"parameter b without a default value follows one with a " +
+				"default value",
+		}},
+		{"f(a: 1, 2); 1", []string{
+			"positional argument follows named argument",
+		}},
+		{"struct P { x, x }; 1", []string{"duplicate field x"}},
+		{"p with { x: 1, x: 2 }; 1", []string{"duplicate field x"}},
+		{"enum E { A, A }; 1", []string{"duplicate variant A"}},
+		{"enum E { A(x, x), B }; 1", []string{"duplicate field x"}},
+		{"impl P { fn f(self) { 1 } fn f(self) { 2 } }; 1", []string{
+			"duplicate method f",
+		}},
+		{"impl P { fn f() { 1 } fn g(self) { 2 } }; 1", []string{
+			"method f has no receiver parameter",
+		}},
+		{"match (x) { 1 | n => n, _ => 0 }; 1", []string{
+			"n is not bound in every alternative of an or-pattern",
+		}},
+		{"switch (x) { default: 1; default: 2 }; 1", []string{
+			"switch has more than one default case",
+		}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if !reflect.DeepEqual(errors, tt.expected) {
+			t.Errorf("%q - expected errors %q, got %q", tt.input,
+				tt.expected, errors)
+		}
+	}
+}
+
 func TestOperatorPrecedenceParsing(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -556,6 +1617,43 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a + b + c * d / f + g)",
 			"add((((a + b) + ((c * d) / f)) + g))",
 		},
+		{"a * b % c", "((a * b) % c)"},
+		{"a + b % c", "(a + (b % c))"},
+		{"a ** b ** c", "(a ** (b ** c))"},
+		{"a * b ** c", "(a * (b ** c))"},
+		{"-a ** b", "(-(a ** b))"},
+		{"a ** -b", "(a ** (-b))"},
+		{"a <= b == b >= a", "((a <= b) == (b >= a))"},
+		{"a || b && c", "(a || (b && c))"},
+		{"a && b || c && d", "((a && b) || (c && d))"},
+		{"a == b && c != d", "((a == b) && (c != d))"},
+		{"a < b || a > c", "((a < b) || (a > c))"},
+		{"a | b ^ c & d", "(a | (b ^ (c & d)))"},
+		{"a & b == c", "((a & b) == c)"},
+		{"a << b + c", "(a << (b + c))"},
+		{"a >> b & c", "((a >> b) & c)"},
+		{"a | b < c", "((a | b) < c)"},
+		{"x = a + b", "(x = (a + b))"},
+		{"x = y = z", "(x = (y = z))"},
+		{"x += a * b", "(x += (a * b))"},
+		{"x = a || b && c", "(x = (a || (b && c)))"},
+		{"x = f(y = 2)", "(x = f((y = 2)))"},
+		{"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)"},
+		{
+			"add(a * b[2], b[1], 2 * [1, 2][1])",
+			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
+		},
+		{"-a[0]", "(-(a[0]))"},
+		{"a.b * c.d", "((a.b) * (c.d))"},
+		{"-a.b", "(-(a.b))"},
+		{"a.b(c).d", "((a.b)(c).d)"},
+		{"f(x)? + 1", "((f(x)?) + 1)"},
+		{"-a.b?", "(-((a.b)?))"},
+		{"a?.b()?", "(((a?).b)()?)"},
+		{"a[0]? * b?", "(((a[0])?) * (b?))"},
+		{"a with {x: 1} == b", "((a with {x: 1}) == b)"},
+		{"-a.b with {x: 1}", "(-((a.b) with {x: 1}))"},
+		{"f(a) with {x: 1} with {y: 2}", "((f(a) with {x: 1}) with {y: 2})"},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,61 @@
+package parser
+
+import (
+	"github.com/jamesroutley/monkey/ast"
+)
+
+// markTailCalls sets Tail on each call expression in tail position within a
+// function literal's body. A call is in tail position if the function
+// returns its result directly: either it's the value of a return statement,
+// or it's the value of the body's last statement. The branches of an if,
+// switch or match expression are in tail position if the expression is.
+//
+// Nothing inside a try expression is in tail position, as the try expression
+// must still be around to catch exceptions and run its finally block when
+// the call returns.
+//
+// Nested function literals aren't descended into, as they're marked when
+// they're parsed.
+func markTailCalls(fn *ast.FunctionLiteral) {
+	markTailBlock(fn.Body)
+	ast.Inspect(fn.Body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral, *ast.TryExpression:
+			return false
+		case *ast.ReturnStatement:
+			markTailExpression(node.ReturnValue)
+		}
+		return true
+	})
+}
+
+// markTailBlock marks the value of block's last statement as being in tail
+// position.
+func markTailBlock(block *ast.BlockStatement) {
+	if block == nil || len(block.Statements) == 0 {
+		return
+	}
+	last := block.Statements[len(block.Statements)-1]
+	if stmt, ok := last.(*ast.ExpressionStatement); ok {
+		markTailExpression(stmt.Expression)
+	}
+}
+
+func markTailExpression(exp ast.Expression) {
+	switch exp := exp.(type) {
+	case *ast.CallExpression:
+		exp.Tail = true
+	case *ast.IfExpression:
+		markTailBlock(exp.Consequence)
+		markTailBlock(exp.Alternative)
+	case *ast.SwitchExpression:
+		for _, c := range exp.Cases {
+			markTailBlock(c.Body)
+		}
+		markTailBlock(exp.Default)
+	case *ast.MatchExpression:
+		for _, arm := range exp.Arms {
+			markTailExpression(arm.Body)
+		}
+	}
+}
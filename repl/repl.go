@@ -2,20 +2,26 @@ package repl
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
 
 	"github.com/jamesroutley/monkey/evaluator"
 	"github.com/jamesroutley/monkey/lexer"
+	"github.com/jamesroutley/monkey/object"
 	"github.com/jamesroutley/monkey/parser"
 )
 
 // PROMPT is the prompt string to print at the repl.
 const PROMPT = ">> "
 
-// Start starts the Monkey repl.
-func Start(in io.Reader, out io.Writer) {
+// Start starts the Monkey repl. Each line is evaluated with opts.
+func Start(in io.Reader, out io.Writer, opts evaluator.Options) {
 	scanner := bufio.NewScanner(in)
+	// Bindings and macros persist between lines, so share one environment
+	// for each.
+	env := object.NewEnvironment()
+	macroEnv := object.NewEnvironment()
 
 	for {
 		fmt.Printf(PROMPT)
@@ -35,7 +41,13 @@ func Start(in io.Reader, out io.Writer) {
 			continue
 		}
 
-		evaluated := evaluator.Eval(program)
+		e := evaluator.New(context.Background(), opts)
+		var evaluated object.Object
+		if expanded, err := e.ExpandMacros(program, macroEnv); err != nil {
+			evaluated = err
+		} else {
+			evaluated = e.Eval(expanded, env)
+		}
 		if evaluated != nil {
 			io.WriteString(out, evaluated.Inspect())
 			io.WriteString(out, "\n")
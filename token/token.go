@@ -1,5 +1,9 @@
 package token
 
+import (
+	"fmt"
+)
+
 // TokenType defines the type of a token
 type TokenType string
 
@@ -8,6 +12,26 @@ type TokenType string
 type Token struct {
 	Type    TokenType
 	Literal string
+	Pos     Position // Where the token starts in the source.
+}
+
+// Position is a location in Monkey source code. Lines and columns start at 1;
+// the zero Position means the location is unknown.
+type Position struct {
+	Line   int
+	Column int // Byte offset within the line.
+}
+
+// IsValid reports whether the position is known.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
 }
 
 // Token Types
@@ -16,29 +40,55 @@ const (
 	EOF     = "EOF"
 
 	// Identifiers and literals
-	IDENT = "IDENT"
-	INT   = "INT"
+	IDENT  = "IDENT"
+	INT    = "INT"
+	STRING = "STRING"
 
 	// Operators
-	ASSIGN   = "="
+	ASSIGN          = "="
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+
 	PLUS     = "+"
 	MINUS    = "-"
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
+	POWER    = "**"
 	LT       = "<"
 	GT       = ">"
+	LT_EQ    = "<="
+	GT_EQ    = ">="
 	EQ       = "=="
 	NOT_EQ   = "!="
+	AND      = "&&"
+	OR       = "||"
+	QUESTION = "?"
+
+	// Bitwise operators
+	BIT_AND     = "&"
+	BIT_OR      = "|"
+	BIT_XOR     = "^"
+	SHIFT_LEFT  = "<<"
+	SHIFT_RIGHT = ">>"
 
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
+	COLON     = ":"
+	DOT       = "."
+	ELLIPSIS  = "..."
+	ARROW     = "=>"
 
-	LPAREN = "("
-	RPAREN = ")"
-	LBRACE = "}"
-	RBRACE = "}"
+	LPAREN   = "("
+	RPAREN   = ")"
+	LBRACE   = "{"
+	RBRACE   = "}"
+	LBRACKET = "["
+	RBRACKET = "]"
 
 	// Keywords
 	FUNCTION = "FUNCTION"
@@ -48,24 +98,131 @@ const (
 	IF       = "IF"
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	IMPORT   = "IMPORT"
+	MACRO    = "MACRO"
+	MATCH    = "MATCH"
+	SWITCH   = "SWITCH"
+	CASE     = "CASE"
+	DEFAULT  = "DEFAULT"
+	THROW    = "THROW"
+	TRY      = "TRY"
+	CATCH    = "CATCH"
+	FINALLY  = "FINALLY"
+	STRUCT   = "STRUCT"
+	WITH     = "WITH"
+	IMPL     = "IMPL"
+	ENUM     = "ENUM"
 )
 
-var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
+// Keywords maps each reserved word to its token type.
+var Keywords = map[string]TokenType{
+	"fn":       FUNCTION,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"import":   IMPORT,
+	"macro":    MACRO,
+	"match":    MATCH,
+	"switch":   SWITCH,
+	"case":     CASE,
+	"default":  DEFAULT,
+	"throw":    THROW,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"struct":   STRUCT,
+	"with":     WITH,
+	"impl":     IMPL,
+	"enum":     ENUM,
+}
+
+// Punctuation maps the literal of each operator and delimiter to its token
+// type. It's the single source of truth the lexer uses to recognise them, so
+// adding an operator means adding its constant above and an entry here.
+var Punctuation = map[string]TokenType{
+	"=":  ASSIGN,
+	"+=": PLUS_ASSIGN,
+	"-=": MINUS_ASSIGN,
+	"*=": ASTERISK_ASSIGN,
+	"/=": SLASH_ASSIGN,
+
+	"+":  PLUS,
+	"-":  MINUS,
+	"!":  BANG,
+	"*":  ASTERISK,
+	"/":  SLASH,
+	"%":  PERCENT,
+	"**": POWER,
+	"<":  LT,
+	">":  GT,
+	"<=": LT_EQ,
+	">=": GT_EQ,
+	"==": EQ,
+	"!=": NOT_EQ,
+	"&&": AND,
+	"||": OR,
+	"?":  QUESTION,
+
+	"&":  BIT_AND,
+	"|":  BIT_OR,
+	"^":  BIT_XOR,
+	"<<": SHIFT_LEFT,
+	">>": SHIFT_RIGHT,
+
+	",":   COMMA,
+	";":   SEMICOLON,
+	":":   COLON,
+	".":   DOT,
+	"...": ELLIPSIS,
+	"=>":  ARROW,
+
+	"(": LPAREN,
+	")": RPAREN,
+	"{": LBRACE,
+	"}": RBRACE,
+	"[": LBRACKET,
+	"]": RBRACKET,
+}
+
+// MaxPunctuationLength is the length of the longest literal in Punctuation.
+var MaxPunctuationLength = maxKeyLength(Punctuation)
+
+func maxKeyLength(m map[string]TokenType) int {
+	max := 0
+	for k := range m {
+		if len(k) > max {
+			max = len(k)
+		}
+	}
+	return max
 }
 
 // LookupIdent returns the token type associated with the identifier ident
 // If ident happens to be a keyword, the keyword token type is returned.
 // If ident is not a keyword, the IDENT token type is returned.
 func LookupIdent(ident string) TokenType {
-	if tok, ok := keywords[ident]; ok {
+	if tok, ok := Keywords[ident]; ok {
 		return tok
 	}
 	return IDENT
 }
+
+// LookupPunctuation returns the token type of the operator or delimiter
+// literal lit. ok is false if lit isn't one.
+func LookupPunctuation(lit string) (tok TokenType, ok bool) {
+	tok, ok = Punctuation[lit]
+	return tok, ok
+}
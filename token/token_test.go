@@ -0,0 +1,49 @@
+package token
+
+import (
+	"testing"
+)
+
+func TestPunctuationTable(t *testing.T) {
+	seen := map[TokenType]string{}
+	for literal, tokenType := range Punctuation {
+		// Operator and delimiter token types are defined as their literal,
+		// which makes parser error messages readable.
+		if string(tokenType) != literal {
+			t.Errorf("Punctuation[%q] has type %q, expected %q", literal,
+				tokenType, literal)
+		}
+		if other, ok := seen[tokenType]; ok {
+			t.Errorf("%q and %q share token type %q", other, literal,
+				tokenType)
+		}
+		seen[tokenType] = literal
+		if len(literal) > MaxPunctuationLength {
+			t.Errorf("%q is longer than MaxPunctuationLength %d", literal,
+				MaxPunctuationLength)
+		}
+	}
+}
+
+func TestKeywordsTable(t *testing.T) {
+	seen := map[TokenType]string{}
+	for keyword, tokenType := range Keywords {
+		if _, ok := Punctuation[string(tokenType)]; ok {
+			t.Errorf("keyword %q has the type of a punctuation token %q",
+				keyword, tokenType)
+		}
+		if other, ok := seen[tokenType]; ok {
+			t.Errorf("%q and %q share token type %q", other, keyword,
+				tokenType)
+		}
+		seen[tokenType] = keyword
+		if LookupIdent(keyword) != tokenType {
+			t.Errorf("LookupIdent(%q) wrong. expected %q, got %q", keyword,
+				tokenType, LookupIdent(keyword))
+		}
+	}
+	if LookupIdent("foobar") != IDENT {
+		t.Errorf("LookupIdent(%q) wrong. expected %q, got %q", "foobar",
+			IDENT, LookupIdent("foobar"))
+	}
+}